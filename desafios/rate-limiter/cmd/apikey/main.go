@@ -0,0 +1,68 @@
+// Comando apikey gera os valores usados na configuração das chaves de API:
+//
+//	go run ./cmd/apikey hash <chave>              hash para RATE_LIMIT_TOKENS
+//	go run ./cmd/apikey sign -plan pro -ttl 720h  chave assinada de um plano
+//
+// As chaves são assinadas com o primeiro secret de RATE_LIMIT_KEY_SECRETS.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "hash":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		hash := apikey.Hash(os.Args[2])
+		fmt.Printf("hash: %s\nid:   %s\n", hash, apikey.HashID(hash))
+	case "sign":
+		sign(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func sign(args []string) {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	plan := flags.String("plan", "", "plano da chave, configurado em RATE_LIMIT_PLANS")
+	id := flags.String("id", "", "ID da chave (aleatório quando vazio)")
+	ttl := flags.Duration("ttl", 0, "validade da chave (0 não expira)")
+	flags.Parse(args)
+
+	_ = godotenv.Load()
+	secret, _, _ := strings.Cut(os.Getenv("RATE_LIMIT_KEY_SECRETS"), ",")
+	if secret = strings.TrimSpace(secret); secret == "" {
+		log.Fatal("RATE_LIMIT_KEY_SECRETS is not set")
+	}
+
+	claims := apikey.Claims{ID: *id, Plan: *plan}
+	if *ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(*ttl).Unix()
+	}
+
+	key, err := apikey.Sign(claims, []byte(secret))
+	if err != nil {
+		log.Fatalf("Failed to sign key: %v", err)
+	}
+	fmt.Println(key)
+}
+
+func usage() {
+	log.Fatal("usage: apikey hash <key> | apikey sign -plan <plan> [-id <id>] [-ttl <duration>]")
+}
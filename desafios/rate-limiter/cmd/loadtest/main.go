@@ -0,0 +1,253 @@
+// Comando loadtest envia tráfego concorrente a um servidor em execução,
+// simulando muitos IPs e tokens, e informa quantas requisições foram aceitas
+// e negadas e os percentis de latência:
+//
+//	go run ./cmd/loadtest -url http://localhost:8080/api/info -duration 10s -ips 50 -tokens abc123
+//
+// Os IPs simulados são enviados em X-Forwarded-For, então o servidor só os
+// considera quando o endereço do loadtest está em RATE_LIMIT_TRUSTED_PROXIES
+// (ex: 127.0.0.1/32).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type options struct {
+	url         string
+	method      string
+	duration    time.Duration
+	rate        float64
+	concurrency int
+	ips         int
+	tokens      []string
+	tokenRatio  float64
+	timeout     time.Duration
+}
+
+// sample é o resultado de uma requisição: o status (0 quando falhou) e a
+// política, ip ou token, que o cliente simulado usou.
+type sample struct {
+	policy  string
+	status  int
+	latency time.Duration
+}
+
+func main() {
+	log.SetFlags(0)
+
+	var opts options
+	var tokens string
+	flag.StringVar(&opts.url, "url", "http://localhost:8080/api/info", "URL que recebe as requisições")
+	flag.StringVar(&opts.method, "method", http.MethodGet, "método HTTP")
+	flag.DurationVar(&opts.duration, "duration", 10*time.Second, "duração do teste")
+	flag.Float64Var(&opts.rate, "rate", 0, "requisições por segundo somando todos os workers (0 não limita)")
+	flag.IntVar(&opts.concurrency, "concurrency", 10, "requisições simultâneas")
+	flag.IntVar(&opts.ips, "ips", 100, "IPs simulados em X-Forwarded-For (0 não envia o header)")
+	flag.StringVar(&tokens, "tokens", "", "tokens enviados no header API_KEY, separados por vírgula")
+	flag.Float64Var(&opts.tokenRatio, "token-ratio", 0.5, "fração das requisições enviadas com token")
+	flag.DurationVar(&opts.timeout, "timeout", 5*time.Second, "timeout de cada requisição")
+	flag.Parse()
+
+	for _, token := range strings.Split(tokens, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			opts.tokens = append(opts.tokens, token)
+		}
+	}
+	if opts.concurrency < 1 || opts.duration <= 0 || opts.rate < 0 || opts.ips < 0 {
+		log.Fatal("concurrency and duration must be positive, and rate and ips must not be negative")
+	}
+	if opts.tokenRatio < 0 || opts.tokenRatio > 1 {
+		log.Fatal("token-ratio must be between 0 and 1")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	log.Printf("Sending %s %s for %s with %d workers...", opts.method, opts.url, opts.duration, opts.concurrency)
+	start := time.Now()
+	samples := run(ctx, opts)
+	report(os.Stdout, samples, time.Since(start))
+}
+
+// run dispara as requisições até ctx terminar. Com rate, um ticker distribui
+// as requisições entre os workers; sem ele, cada worker envia a próxima assim
+// que recebe a resposta.
+func run(ctx context.Context, opts options) []sample {
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.concurrency,
+			MaxIdleConnsPerHost: opts.concurrency,
+		},
+	}
+
+	var ticks <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+
+			var local []sample
+			for {
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-ctx.Done():
+					}
+				}
+				if ctx.Err() != nil {
+					break
+				}
+				local = append(local, send(ctx, client, opts, random))
+			}
+
+			mu.Lock()
+			samples = append(samples, local...)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	return samples
+}
+
+func send(ctx context.Context, client *http.Client, opts options, random *rand.Rand) sample {
+	req, err := http.NewRequestWithContext(ctx, opts.method, opts.url, nil)
+	if err != nil {
+		log.Fatalf("Invalid request: %v", err)
+	}
+
+	s := sample{policy: "ip"}
+	if opts.ips > 0 {
+		n := random.Intn(opts.ips)
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.%d.%d.%d", n>>16&255, n>>8&255, n&255))
+	}
+	if len(opts.tokens) > 0 && random.Float64() < opts.tokenRatio {
+		req.Header.Set("API_KEY", opts.tokens[random.Intn(len(opts.tokens))])
+		s.policy = "token"
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	s.latency = time.Since(start)
+	if err != nil {
+		// requisições interrompidas pelo fim do teste não contam como erro
+		if ctx.Err() != nil {
+			s.policy = ""
+		}
+		return s
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	s.status = resp.StatusCode
+	return s
+}
+
+func report(w io.Writer, samples []sample, elapsed time.Duration) {
+	var completed []sample
+	for _, s := range samples {
+		if s.policy != "" {
+			completed = append(completed, s)
+		}
+	}
+	if len(completed) == 0 {
+		fmt.Fprintln(w, "No requests completed")
+		return
+	}
+
+	fmt.Fprintf(w, "\nRequests:   %d in %s (%.1f req/s)\n", len(completed), elapsed.Round(time.Millisecond), float64(len(completed))/elapsed.Seconds())
+
+	fmt.Fprintf(w, "\n%-8s %10s %10s %10s %10s\n", "policy", "requests", "allowed", "denied", "errors")
+	for _, policy := range []string{"ip", "token", "total"} {
+		var total, allowed, denied, failed int
+		for _, s := range completed {
+			if policy != "total" && s.policy != policy {
+				continue
+			}
+			total++
+			switch {
+			case s.status >= 200 && s.status < 400:
+				allowed++
+			case s.status == http.StatusTooManyRequests:
+				denied++
+			default:
+				failed++
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %10d %9.1f%% %9.1f%% %9.1f%%\n", policy, total,
+			percent(allowed, total), percent(denied, total), percent(failed, total))
+	}
+
+	statuses := make(map[int]int)
+	for _, s := range completed {
+		statuses[s.status]++
+	}
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	fmt.Fprintln(w, "\nStatus codes:")
+	for _, code := range codes {
+		name := http.StatusText(code)
+		if code == 0 {
+			name = "connection error"
+		}
+		fmt.Fprintf(w, "  %3d %-22s %d\n", code, name, statuses[code])
+	}
+
+	latencies := make([]time.Duration, len(completed))
+	for i, s := range completed {
+		latencies[i] = s.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Fprintln(w, "\nLatency:")
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Fprintf(w, "  p%-4g %s\n", p, percentile(latencies, p).Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "  max   %s\n", latencies[len(latencies)-1].Round(time.Microsecond))
+}
+
+func percent(n, total int) float64 {
+	return float64(n) * 100 / float64(total)
+}
+
+// percentile usa o método nearest-rank sobre as latências ordenadas.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(float64(len(sorted))*p/100)) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
@@ -1,19 +1,37 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/admin"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/metrics"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/quota"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// shutdownTimeout é quanto o servidor espera as requisições em andamento
+// terminarem depois de receber SIGINT ou SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Carrega as configurações
 	cfg, err := config.Load()
@@ -22,7 +40,7 @@ func main() {
 	}
 
 	// Inicializa o storage (Redis)
-	store, err := storage.NewRedisStorage(
+	redisStore, err := storage.NewRedisStorage(
 		cfg.Redis.Host,
 		cfg.Redis.Port,
 		cfg.Redis.Password,
@@ -31,12 +49,83 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
-	defer store.Close()
 
 	log.Println("Connected to Redis successfully")
 
-	// Cria o rate limiter
+	m := metrics.New(prometheus.DefaultRegisterer)
+
+	// Circuit breaker em volta do Redis e, no modo local, fallback em memória
+	breaker := storage.NewBreaker(m.Storage(redisStore), redisStore.Ping, cfg.BreakerFailures, cfg.BreakerProbeInterval)
+	breaker.OnStateChange = func(open bool) {
+		m.SetCircuitOpen(open)
+		if open {
+			log.Printf("Redis unavailable, circuit breaker open (failure mode: %s)", cfg.FailureMode)
+		} else {
+			log.Println("Redis recovered, circuit breaker closed")
+		}
+	}
+
+	var store storage.Storage = breaker
+	if cfg.FailureMode == config.FailLocal {
+		store = storage.NewFallback(breaker, storage.NewBoundedMemoryStorage(cfg.FallbackMaxKeys), cfg.FallbackScale)
+	}
+	defer store.Close()
+
+	// Cria o rate limiter, com métricas das decisões
 	rateLimiter := limiter.New(store, cfg)
+	rateLimiter.Observe(m)
+	if cfg.LogDenials {
+		rateLimiter.Observe(limiter.NewDenialLogger(os.Stdout))
+	}
+
+	// Tarefas em segundo plano, encerradas só depois do servidor HTTP para que o
+	// último snapshot inclua as cotas das requisições finalizadas no shutdown
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var backgroundDone sync.WaitGroup
+
+	// Snapshot do consumo das cotas no PostgreSQL, para sobreviver à perda do Redis
+	if cfg.QuotaDSN != "" {
+		db, err := sql.Open("postgres", cfg.QuotaDSN)
+		if err != nil {
+			log.Fatalf("Failed to open quota database: %v", err)
+		}
+		defer db.Close()
+
+		ctx := context.Background()
+		quotaStore := quota.NewSQLStore(db)
+		if err := quotaStore.Migrate(ctx); err != nil {
+			log.Fatalf("Failed to migrate quota database: %v", err)
+		}
+
+		persister := quota.NewPersister(rateLimiter, store, quotaStore)
+		persister.OnError = func(err error) {
+			log.Printf("Failed to snapshot quota usage: %v", err)
+		}
+		if err := persister.Restore(ctx); err != nil {
+			log.Printf("Failed to restore quota usage: %v", err)
+		}
+		backgroundDone.Add(1)
+		go func() {
+			defer backgroundDone.Done()
+			persister.Run(background, cfg.SnapshotInterval)
+		}()
+		log.Printf("Quota usage snapshots enabled every %v", cfg.SnapshotInterval)
+	}
+
+	// Recarrega o arquivo de políticas sem reiniciar o servidor
+	if cfg.PolicyFile != "" {
+		log.Printf("Rate limit policies loaded from %s", cfg.PolicyFile)
+		go config.WatchPolicyFile(background, cfg,
+			func(updated *config.Config) {
+				rateLimiter.SetConfig(updated)
+				log.Printf("Rate limit policies reloaded from %s", cfg.PolicyFile)
+			},
+			func(err error) {
+				log.Printf("Failed to reload rate limit policies, keeping current ones: %v", err)
+			},
+		)
+	}
 
 	// Configura o roteador
 	r := chi.NewRouter()
@@ -46,14 +135,28 @@ func main() {
 	r.Use(chimiddleware.Recoverer)
 	r.Use(chimiddleware.RequestID)
 
-	// Aplica o middleware de rate limiting
-	r.Use(middleware.RateLimiterMiddleware(rateLimiter))
+	// Rotas, com o middleware de rate limiting
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimiterMiddleware(rateLimiter))
+
+		r.Get("/", handleHome)
+		r.Get("/health", handleHealth)
+		r.Post("/api/data", handleData)
+		r.Get("/api/info", handleInfo(rateLimiter))
+	})
+
+	// Métricas do Prometheus, fora do rate limiting
+	r.Handle("/metrics", promhttp.Handler())
 
-	// Rotas
-	r.Get("/", handleHome)
-	r.Get("/health", handleHealth)
-	r.Post("/api/data", handleData)
-	r.Get("/api/info", handleInfo)
+	// Consumo das cotas do token, limitado só pelo IP para não consumir a cota
+	r.With(middleware.RateLimiterMiddleware(rateLimiter, middleware.WithIPOnly())).
+		Method(http.MethodGet, "/usage", quota.NewUsageHandler(rateLimiter))
+
+	// API administrativa, fora do rate limiting e habilitada apenas com ADMIN_TOKEN
+	if cfg.AdminToken != "" {
+		r.Mount("/admin", admin.NewRouter(rateLimiter, cfg.AdminToken))
+		log.Println("Admin API enabled on /admin")
+	}
 
 	// Inicia o servidor
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
@@ -62,18 +165,66 @@ func main() {
 		cfg.IP.Requests, cfg.IP.Duration, cfg.IP.BlockDuration)
 	log.Printf("Rate Limit - Token (default): %d req/%v, Block: %v",
 		cfg.Token.Requests, cfg.Token.Duration, cfg.Token.BlockDuration)
+	if cfg.Token.Daily > 0 || cfg.Token.Monthly > 0 {
+		log.Printf("Rate Limit - Token quotas (default): %d/day, %d/month (%s)",
+			cfg.Token.Daily, cfg.Token.Monthly, cfg.QuotaLocation)
+	}
 
+	// tokens são identificados pelo ID, sem expor a chave nos logs
 	if len(cfg.Tokens) > 0 {
 		log.Printf("Custom token limits configured for %d tokens", len(cfg.Tokens))
-		for token, limit := range cfg.Tokens {
+		for hash, limit := range cfg.Tokens {
 			log.Printf("  - Token %s: %d req/%v, Block: %v",
-				token, limit.Requests, limit.Duration, limit.BlockDuration)
+				apikey.HashID(hash), limit.Requests, limit.Duration, limit.BlockDuration)
 		}
 	}
+	if len(cfg.Plans) > 0 {
+		log.Printf("Signed key plans configured: %d plans, %d secrets", len(cfg.Plans), len(cfg.KeySecrets))
+		for plan, limit := range cfg.Plans {
+			log.Printf("  - Plan %s: %d req/%v, Block: %v",
+				plan, limit.Requests, limit.Duration, limit.BlockDuration)
+		}
+	}
+
+	for _, route := range cfg.Routes {
+		log.Printf("Rate Limit - Route %s: IP %d req/%v, Token %d req/%v",
+			route.Name(), route.IP.Requests, route.IP.Duration, route.Token.Requests, route.Token.Duration)
+	}
+	if len(cfg.ExemptPaths) > 0 {
+		log.Printf("Rate Limit - Exempt paths: %v", cfg.ExemptPaths)
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		log.Printf("Rate Limit - Trusted proxies: %v", cfg.TrustedProxies)
+	}
+	if len(cfg.AllowList) > 0 || len(cfg.DenyList) > 0 {
+		log.Printf("Rate Limit - Allowlist: %v, Denylist: %v", cfg.AllowList, cfg.DenyList)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := http.ListenAndServe(addr, r); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: addr, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Printf("Failed to start server: %v", err)
+	case <-ctx.Done():
+		log.Println("Shutting down server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down server gracefully: %v", err)
+		}
 	}
+
+	// o Run do persister faz o último snapshot antes de retornar
+	stopBackground()
+	backgroundDone.Wait()
+	log.Println("Server stopped")
 }
 
 func handleHome(w http.ResponseWriter, r *http.Request) {
@@ -83,6 +234,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 			"GET /health - Health check",
 			"GET /api/info - Get API information",
 			"POST /api/data - Submit data",
+			"GET /usage - Get quota usage of the API_KEY",
 		},
 		"usage": map[string]string{
 			"rate_limit_by_ip":    "Requests are limited by IP address",
@@ -121,19 +273,22 @@ func handleData(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func handleInfo(w http.ResponseWriter, r *http.Request) {
-	apiKey := r.Header.Get("API_KEY")
+// handleInfo informa o ID da chave enviada, nunca a própria chave.
+func handleInfo(rl *limiter.RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(middleware.HeaderAPIKey)
 
-	response := map[string]interface{}{
-		"message":       "API Information",
-		"version":       "1.0.0",
-		"authenticated": apiKey != "",
-	}
+		response := map[string]interface{}{
+			"message":       "API Information",
+			"version":       "1.0.0",
+			"authenticated": apiKey != "",
+		}
 
-	if apiKey != "" {
-		response["token"] = apiKey
-	}
+		if apiKey != "" {
+			response["key_id"], _ = rl.Config().TokenLimit(apiKey)
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
 }
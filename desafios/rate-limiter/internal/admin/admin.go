@@ -0,0 +1,318 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/go-chi/chi/v5"
+)
+
+// NewRouter cria as rotas da API administrativa. Todas exigem o header
+// "Authorization: Bearer <token>". Tokens são consultados pelo ID da chave
+// (o início do hash ou, em chaves assinadas, "<plano>.<id>"), para que a chave
+// não apareça em URLs e logs de acesso.
+//
+//	GET    /{ip|token}/{id}           estado, política efetiva e override
+//	DELETE /{ip|token}/{id}/block     remove o bloqueio
+//	DELETE /{ip|token}/{id}/counters  apaga contadores e bloqueios
+//	PUT    /{ip|token}/{id}/override  substitui o limite temporariamente
+//	DELETE /{ip|token}/{id}/override  remove o override
+func NewRouter(rl *limiter.RateLimiter, token string) http.Handler {
+	h := &handler{rl: rl}
+
+	r := chi.NewRouter()
+	r.Use(authenticate(token))
+
+	r.Route("/{kind:ip|token}/{id}", func(r chi.Router) {
+		r.Get("/", h.status)
+		r.Delete("/block", h.unblock)
+		r.Delete("/counters", h.reset)
+		r.Put("/override", h.setOverride)
+		r.Delete("/override", h.removeOverride)
+	})
+
+	return r
+}
+
+func authenticate(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type handler struct {
+	rl *limiter.RateLimiter
+}
+
+type limitResponse struct {
+	Requests      int              `json:"requests"`
+	Duration      string           `json:"duration"`
+	BlockDuration string           `json:"block_duration"`
+	Algorithm     config.Algorithm `json:"algorithm"`
+	Concurrency   int              `json:"concurrency,omitempty"`
+	Daily         int              `json:"daily_quota,omitempty"`
+	Monthly       int              `json:"monthly_quota,omitempty"`
+	Shadow        bool             `json:"shadow,omitempty"`
+}
+
+type overrideResponse struct {
+	Limit     limitResponse `json:"limit"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+type keyResponse struct {
+	Key          string        `json:"key"`
+	Route        string        `json:"route,omitempty"`
+	Limit        limitResponse `json:"limit"`
+	Count        int64         `json:"count"`
+	ResetAt      *time.Time    `json:"reset_at,omitempty"`
+	Log          int64         `json:"sliding_log_count"`
+	Current      int64         `json:"sliding_window_current"`
+	Previous     int64         `json:"sliding_window_previous"`
+	Tokens       float64       `json:"tokens"`
+	TAT          *time.Time    `json:"gcra_tat,omitempty"`
+	BlockedUntil *time.Time    `json:"blocked_until,omitempty"`
+	InFlight     int64         `json:"in_flight"`
+	Violations   int64         `json:"violations"`
+}
+
+type statusResponse struct {
+	Key      string            `json:"key"`
+	Policy   string            `json:"policy"`
+	Limit    limitResponse     `json:"limit"`
+	Override *overrideResponse `json:"override,omitempty"`
+	Keys     []keyResponse     `json:"keys"`
+}
+
+type overrideRequest struct {
+	Requests      int              `json:"requests"`
+	Duration      string           `json:"duration"`
+	BlockDuration string           `json:"block_duration"`
+	Algorithm     config.Algorithm `json:"algorithm"`
+	Concurrency   int              `json:"concurrency"`
+	Daily         int              `json:"daily_quota"`
+	Monthly       int              `json:"monthly_quota"`
+	Shadow        bool             `json:"shadow"`
+	TTL           string           `json:"ttl"`
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request) {
+	req, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.rl.Status(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := h.rl.Config().Now()
+	response := statusResponse{
+		Key:    status.Key,
+		Policy: status.Policy,
+		Limit:  toLimitResponse(status.Limit),
+		Keys:   make([]keyResponse, 0, len(status.Keys)),
+	}
+	if status.Override != nil {
+		response.Override = toOverrideResponse(*status.Override)
+	}
+
+	for _, k := range status.Keys {
+		key := keyResponse{
+			Key:        k.Key,
+			Route:      k.Route,
+			Limit:      toLimitResponse(k.Limit),
+			Count:      k.State.Count,
+			Log:        k.State.Log,
+			Current:    k.State.Current,
+			Previous:   k.State.Previous,
+			Tokens:     k.State.Tokens,
+			InFlight:   k.State.InFlight,
+			Violations: k.State.Violations,
+		}
+		if k.State.CountResetAfter > 0 {
+			resetAt := now.Add(k.State.CountResetAfter)
+			key.ResetAt = &resetAt
+		}
+		if !k.State.TAT.IsZero() {
+			key.TAT = &k.State.TAT
+		}
+		if k.State.BlockedFor > 0 {
+			blockedUntil := now.Add(k.State.BlockedFor)
+			key.BlockedUntil = &blockedUntil
+		}
+		response.Keys = append(response.Keys, key)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *handler) unblock(w http.ResponseWriter, r *http.Request) {
+	req, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.rl.Unblock(r.Context(), req); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) reset(w http.ResponseWriter, r *http.Request) {
+	req, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.rl.Reset(r.Context(), req); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) setOverride(w http.ResponseWriter, r *http.Request) {
+	req, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	var body overrideRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	limit := config.RateLimitConfig{
+		Requests:    body.Requests,
+		Algorithm:   body.Algorithm,
+		Concurrency: body.Concurrency,
+		Daily:       body.Daily,
+		Monthly:     body.Monthly,
+		Shadow:      body.Shadow,
+	}
+	var ttl time.Duration
+	for _, d := range []struct {
+		name     string
+		value    string
+		dest     *time.Duration
+		optional bool
+	}{
+		{"duration", body.Duration, &limit.Duration, false},
+		{"block_duration", body.BlockDuration, &limit.BlockDuration, true},
+		{"ttl", body.TTL, &ttl, false},
+	} {
+		if d.value == "" && d.optional {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q", d.name, d.value))
+			return
+		}
+		*d.dest = parsed
+	}
+
+	override, err := h.rl.SetOverride(r.Context(), req, limit, ttl)
+	if errors.Is(err, limiter.ErrInvalidOverride) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toOverrideResponse(override))
+}
+
+func (h *handler) removeOverride(w http.ResponseWriter, r *http.Request) {
+	req, ok := subject(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.rl.RemoveOverride(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "override not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// subject converte os parâmetros da rota no IP ou no ID do token consultado.
+func subject(w http.ResponseWriter, r *http.Request) (limiter.Request, bool) {
+	id, err := url.PathUnescape(chi.URLParam(r, "id"))
+	if err != nil || id == "" {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return limiter.Request{}, false
+	}
+
+	if chi.URLParam(r, "kind") == limiter.PolicyToken {
+		return limiter.Request{KeyID: id}, true
+	}
+
+	addr, err := netip.ParseAddr(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid ip %q", id))
+		return limiter.Request{}, false
+	}
+	return limiter.Request{IP: addr.String()}, true
+}
+
+func toLimitResponse(limit config.RateLimitConfig) limitResponse {
+	algorithm := limit.Algorithm
+	if algorithm == "" {
+		algorithm = config.AlgorithmFixedWindow
+	}
+	return limitResponse{
+		Requests:      limit.Requests,
+		Duration:      limit.Duration.String(),
+		BlockDuration: limit.BlockDuration.String(),
+		Algorithm:     algorithm,
+		Concurrency:   limit.Concurrency,
+		Daily:         limit.Daily,
+		Monthly:       limit.Monthly,
+		Shadow:        limit.Shadow,
+	}
+}
+
+func toOverrideResponse(override limiter.Override) *overrideResponse {
+	return &overrideResponse{Limit: toLimitResponse(override.Limit), ExpiresAt: override.ExpiresAt}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+func newTestRouter(t *testing.T) (http.Handler, *limiter.RateLimiter) {
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute},
+		Token:  config.RateLimitConfig{Requests: 10, Duration: time.Minute, BlockDuration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	rl := limiter.New(store, cfg)
+	return NewRouter(rl, testToken), rl
+}
+
+func serve(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, header := range []string{"", "Bearer wrong", testToken} {
+		req := httptest.NewRequest("GET", "/ip/192.168.1.1", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, header)
+	}
+
+	disabled := NewRouter(limiter.New(storage.NewMemoryStorage(), &config.Config{}), "")
+	req := httptest.NewRequest("GET", "/ip/192.168.1.1", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	disabled.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAdmin_StatusAndUnblock(t *testing.T) {
+	router, rl := newTestRouter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+	}
+
+	w := serve(router, "GET", "/ip/192.168.1.1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var status statusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "ip:192.168.1.1", status.Key)
+	assert.Equal(t, "ip", status.Policy)
+	assert.Equal(t, limitResponse{Requests: 1, Duration: "1m0s", BlockDuration: "1m0s", Algorithm: config.AlgorithmFixedWindow}, status.Limit)
+	require.Len(t, status.Keys, 1)
+	assert.Equal(t, int64(2), status.Keys[0].Count)
+	require.NotNil(t, status.Keys[0].BlockedUntil)
+	assert.True(t, status.Keys[0].BlockedUntil.After(time.Now()))
+
+	w = serve(router, "DELETE", "/ip/192.168.1.1/block", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = serve(router, "GET", "/ip/192.168.1.1", "")
+	status = statusResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Nil(t, status.Keys[0].BlockedUntil)
+	assert.Equal(t, int64(2), status.Keys[0].Count)
+
+	w = serve(router, "DELETE", "/ip/192.168.1.1/counters", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	w = serve(router, "GET", "/ip/not-an-ip", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdmin_Override(t *testing.T) {
+	router, rl := newTestRouter(t)
+	ctx := context.Background()
+	// tokens são consultados pelo ID, sem a chave na URL
+	path := "/token/" + apikey.HashID(apikey.Hash("abc123"))
+
+	w := serve(router, "PUT", path+"/override", `{"requests": 100, "duration": "1s", "ttl": "1h", "algorithm": "token_bucket"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var override overrideResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &override))
+	assert.Equal(t, 100, override.Limit.Requests)
+	assert.Equal(t, config.AlgorithmTokenBucket, override.Limit.Algorithm)
+
+	decision, err := rl.CheckLimit(ctx, "", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, 100, decision.Limit)
+
+	w = serve(router, "GET", path, "")
+	var status statusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.NotNil(t, status.Override)
+	assert.Equal(t, 100, status.Limit.Requests)
+
+	w = serve(router, "DELETE", path+"/override", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = serve(router, "DELETE", path+"/override", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	invalid := []string{
+		`{"requests": 100, "duration": "1x", "ttl": "1h"}`,
+		`{"requests": 100, "duration": "1s"}`,
+		`{"requests": 100, "duration": "1s", "ttl": "1h", "algorithm": "leaky"}`,
+		`{"requests": 100, "duration": "1s", "ttl": "1h", "unknown": true}`,
+		`{"requests": 0, "duration": "1s", "ttl": "1h"}`,
+	}
+	for _, body := range invalid {
+		w = serve(router, "PUT", path+"/override", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,143 @@
+// Package apikey identifica as chaves de API sem guardar o seu valor: chaves
+// comuns são configuradas e comparadas pelo hash SHA-256, e chaves assinadas
+// com HMAC carregam o próprio plano. Contadores, logs e a API administrativa
+// usam apenas o ID da chave.
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	hashPrefix   = "sha256:"
+	signedPrefix = "rlk_"
+
+	// idLength é o número de caracteres hexadecimais do hash usados no ID
+	idLength = 16
+)
+
+var (
+	ErrInvalid = errors.New("invalid signed key")
+	ErrExpired = errors.New("signed key expired")
+)
+
+// Hash retorna o hash da chave no formato aceito pela configuração,
+// "sha256:<hex>".
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsHash indica se value já é um hash no formato de Hash.
+func IsHash(value string) bool {
+	digest, found := strings.CutPrefix(value, hashPrefix)
+	if !found || len(digest) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// HashID retorna o ID de uma chave comum a partir do seu hash: o início do
+// digest, suficiente para distinguir as chaves sem permitir recuperá-las.
+func HashID(hash string) string {
+	digest := strings.ToLower(strings.TrimPrefix(hash, hashPrefix))
+	return digest[:min(idLength, len(digest))]
+}
+
+// Claims é o conteúdo de uma chave assinada. O ID identifica a chave nos
+// contadores e Plan escolhe a política configurada para o plano. ExpiresAt é o
+// instante Unix a partir do qual a chave deixa de valer (0 não expira).
+type Claims struct {
+	ID        string `json:"id"`
+	Plan      string `json:"plan"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// KeyID retorna o ID da chave assinada, prefixado pelo plano para que a API
+// administrativa encontre a política sem conhecer a chave.
+func (c Claims) KeyID() string {
+	return c.Plan + "." + c.ID
+}
+
+// IsSigned indica se key tem o formato de uma chave assinada.
+func IsSigned(key string) bool {
+	return strings.HasPrefix(key, signedPrefix)
+}
+
+// Sign emite uma chave "rlk_<claims>.<assinatura>", com as partes em base64
+// URL. Sem ID, um aleatório é gerado.
+func Sign(claims Claims, secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("signing secret is empty")
+	}
+	if claims.Plan == "" || strings.Contains(claims.Plan, ".") {
+		return "", fmt.Errorf("invalid plan %q", claims.Plan)
+	}
+	if claims.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return "", fmt.Errorf("failed to generate key id: %w", err)
+		}
+		claims.ID = hex.EncodeToString(id)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return signedPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(sign(encoded, secret)), nil
+}
+
+// Verify confere a assinatura da chave com cada um dos secrets, para permitir
+// a rotação, e retorna o seu conteúdo.
+func Verify(key string, secrets [][]byte, now time.Time) (Claims, error) {
+	encoded, signature, found := strings.Cut(strings.TrimPrefix(key, signedPrefix), ".")
+	if !IsSigned(key) || !found {
+		return Claims{}, ErrInvalid
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+
+	valid := false
+	for _, secret := range secrets {
+		if len(secret) > 0 && hmac.Equal(given, sign(encoded, secret)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return Claims{}, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" || claims.Plan == "" {
+		return Claims{}, ErrInvalid
+	}
+	if claims.ExpiresAt > 0 && !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+func sign(encoded string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signedPrefix + encoded))
+	return mac.Sum(nil)
+}
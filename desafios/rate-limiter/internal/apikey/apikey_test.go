@@ -0,0 +1,64 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	hash := Hash("abc123")
+	assert.Equal(t, "sha256:6ca13d52ca70c883e0f0bb101e425a89e8624de51db2d2392593af6a84118090", hash)
+	assert.True(t, IsHash(hash))
+	assert.Equal(t, "6ca13d52ca70c883", HashID(hash))
+
+	assert.False(t, IsHash("abc123"))
+	assert.False(t, IsHash("sha256:abc123"))
+	assert.False(t, IsHash("sha256:"+strings.Repeat("z", 64)))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	secret := []byte("current")
+	now := time.Now()
+
+	key, err := Sign(Claims{Plan: "pro", ExpiresAt: now.Add(time.Hour).Unix()}, secret)
+	require.NoError(t, err)
+	assert.True(t, IsSigned(key))
+
+	claims, err := Verify(key, [][]byte{secret}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "pro", claims.Plan)
+	assert.Len(t, claims.ID, 16, "a random id is generated")
+	assert.Equal(t, "pro."+claims.ID, claims.KeyID())
+
+	// a chave continua válida enquanto o secret antigo estiver na lista
+	_, err = Verify(key, [][]byte{[]byte("next"), secret}, now)
+	assert.NoError(t, err)
+
+	_, err = Verify(key, [][]byte{[]byte("next")}, now)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Verify(key, [][]byte{secret}, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	// trocar o plano invalida a assinatura
+	forged, err := Sign(Claims{ID: claims.ID, Plan: "enterprise"}, []byte("attacker"))
+	require.NoError(t, err)
+	_, signature, _ := strings.Cut(key, ".")
+	payload, _, _ := strings.Cut(forged, ".")
+	_, err = Verify(payload+"."+signature, [][]byte{secret}, now)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	for _, invalid := range []string{"abc123", "rlk_", "rlk_abc", "rlk_abc.def"} {
+		_, err = Verify(invalid, [][]byte{secret}, now)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+
+	_, err = Sign(Claims{Plan: "pro"}, nil)
+	assert.Error(t, err)
+	_, err = Sign(Claims{Plan: "pro.plus"}, secret)
+	assert.Error(t, err)
+}
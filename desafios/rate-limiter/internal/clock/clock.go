@@ -0,0 +1,54 @@
+// Package clock abstrai a hora atual para que janelas, bloqueios e cotas
+// possam ser testados avançando o tempo em vez de esperar por ele.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock informa a hora atual.
+type Clock interface {
+	Now() time.Time
+}
+
+// System é o relógio do sistema, usado quando nenhum outro é configurado.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake é um relógio que só anda quando Advance ou Set são chamados. Pode ser
+// usado por várias goroutines.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake cria um relógio parado em now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance avança o relógio em d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set move o relógio para now, que pode estar no passado.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
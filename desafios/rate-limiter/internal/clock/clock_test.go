@@ -0,0 +1,26 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	clk := NewFake(start)
+	assert.Equal(t, start, clk.Now())
+
+	clk.Advance(2 * time.Minute)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 1, 0, 0, time.UTC), clk.Now())
+
+	clk.Set(start)
+	assert.Equal(t, start, clk.Now())
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	assert.False(t, now.Before(before))
+}
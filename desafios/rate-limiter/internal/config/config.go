@@ -1,21 +1,66 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	// fusos horários embutidos no binário, já que a imagem final não tem tzdata
+	_ "time/tzdata"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 	"github.com/joho/godotenv"
 )
 
+// Config é a configuração do rate limiter. Tokens guarda o limite de cada chave
+// de API pelo hash da chave ("sha256:<hex>"), nunca pelo valor, e Plans o
+// limite de cada plano das chaves assinadas com um dos KeySecrets. Clock é o
+// relógio das decisões e da validade das chaves (o do sistema quando nil),
+// trocado nos testes. OverrideCacheTTL é por quanto tempo cada instância guarda
+// os overrides lidos do storage (0 lê o storage a cada requisição).
 type Config struct {
-	Redis      RedisConfig
-	IP         RateLimitConfig
-	Token      RateLimitConfig
-	Tokens     map[string]RateLimitConfig
-	ServerPort string
+	Redis                RedisConfig
+	IP                   RateLimitConfig
+	Token                RateLimitConfig
+	Tokens               map[string]RateLimitConfig
+	Plans                map[string]RateLimitConfig
+	KeySecrets           [][]byte
+	IPRanges             []IPRangePolicy
+	Routes               []RoutePolicy
+	ExemptPaths          []string
+	TrustedProxies       []netip.Prefix
+	AllowList            []netip.Prefix
+	DenyList             []netip.Prefix
+	IPv6Prefix           int
+	PolicyFile           string
+	PolicyReloadInterval time.Duration
+	AdminToken           string
+	OverrideCacheTTL     time.Duration
+	LogDenials           bool
+	BlockFactor          float64
+	MaxBlockDuration     time.Duration
+	ViolationDecay       time.Duration
+	ConcurrencyLease     time.Duration
+	Cost                 Cost
+	CostHeader           string
+	QuotaLocation        *time.Location
+	QuotaDSN             string
+	SnapshotInterval     time.Duration
+	FailureMode          FailureMode
+	FallbackScale        float64
+	FallbackMaxKeys      int
+	BreakerFailures      int
+	BreakerProbeInterval time.Duration
+	ServerPort           string
+	Clock                clock.Clock
+
+	// configuração vinda apenas do ambiente, base para recarregar o arquivo
+	env *Config
 }
 
 type RedisConfig struct {
@@ -25,63 +70,654 @@ type RedisConfig struct {
 	DB       int
 }
 
+// RateLimitConfig limita a taxa de requisições e, quando Concurrency é
+// positivo, também o número de requisições simultâneas da mesma chave. Daily e
+// Monthly são cotas de consumo acumulado de um token por dia e por mês do
+// calendário, somadas ao limite de taxa (0 sem cota). Com Shadow, a política
+// é avaliada e registrada, mas nunca nega requisições, para medir o impacto de
+// um limite novo antes de aplicá-lo.
 type RateLimitConfig struct {
 	Requests      int
 	Duration      time.Duration
 	BlockDuration time.Duration
+	Algorithm     Algorithm
+	Concurrency   int
+	Daily         int
+	Monthly       int
+	Shadow        bool
+}
+
+// RoutePolicy limita um padrão de rota, opcionalmente restrito a um método HTTP.
+// O orçamento é independente do global e de outras rotas. Quando Token não é
+// configurado, requisições com token usam o mesmo limite de IP da rota.
+type RoutePolicy struct {
+	Method  string
+	Pattern string
+	IP      RateLimitConfig
+	Token   RateLimitConfig
+	Cost    Cost
+}
+
+// Cost define quanto uma requisição consome do limite: Fixed unidades (1 quando
+// não positivo) mais uma a cada Bytes do corpo, quando Bytes é positivo.
+type Cost struct {
+	Fixed int
+	Bytes int
+}
+
+// Of retorna o custo de uma requisição com corpo de size bytes.
+func (c Cost) Of(size int64) int64 {
+	cost := int64(max(c.Fixed, 1))
+	if c.Bytes > 0 && size > 0 {
+		cost += (size + int64(c.Bytes) - 1) / int64(c.Bytes)
+	}
+	return cost
+}
+
+// IPRangePolicy substitui o limite de IP para endereços dentro do prefixo.
+type IPRangePolicy struct {
+	Prefix netip.Prefix
+	Limit  RateLimitConfig
+}
+
+type Algorithm string
+
+const (
+	AlgorithmFixedWindow   Algorithm = "fixed_window"
+	AlgorithmSlidingLog    Algorithm = "sliding_log"
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	AlgorithmTokenBucket   Algorithm = "token_bucket"
+	AlgorithmGCRA          Algorithm = "gcra"
+)
+
+func (a Algorithm) Valid() bool {
+	switch a {
+	case AlgorithmFixedWindow, AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmTokenBucket, AlgorithmGCRA:
+		return true
+	}
+	return false
+}
+
+// FailureMode define o comportamento quando o storage está indisponível:
+// liberar as requisições, negá-las ou limitar com um MemoryStorage local.
+type FailureMode string
+
+const (
+	FailOpen   FailureMode = "fail_open"
+	FailClosed FailureMode = "fail_closed"
+	FailLocal  FailureMode = "local"
+)
+
+func (m FailureMode) Valid() bool {
+	switch m {
+	case FailOpen, FailClosed, FailLocal:
+		return true
+	}
+	return false
 }
 
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
+	// os erros de leitura das variáveis são juntados e retornados de uma vez
+	var errs []error
+	envInt := func(key string, defaultValue int) int {
+		value, err := getEnvAsInt(key, defaultValue)
+		errs = append(errs, err)
+		return value
+	}
+	envDuration := func(key string, defaultValue time.Duration) time.Duration {
+		value, err := getEnvAsDuration(key, defaultValue)
+		errs = append(errs, err)
+		return value
+	}
+	envBool := func(key string, defaultValue bool) bool {
+		value, err := getEnvAsBool(key, defaultValue)
+		errs = append(errs, err)
+		return value
+	}
+	envFloat := func(key string, defaultValue float64) float64 {
+		value, err := getEnvAsFloat(key, defaultValue)
+		errs = append(errs, err)
+		return value
+	}
+
 	cfg := &Config{
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
+			DB:       envInt("REDIS_DB", 0),
 		},
 		IP: RateLimitConfig{
-			Requests:      getEnvAsInt("RATE_LIMIT_IP_REQUESTS", 5),
-			Duration:      getEnvAsDuration("RATE_LIMIT_IP_DURATION", time.Second),
-			BlockDuration: getEnvAsDuration("RATE_LIMIT_IP_BLOCK_DURATION", 5*time.Minute),
+			Requests:      envInt("RATE_LIMIT_IP_REQUESTS", 5),
+			Duration:      envDuration("RATE_LIMIT_IP_DURATION", time.Second),
+			BlockDuration: envDuration("RATE_LIMIT_IP_BLOCK_DURATION", 5*time.Minute),
+			Algorithm:     Algorithm(getEnv("RATE_LIMIT_IP_ALGORITHM", string(AlgorithmFixedWindow))),
+			Concurrency:   envInt("RATE_LIMIT_IP_CONCURRENCY", 0),
+			Shadow:        envBool("RATE_LIMIT_IP_SHADOW", false),
 		},
 		Token: RateLimitConfig{
-			Requests:      getEnvAsInt("RATE_LIMIT_TOKEN_REQUESTS", 10),
-			Duration:      getEnvAsDuration("RATE_LIMIT_TOKEN_DURATION", time.Second),
-			BlockDuration: getEnvAsDuration("RATE_LIMIT_TOKEN_BLOCK_DURATION", 5*time.Minute),
+			Requests:      envInt("RATE_LIMIT_TOKEN_REQUESTS", 10),
+			Duration:      envDuration("RATE_LIMIT_TOKEN_DURATION", time.Second),
+			BlockDuration: envDuration("RATE_LIMIT_TOKEN_BLOCK_DURATION", 5*time.Minute),
+			Algorithm:     Algorithm(getEnv("RATE_LIMIT_TOKEN_ALGORITHM", string(AlgorithmFixedWindow))),
+			Concurrency:   envInt("RATE_LIMIT_TOKEN_CONCURRENCY", 0),
+			Daily:         envInt("RATE_LIMIT_TOKEN_DAILY_QUOTA", 0),
+			Monthly:       envInt("RATE_LIMIT_TOKEN_MONTHLY_QUOTA", 0),
+			Shadow:        envBool("RATE_LIMIT_TOKEN_SHADOW", false),
 		},
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		Tokens:     make(map[string]RateLimitConfig),
-	}
-
-	tokensConfig := getEnv("RATE_LIMIT_TOKENS", "")
-	if tokensConfig != "" {
-		tokens := strings.Split(tokensConfig, ",")
-		for _, tokenStr := range tokens {
-			parts := strings.Split(strings.TrimSpace(tokenStr), ":")
-			if len(parts) == 4 {
-				token := parts[0]
-				requests, _ := strconv.Atoi(parts[1])
-				duration, _ := time.ParseDuration(parts[2])
-				blockDuration, _ := time.ParseDuration(parts[3])
-
-				cfg.Tokens[token] = RateLimitConfig{
-					Requests:      requests,
-					Duration:      duration,
-					BlockDuration: blockDuration,
+		IPv6Prefix:           envInt("RATE_LIMIT_IPV6_PREFIX", 0),
+		PolicyFile:           getEnv("RATE_LIMIT_POLICY_FILE", ""),
+		PolicyReloadInterval: envDuration("RATE_LIMIT_POLICY_RELOAD_INTERVAL", 5*time.Second),
+		AdminToken:           getEnv("ADMIN_TOKEN", ""),
+		OverrideCacheTTL:     envDuration("RATE_LIMIT_OVERRIDE_CACHE_TTL", time.Second),
+		LogDenials:           envBool("RATE_LIMIT_LOG_DENIALS", false),
+		BlockFactor:          envFloat("RATE_LIMIT_BLOCK_FACTOR", 1),
+		MaxBlockDuration:     envDuration("RATE_LIMIT_MAX_BLOCK_DURATION", 24*time.Hour),
+		ViolationDecay:       envDuration("RATE_LIMIT_VIOLATION_DECAY", 24*time.Hour),
+		ConcurrencyLease:     envDuration("RATE_LIMIT_CONCURRENCY_LEASE", 30*time.Second),
+		Cost:                 Cost{Bytes: envInt("RATE_LIMIT_COST_BYTES", 0)},
+		CostHeader:           getEnv("RATE_LIMIT_COST_HEADER", ""),
+		QuotaDSN:             getEnv("RATE_LIMIT_QUOTA_DSN", ""),
+		SnapshotInterval:     envDuration("RATE_LIMIT_QUOTA_SNAPSHOT_INTERVAL", time.Minute),
+		FailureMode:          FailureMode(getEnv("RATE_LIMIT_FAILURE_MODE", string(FailOpen))),
+		FallbackScale:        envFloat("RATE_LIMIT_FALLBACK_SCALE", 1),
+		FallbackMaxKeys:      envInt("RATE_LIMIT_FALLBACK_MAX_KEYS", 100000),
+		BreakerFailures:      envInt("RATE_LIMIT_BREAKER_FAILURES", 5),
+		BreakerProbeInterval: envDuration("RATE_LIMIT_BREAKER_PROBE_INTERVAL", time.Second),
+		ServerPort:           getEnv("SERVER_PORT", "8080"),
+		Tokens:               make(map[string]RateLimitConfig),
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if !cfg.IP.Algorithm.Valid() {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_IP_ALGORITHM %q", cfg.IP.Algorithm)
+	}
+	if !cfg.Token.Algorithm.Valid() {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_TOKEN_ALGORITHM %q", cfg.Token.Algorithm)
+	}
+
+	if cfg.IP.Concurrency < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_IP_CONCURRENCY %d: must not be negative", cfg.IP.Concurrency)
+	}
+	if cfg.Token.Concurrency < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_TOKEN_CONCURRENCY %d: must not be negative", cfg.Token.Concurrency)
+	}
+	if cfg.BlockFactor < 1 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BLOCK_FACTOR %v: must be at least 1", cfg.BlockFactor)
+	}
+	if cfg.MaxBlockDuration < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_MAX_BLOCK_DURATION %v: must not be negative", cfg.MaxBlockDuration)
+	}
+	if cfg.ViolationDecay <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_VIOLATION_DECAY %v: must be positive", cfg.ViolationDecay)
+	}
+	if cfg.OverrideCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_OVERRIDE_CACHE_TTL %v: must not be negative", cfg.OverrideCacheTTL)
+	}
+	if cfg.ConcurrencyLease <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_CONCURRENCY_LEASE %v: must be positive", cfg.ConcurrencyLease)
+	}
+	if cfg.Cost.Bytes < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_COST_BYTES %d: must not be negative", cfg.Cost.Bytes)
+	}
+	if cfg.Token.Daily < 0 || cfg.Token.Monthly < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_TOKEN_DAILY_QUOTA/RATE_LIMIT_TOKEN_MONTHLY_QUOTA: must not be negative")
+	}
+	timezone := getEnv("RATE_LIMIT_QUOTA_TIMEZONE", "UTC")
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_QUOTA_TIMEZONE %q: %w", timezone, err)
+	}
+	cfg.QuotaLocation = location
+	if cfg.SnapshotInterval <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_QUOTA_SNAPSHOT_INTERVAL %v: must be positive", cfg.SnapshotInterval)
+	}
+
+	tokens, err := parseTokens(getEnv("RATE_LIMIT_TOKENS", ""), cfg.Token.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Tokens = tokens
+
+	plans, err := parsePlans(getEnv("RATE_LIMIT_PLANS", ""), cfg.Token.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Plans = plans
+
+	for _, secret := range strings.Split(getEnv("RATE_LIMIT_KEY_SECRETS", ""), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			cfg.KeySecrets = append(cfg.KeySecrets, []byte(secret))
+		}
+	}
+
+	routes, err := parseRoutes(getEnv("RATE_LIMIT_ROUTES", ""), cfg.IP.Algorithm, cfg.Token.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Routes = routes
+
+	// tokens, planos e rotas do ambiente herdam o limite de concorrência, as
+	// cotas e o custo padrão
+	for _, limits := range []map[string]RateLimitConfig{cfg.Tokens, cfg.Plans} {
+		for name, limit := range limits {
+			limit.Concurrency = cfg.Token.Concurrency
+			limit.Daily = cfg.Token.Daily
+			limit.Monthly = cfg.Token.Monthly
+			limits[name] = limit
+		}
+	}
+	for i := range cfg.Routes {
+		cfg.Routes[i].IP.Concurrency = cfg.IP.Concurrency
+		cfg.Routes[i].Token.Concurrency = cfg.Token.Concurrency
+		cfg.Routes[i].Cost = cfg.Cost
+	}
+
+	for _, path := range strings.Split(getEnv("RATE_LIMIT_EXEMPT_PATHS", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			cfg.ExemptPaths = append(cfg.ExemptPaths, path)
+		}
+	}
+
+	if cfg.TrustedProxies, err = parsePrefixes("RATE_LIMIT_TRUSTED_PROXIES"); err != nil {
+		return nil, err
+	}
+	if cfg.AllowList, err = parsePrefixes("RATE_LIMIT_ALLOWLIST"); err != nil {
+		return nil, err
+	}
+	if cfg.DenyList, err = parsePrefixes("RATE_LIMIT_DENYLIST"); err != nil {
+		return nil, err
+	}
+	if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_IPV6_PREFIX %d: must be between 0 and 128", cfg.IPv6Prefix)
+	}
+
+	if !cfg.FailureMode.Valid() {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_FAILURE_MODE %q", cfg.FailureMode)
+	}
+	if cfg.FallbackScale <= 0 || cfg.FallbackScale > 1 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_FALLBACK_SCALE %v: must be greater than 0 and at most 1", cfg.FallbackScale)
+	}
+	if cfg.FallbackMaxKeys < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_FALLBACK_MAX_KEYS %d: must not be negative", cfg.FallbackMaxKeys)
+	}
+	if cfg.BreakerFailures < 1 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BREAKER_FAILURES %d: must be positive", cfg.BreakerFailures)
+	}
+	if cfg.BreakerProbeInterval <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_BREAKER_PROBE_INTERVAL %v: must be positive", cfg.BreakerProbeInterval)
+	}
+	if cfg.PolicyReloadInterval <= 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_POLICY_RELOAD_INTERVAL %v: must be positive", cfg.PolicyReloadInterval)
+	}
+
+	if cfg.PolicyFile != "" {
+		return LoadPolicyFile(cfg.PolicyFile, cfg)
+	}
+
+	return cfg, nil
+}
+
+// Now retorna a hora atual do Clock configurado.
+func (c *Config) Now() time.Time {
+	if c.Clock == nil {
+		return clock.System.Now()
+	}
+	return c.Clock.Now()
+}
+
+// TokenLimit retorna o ID e o limite de uma chave de API. Chaves assinadas
+// válidas usam o limite do seu plano e as demais são procuradas pelo hash.
+// Chaves desconhecidas, inclusive assinadas com uma assinatura inválida ou
+// expiradas, usam o limite padrão de token.
+func (c *Config) TokenLimit(token string) (string, RateLimitConfig) {
+	if apikey.IsSigned(token) && len(c.KeySecrets) > 0 {
+		if claims, err := apikey.Verify(token, c.KeySecrets, c.Now()); err == nil {
+			return claims.KeyID(), c.planLimit(claims.Plan)
+		}
+	}
+
+	hash := apikey.Hash(token)
+	if limit, exists := c.Tokens[hash]; exists {
+		return apikey.HashID(hash), limit
+	}
+	return apikey.HashID(hash), c.Token
+}
+
+// KeyLimit retorna o limite de uma chave de API pelo seu ID, usado para
+// consultá-la sem conhecer a chave.
+func (c *Config) KeyLimit(id string) RateLimitConfig {
+	if plan, _, signed := strings.Cut(id, "."); signed {
+		return c.planLimit(plan)
+	}
+	for hash, limit := range c.Tokens {
+		if apikey.HashID(hash) == id {
+			return limit
+		}
+	}
+	return c.Token
+}
+
+// planLimit retorna o limite do plano ou, se ele não existir, o padrão de token.
+func (c *Config) planLimit(plan string) RateLimitConfig {
+	if limit, exists := c.Plans[plan]; exists {
+		return limit
+	}
+	return c.Token
+}
+
+// IPLimit retorna o limite do primeiro intervalo que contém o IP ou, se nenhum
+// corresponder, o limite padrão de IP.
+func (c *Config) IPLimit(ip string) RateLimitConfig {
+	if len(c.IPRanges) > 0 {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			addr = addr.Unmap()
+			for _, ipRange := range c.IPRanges {
+				if ipRange.Prefix.Contains(addr) {
+					return ipRange.Limit
 				}
 			}
 		}
 	}
+	return c.IP
+}
 
-	return cfg, nil
+func (c *Config) IsTrustedProxy(addr netip.Addr) bool {
+	return containsAddr(c.TrustedProxies, addr)
+}
+
+// IsAllowed indica se o IP está na allowlist e nunca deve ser limitado.
+func (c *Config) IsAllowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && containsAddr(c.AllowList, addr)
+}
+
+// IsDenied indica se o IP está na denylist e deve ser sempre rejeitado.
+func (c *Config) IsDenied(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && containsAddr(c.DenyList, addr)
+}
+
+// IPKey retorna o identificador usado no contador do IP. Com IPv6Prefix
+// configurado, endereços IPv6 são agregados no prefixo (ex: /64), já que um
+// único cliente costuma controlar a sub-rede inteira.
+func (c *Config) IPKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	addr = addr.Unmap().WithZone("")
+	if addr.Is6() && c.IPv6Prefix > 0 && c.IPv6Prefix < 128 {
+		prefix, _ := addr.Prefix(c.IPv6Prefix)
+		return prefix.String()
+	}
+	return addr.String()
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Route retorna a primeira política de rota que corresponde à requisição.
+func (c *Config) Route(method, path string) (RoutePolicy, bool) {
+	for _, route := range c.Routes {
+		if route.Matches(method, path) {
+			return route, true
+		}
+	}
+	return RoutePolicy{}, false
+}
+
+func (c *Config) IsExempt(path string) bool {
+	for _, pattern := range c.ExemptPaths {
+		if MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RoutePolicy) Matches(method, path string) bool {
+	if p.Method != "" && !strings.EqualFold(p.Method, method) {
+		return false
+	}
+	return MatchPath(p.Pattern, path)
+}
+
+func (p RoutePolicy) Name() string {
+	method := p.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + p.Pattern
+}
+
+// MatchPath compara um caminho com um padrão no estilo do chi: segmentos
+// {param} aceitam qualquer valor e um "*" final aceita qualquer sufixo.
+func MatchPath(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
 }
 
 func (c *RedisConfig) Address() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+// parseTokens lê entradas no formato "token:requests:duration:block[:algorithm]"
+// separadas por vírgula. O token pode ser a chave ou o seu hash
+// ("sha256:<hex>") e é guardado sempre pelo hash.
+func parseTokens(value string, algorithm Algorithm) (map[string]RateLimitConfig, error) {
+	return parseNamedLimits("RATE_LIMIT_TOKENS", "token", value, algorithm, tokenHash)
+}
+
+// parsePlans lê os planos das chaves assinadas no formato
+// "plan:requests:duration:block[:algorithm]", separados por vírgula.
+func parsePlans(value string, algorithm Algorithm) (map[string]RateLimitConfig, error) {
+	return parseNamedLimits("RATE_LIMIT_PLANS", "plan", value, algorithm, func(plan string) string {
+		if strings.Contains(plan, ".") {
+			return ""
+		}
+		return plan
+	})
+}
+
+func parseNamedLimits(env, kind, value string, algorithm Algorithm, normalize func(string) string) (map[string]RateLimitConfig, error) {
+	limits := make(map[string]RateLimitConfig)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, spec, found := strings.Cut(entry, ":")
+		// o hash tem o próprio separador, "sha256:<hex>"
+		if digest, rest, hashed := strings.Cut(spec, ":"); name == "sha256" && hashed {
+			name, spec = name+":"+digest, rest
+		}
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid %s entry %d: missing %s", env, len(limits)+1, kind)
+		}
+		if name = normalize(name); name == "" {
+			return nil, fmt.Errorf("invalid %s entry %d: invalid %s", env, len(limits)+1, kind)
+		}
+
+		limit, err := parseLimit(spec, algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %d: %w", env, len(limits)+1, err)
+		}
+		if _, exists := limits[name]; exists {
+			return nil, fmt.Errorf("invalid %s entry %d: duplicated %s", env, len(limits)+1, kind)
+		}
+
+		limits[name] = limit
+	}
+
+	return limits, nil
+}
+
+// tokenHash retorna a chave de Tokens de um token: o hash do token, ou o
+// próprio token quando ele já é um hash.
+func tokenHash(token string) string {
+	if apikey.IsHash(token) {
+		return strings.ToLower(token)
+	}
+	return apikey.Hash(token)
+}
+
+// parseRoutes lê entradas no formato
+// "METHOD /pattern=requests:duration:block[:algorithm][;requests:duration:block[:algorithm]]",
+// separadas por vírgula, onde a segunda especificação é o limite para tokens.
+func parseRoutes(value string, ipAlgorithm, tokenAlgorithm Algorithm) ([]RoutePolicy, error) {
+	var routes []RoutePolicy
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, specs, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid route policy %q: missing limit", entry)
+		}
+
+		policy := RoutePolicy{Pattern: strings.TrimSpace(route)}
+		if method, pattern, found := strings.Cut(policy.Pattern, " "); found {
+			policy.Method = strings.ToUpper(method)
+			policy.Pattern = strings.TrimSpace(pattern)
+		}
+		if !strings.HasPrefix(policy.Pattern, "/") {
+			return nil, fmt.Errorf("invalid route policy %q: pattern must start with /", entry)
+		}
+
+		ipSpec, tokenSpec, hasToken := strings.Cut(specs, ";")
+
+		var err error
+		if policy.IP, err = parseLimit(ipSpec, ipAlgorithm); err != nil {
+			return nil, fmt.Errorf("invalid route policy %q: %w", entry, err)
+		}
+		policy.Token = policy.IP
+		if hasToken {
+			if policy.Token, err = parseLimit(tokenSpec, tokenAlgorithm); err != nil {
+				return nil, fmt.Errorf("invalid route policy %q: %w", entry, err)
+			}
+		}
+
+		routes = append(routes, policy)
+	}
+
+	return routes, nil
+}
+
+// parseLimit lê um limite no formato requests:duration:block_duration, seguido
+// opcionalmente do algoritmo e de "shadow".
+func parseLimit(spec string, algorithm Algorithm) (RateLimitConfig, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	shadow := len(parts) > 3 && parts[len(parts)-1] == "shadow"
+	if shadow {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) != 3 && len(parts) != 4 {
+		return RateLimitConfig{}, fmt.Errorf("expected requests:duration:block_duration[:algorithm][:shadow], got %q", spec)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return RateLimitConfig{}, fmt.Errorf("invalid requests %q", parts[0])
+	}
+	duration, err := time.ParseDuration(parts[1])
+	if err != nil || duration <= 0 {
+		return RateLimitConfig{}, fmt.Errorf("invalid duration %q", parts[1])
+	}
+	blockDuration, err := time.ParseDuration(parts[2])
+	if err != nil || blockDuration < 0 {
+		return RateLimitConfig{}, fmt.Errorf("invalid block duration %q", parts[2])
+	}
+
+	limit := RateLimitConfig{
+		Requests:      requests,
+		Duration:      duration,
+		BlockDuration: blockDuration,
+		Algorithm:     algorithm,
+		Shadow:        shadow,
+	}
+	if len(parts) == 4 {
+		limit.Algorithm = Algorithm(parts[3])
+		if !limit.Algorithm.Valid() {
+			return RateLimitConfig{}, fmt.Errorf("invalid algorithm %q", parts[3])
+		}
+	}
+
+	return limit, nil
+}
+
+// parsePrefixes lê uma lista de CIDRs separados por vírgula. Endereços sem
+// máscara são tratados como um único host.
+func parsePrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q", key, value)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -89,18 +725,52 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
+// getEnvAsInt e as funções seguintes retornam defaultValue só quando a
+// variável não está definida; um valor que não pode ser lido é um erro.
+func getEnvAsInt(key string, defaultValue int) (int, error) {
 	valueStr := getEnv(key, "")
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
+	if valueStr == "" {
+		return defaultValue, nil
 	}
-	return defaultValue
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be an integer", key, valueStr)
+	}
+	return value, nil
 }
 
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
 	valueStr := getEnv(key, "")
-	if value, err := time.ParseDuration(valueStr); err == nil {
-		return value
+	if valueStr == "" {
+		return defaultValue, nil
 	}
-	return defaultValue
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a duration", key, valueStr)
+	}
+	return value, nil
+}
+
+func getEnvAsBool(key string, defaultValue bool) (bool, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be a boolean", key, valueStr)
+	}
+	return value, nil
+}
+
+func getEnvAsFloat(key string, defaultValue float64) (float64, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a number", key, valueStr)
+	}
+	return value, nil
 }
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"net/netip"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,10 +21,433 @@ func TestLoad_WithDefaults(t *testing.T) {
 	assert.Equal(t, time.Second, cfg.IP.Duration)
 	assert.Equal(t, 5*time.Minute, cfg.IP.BlockDuration)
 	assert.Equal(t, 10, cfg.Token.Requests)
+	assert.Equal(t, FailOpen, cfg.FailureMode)
+	assert.Equal(t, 1.0, cfg.FallbackScale)
+	assert.Equal(t, 5, cfg.BreakerFailures)
+	assert.Equal(t, time.Second, cfg.BreakerProbeInterval)
 	assert.Equal(t, "8080", cfg.ServerPort)
 }
 
+func TestLoad_InvalidEnvValues(t *testing.T) {
+	tests := map[string]string{
+		"RATE_LIMIT_IP_REQUESTS":            "abc",
+		"REDIS_DB":                          "1.5",
+		"RATE_LIMIT_BREAKER_PROBE_INTERVAL": "1x",
+		"RATE_LIMIT_TOKEN_SHADOW":           "sim",
+		"RATE_LIMIT_FALLBACK_SCALE":         "half",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+
+		_, err := Load()
+		require.Error(t, err, key)
+		assert.Contains(t, err.Error(), key)
+	}
+}
+
 func TestRedisConfig_Address(t *testing.T) {
 	cfg := RedisConfig{Host: "localhost", Port: "6379"}
 	assert.Equal(t, "localhost:6379", cfg.Address())
 }
+
+func TestLoad_Algorithms(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_IP_ALGORITHM", "sliding_log")
+	os.Setenv("RATE_LIMIT_TOKENS", "abc123:100:1s:10m,xyz789:50:1s:3m:gcra")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmSlidingLog, cfg.IP.Algorithm)
+	assert.Equal(t, AlgorithmFixedWindow, cfg.Token.Algorithm)
+	assert.Equal(t, AlgorithmFixedWindow, cfg.Tokens[apikey.Hash("abc123")].Algorithm)
+	assert.Equal(t, AlgorithmGCRA, cfg.Tokens[apikey.Hash("xyz789")].Algorithm)
+}
+
+func TestLoad_HashedTokensAndPlans(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_TOKENS", "abc123:100:1s:10m,"+apikey.Hash("xyz789")+":50:1s:3m:gcra")
+	os.Setenv("RATE_LIMIT_PLANS", "free:10:1s:1m,pro:1000:1m:1m:token_bucket")
+	os.Setenv("RATE_LIMIT_KEY_SECRETS", "next, current")
+	os.Setenv("RATE_LIMIT_TOKEN_DAILY_QUOTA", "500")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]RateLimitConfig{
+		apikey.Hash("abc123"): {Requests: 100, Duration: time.Second, BlockDuration: 10 * time.Minute, Algorithm: AlgorithmFixedWindow, Daily: 500},
+		apikey.Hash("xyz789"): {Requests: 50, Duration: time.Second, BlockDuration: 3 * time.Minute, Algorithm: AlgorithmGCRA, Daily: 500},
+	}, cfg.Tokens, "tokens are kept only by their hash")
+	assert.Equal(t, AlgorithmTokenBucket, cfg.Plans["pro"].Algorithm)
+	assert.Equal(t, 500, cfg.Plans["pro"].Daily, "plans inherit the default quotas")
+	assert.Equal(t, [][]byte{[]byte("next"), []byte("current")}, cfg.KeySecrets)
+
+	tests := map[string]string{
+		"RATE_LIMIT_TOKENS": "abc123:100:1s:10m," + apikey.Hash("abc123") + ":5:1s:1m",
+		"RATE_LIMIT_PLANS":  "pro.plus:10:1s:1m",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestConfig_TokenLimit(t *testing.T) {
+	secret := []byte("current")
+	cfg := &Config{
+		Token:      RateLimitConfig{Requests: 10, Duration: time.Second},
+		Tokens:     map[string]RateLimitConfig{apikey.Hash("abc123"): {Requests: 100, Duration: time.Second}},
+		Plans:      map[string]RateLimitConfig{"pro": {Requests: 1000, Duration: time.Minute}},
+		KeySecrets: [][]byte{[]byte("next"), secret},
+	}
+
+	id, limit := cfg.TokenLimit("abc123")
+	assert.Equal(t, "6ca13d52ca70c883", id)
+	assert.Equal(t, 100, limit.Requests)
+	assert.Equal(t, 100, cfg.KeyLimit(id).Requests)
+
+	id, limit = cfg.TokenLimit("unknown")
+	assert.Equal(t, apikey.HashID(apikey.Hash("unknown")), id)
+	assert.Equal(t, 10, limit.Requests)
+
+	signed, err := apikey.Sign(apikey.Claims{ID: "k1", Plan: "pro"}, secret)
+	require.NoError(t, err)
+	id, limit = cfg.TokenLimit(signed)
+	assert.Equal(t, "pro.k1", id)
+	assert.Equal(t, 1000, limit.Requests, "signed keys use their plan without being configured")
+	assert.Equal(t, 1000, cfg.KeyLimit(id).Requests)
+
+	unknownPlan, err := apikey.Sign(apikey.Claims{ID: "k2", Plan: "enterprise"}, secret)
+	require.NoError(t, err)
+	id, limit = cfg.TokenLimit(unknownPlan)
+	assert.Equal(t, "enterprise.k2", id)
+	assert.Equal(t, 10, limit.Requests)
+
+	forged, err := apikey.Sign(apikey.Claims{ID: "k3", Plan: "pro"}, []byte("attacker"))
+	require.NoError(t, err)
+	id, limit = cfg.TokenLimit(forged)
+	assert.Equal(t, apikey.HashID(apikey.Hash(forged)), id, "invalid signed keys are unknown tokens")
+	assert.Equal(t, 10, limit.Requests)
+}
+
+func TestLoad_InvalidAlgorithm(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_TOKEN_ALGORITHM", "leaky")
+
+	_, err := Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_Routes(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_ROUTES", "POST /api/data=2:1s:1m, GET /api/info=20:1s:5m:sliding_log;100:1s:1m, /users/{id}=3:1s:0s")
+	os.Setenv("RATE_LIMIT_EXEMPT_PATHS", "/health, /static/*")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Len(t, cfg.Routes, 3)
+
+	assert.Equal(t, RoutePolicy{
+		Method:  "POST",
+		Pattern: "/api/data",
+		IP:      RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: time.Minute, Algorithm: AlgorithmFixedWindow},
+		Token:   RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: time.Minute, Algorithm: AlgorithmFixedWindow},
+	}, cfg.Routes[0])
+	assert.Equal(t, AlgorithmSlidingLog, cfg.Routes[1].IP.Algorithm)
+	assert.Equal(t, 100, cfg.Routes[1].Token.Requests)
+	assert.Equal(t, "", cfg.Routes[2].Method)
+	assert.Equal(t, "* /users/{id}", cfg.Routes[2].Name())
+
+	route, found := cfg.Route("post", "/api/data")
+	assert.True(t, found)
+	assert.Equal(t, "POST /api/data", route.Name())
+
+	_, found = cfg.Route("GET", "/api/data")
+	assert.False(t, found)
+
+	assert.Equal(t, []string{"/health", "/static/*"}, cfg.ExemptPaths)
+	assert.True(t, cfg.IsExempt("/health"))
+	assert.True(t, cfg.IsExempt("/static/css/app.css"))
+	assert.False(t, cfg.IsExempt("/api/info"))
+}
+
+func TestLoad_InvalidRoutes(t *testing.T) {
+	tests := []string{
+		"POST /api/data",
+		"POST api/data=2:1s:1m",
+		"POST /api/data=two:1s:1m",
+		"POST /api/data=2:1s",
+		"POST /api/data=2:1s:1m:leaky",
+		"POST /api/data=2:1s:1m;5:x:1m",
+	}
+
+	for _, routes := range tests {
+		os.Clearenv()
+		os.Setenv("RATE_LIMIT_ROUTES", routes)
+
+		_, err := Load()
+		assert.Error(t, err, routes)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/", "/", true},
+		{"/", "/api", false},
+		{"/api/data", "/api/data", true},
+		{"/api/data", "/api/data/", true},
+		{"/api/data", "/api/data/1", false},
+		{"/users/{id}", "/users/42", true},
+		{"/users/{id}", "/users", false},
+		{"/users/{id}/orders", "/users/42/orders", true},
+		{"/static/*", "/static/js/app.js", true},
+		{"/static/*", "/other/app.js", false},
+		{"/*", "/anything/at/all", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, MatchPath(tt.pattern, tt.path), "%s %s", tt.pattern, tt.path)
+	}
+}
+
+func TestLoad_IPLists(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_TRUSTED_PROXIES", "10.0.0.0/8, fd00::/8")
+	os.Setenv("RATE_LIMIT_ALLOWLIST", "192.168.0.10")
+	os.Setenv("RATE_LIMIT_DENYLIST", "203.0.113.0/24,2001:db8::1")
+	os.Setenv("RATE_LIMIT_IPV6_PREFIX", "64")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.True(t, cfg.IsTrustedProxy(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, cfg.IsTrustedProxy(netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.False(t, cfg.IsTrustedProxy(netip.MustParseAddr("192.168.0.10")))
+
+	assert.True(t, cfg.IsAllowed("192.168.0.10"))
+	assert.False(t, cfg.IsAllowed("192.168.0.11"))
+
+	assert.True(t, cfg.IsDenied("203.0.113.99"))
+	assert.True(t, cfg.IsDenied("2001:db8::1"))
+	assert.False(t, cfg.IsDenied("2001:db8::2"))
+
+	assert.Equal(t, "2001:db8:1:2::/64", cfg.IPKey("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "192.168.0.10", cfg.IPKey("::ffff:192.168.0.10"))
+	assert.Equal(t, "not-an-ip", cfg.IPKey("not-an-ip"))
+}
+
+func TestLoad_InvalidIPLists(t *testing.T) {
+	tests := map[string]string{
+		"RATE_LIMIT_TRUSTED_PROXIES": "10.0.0.0/33",
+		"RATE_LIMIT_ALLOWLIST":       "localhost",
+		"RATE_LIMIT_DENYLIST":        "1.2.3",
+		"RATE_LIMIT_IPV6_PREFIX":     "129",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestLoad_BlockEscalation(t *testing.T) {
+	os.Clearenv()
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, cfg.BlockFactor, "escalation is disabled by default")
+
+	os.Setenv("RATE_LIMIT_BLOCK_FACTOR", "6")
+	os.Setenv("RATE_LIMIT_MAX_BLOCK_DURATION", "24h")
+	os.Setenv("RATE_LIMIT_VIOLATION_DECAY", "1h")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, cfg.BlockFactor)
+	assert.Equal(t, 24*time.Hour, cfg.MaxBlockDuration)
+	assert.Equal(t, time.Hour, cfg.ViolationDecay)
+
+	tests := map[string]string{
+		"RATE_LIMIT_BLOCK_FACTOR":       "0.5",
+		"RATE_LIMIT_MAX_BLOCK_DURATION": "-1h",
+		"RATE_LIMIT_VIOLATION_DECAY":    "0s",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestLoad_FailureMode(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_FAILURE_MODE", "local")
+	os.Setenv("RATE_LIMIT_FALLBACK_SCALE", "0.25")
+	os.Setenv("RATE_LIMIT_FALLBACK_MAX_KEYS", "5000")
+	os.Setenv("RATE_LIMIT_BREAKER_FAILURES", "3")
+	os.Setenv("RATE_LIMIT_BREAKER_PROBE_INTERVAL", "500ms")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, FailLocal, cfg.FailureMode)
+	assert.Equal(t, 0.25, cfg.FallbackScale)
+	assert.Equal(t, 5000, cfg.FallbackMaxKeys)
+	assert.Equal(t, 3, cfg.BreakerFailures)
+	assert.Equal(t, 500*time.Millisecond, cfg.BreakerProbeInterval)
+
+	tests := map[string]string{
+		"RATE_LIMIT_FAILURE_MODE":           "ignore",
+		"RATE_LIMIT_FALLBACK_SCALE":         "1.5",
+		"RATE_LIMIT_FALLBACK_MAX_KEYS":      "-1",
+		"RATE_LIMIT_BREAKER_FAILURES":       "0",
+		"RATE_LIMIT_BREAKER_PROBE_INTERVAL": "-1s",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestLoad_Concurrency(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_IP_CONCURRENCY", "2")
+	os.Setenv("RATE_LIMIT_TOKEN_CONCURRENCY", "8")
+	os.Setenv("RATE_LIMIT_CONCURRENCY_LEASE", "10s")
+	os.Setenv("RATE_LIMIT_TOKENS", "abc123:100:1s:10m")
+	os.Setenv("RATE_LIMIT_ROUTES", "POST /api/data=2:1s:1m")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.IP.Concurrency)
+	assert.Equal(t, 8, cfg.Token.Concurrency)
+	assert.Equal(t, 10*time.Second, cfg.ConcurrencyLease)
+	assert.Equal(t, 8, cfg.Tokens[apikey.Hash("abc123")].Concurrency)
+	require.Len(t, cfg.Routes, 1)
+	assert.Equal(t, 2, cfg.Routes[0].IP.Concurrency)
+	assert.Equal(t, 8, cfg.Routes[0].Token.Concurrency)
+
+	tests := map[string]string{
+		"RATE_LIMIT_IP_CONCURRENCY":    "-1",
+		"RATE_LIMIT_TOKEN_CONCURRENCY": "-1",
+		"RATE_LIMIT_CONCURRENCY_LEASE": "0s",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestCost_Of(t *testing.T) {
+	assert.Equal(t, int64(1), Cost{}.Of(0))
+	assert.Equal(t, int64(1), Cost{}.Of(5000))
+	assert.Equal(t, int64(50), Cost{Fixed: 50}.Of(0))
+	assert.Equal(t, int64(1), Cost{Bytes: 1024}.Of(0))
+	assert.Equal(t, int64(2), Cost{Bytes: 1024}.Of(1))
+	assert.Equal(t, int64(4), Cost{Fixed: 2, Bytes: 1024}.Of(2048))
+}
+
+func TestLoad_Quotas(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_TOKEN_DAILY_QUOTA", "1000")
+	os.Setenv("RATE_LIMIT_TOKEN_MONTHLY_QUOTA", "20000")
+	os.Setenv("RATE_LIMIT_QUOTA_TIMEZONE", "America/Sao_Paulo")
+	os.Setenv("RATE_LIMIT_QUOTA_DSN", "postgres://localhost/ratelimit")
+	os.Setenv("RATE_LIMIT_QUOTA_SNAPSHOT_INTERVAL", "30s")
+	os.Setenv("RATE_LIMIT_TOKENS", "abc123:100:1s:10m")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 1000, cfg.Token.Daily)
+	assert.Equal(t, 20000, cfg.Token.Monthly)
+	assert.Equal(t, 1000, cfg.Tokens[apikey.Hash("abc123")].Daily)
+	assert.Equal(t, 20000, cfg.Tokens[apikey.Hash("abc123")].Monthly)
+	assert.Equal(t, "America/Sao_Paulo", cfg.QuotaLocation.String())
+	assert.Equal(t, "postgres://localhost/ratelimit", cfg.QuotaDSN)
+	assert.Equal(t, 30*time.Second, cfg.SnapshotInterval)
+
+	os.Clearenv()
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, cfg.QuotaLocation)
+	assert.Equal(t, time.Minute, cfg.SnapshotInterval)
+
+	tests := map[string]string{
+		"RATE_LIMIT_TOKEN_DAILY_QUOTA":       "-1",
+		"RATE_LIMIT_TOKEN_MONTHLY_QUOTA":     "-1",
+		"RATE_LIMIT_QUOTA_TIMEZONE":          "Mars/Olympus",
+		"RATE_LIMIT_QUOTA_SNAPSHOT_INTERVAL": "0s",
+	}
+
+	for key, value := range tests {
+		os.Clearenv()
+		os.Setenv(key, value)
+
+		_, err := Load()
+		assert.Error(t, err, key)
+	}
+}
+
+func TestLoad_Cost(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_COST_BYTES", "4096")
+	os.Setenv("RATE_LIMIT_COST_HEADER", "X-RateLimit-Cost")
+	os.Setenv("RATE_LIMIT_ROUTES", "POST /api/data=2:1s:1m")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Cost{Bytes: 4096}, cfg.Cost)
+	assert.Equal(t, "X-RateLimit-Cost", cfg.CostHeader)
+	require.Len(t, cfg.Routes, 1)
+	assert.Equal(t, Cost{Bytes: 4096}, cfg.Routes[0].Cost)
+
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_COST_BYTES", "-1")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoad_Shadow(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_IP_SHADOW", "true")
+	os.Setenv("RATE_LIMIT_TOKENS", "abc123:100:1s:10m:shadow,def456:50:1s:1m:gcra:shadow,ghi789:10:1s:1m")
+	os.Setenv("RATE_LIMIT_ROUTES", "POST /api/data=2:1s:1m:shadow")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.IP.Shadow)
+	assert.False(t, cfg.Token.Shadow)
+	assert.True(t, cfg.Tokens[apikey.Hash("abc123")].Shadow)
+	assert.True(t, cfg.Tokens[apikey.Hash("def456")].Shadow)
+	assert.Equal(t, AlgorithmGCRA, cfg.Tokens[apikey.Hash("def456")].Algorithm)
+	assert.False(t, cfg.Tokens[apikey.Hash("ghi789")].Shadow)
+	require.Len(t, cfg.Routes, 1)
+	assert.True(t, cfg.Routes[0].IP.Shadow)
+
+	for _, spec := range []string{"abc123:100:1s:10m:gcra:shadow:x", "abc123:100:1s:shadow"} {
+		os.Clearenv()
+		os.Setenv("RATE_LIMIT_TOKENS", spec)
+
+		_, err := Load()
+		assert.Error(t, err, spec)
+	}
+}
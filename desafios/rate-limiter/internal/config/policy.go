@@ -0,0 +1,456 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"gopkg.in/yaml.v3"
+)
+
+// policyFile é o formato do arquivo de políticas. Seções ausentes mantêm os
+// valores vindos das variáveis de ambiente.
+//
+//	ip: {requests: 5, duration: 1s, block_duration: 5m, algorithm: sliding_window}
+//	token: {requests: 10, duration: 1s, block_duration: 5m}
+//	tokens:
+//	  abc123: {requests: 100, duration: 1s, block_duration: 10m, daily: 10000}
+//	  sha256:6ca13d52...: {requests: 50, duration: 1s}
+//	plans:
+//	  pro: {requests: 1000, duration: 1m, monthly: 1000000}
+//	ip_ranges:
+//	  - cidr: 10.0.0.0/8
+//	    limit: {requests: 100, duration: 1s, block_duration: 1m}
+//	routes:
+//	  - method: POST
+//	    pattern: /api/data
+//	    ip: {requests: 2, duration: 1s, block_duration: 1m}
+//	    token: {requests: 20, duration: 1s, block_duration: 1m}
+//	    cost: 5
+//	  - pattern: /api/search
+//	    ip: {requests: 1, duration: 1s, shadow: true}
+//	exempt_paths: [/health]
+//	trusted_proxies: [10.0.0.0/8]
+//	allowlist: [192.168.0.10]
+//	denylist: [203.0.113.0/24]
+type policyFile struct {
+	IP             *limitSpec           `yaml:"ip"`
+	Token          *limitSpec           `yaml:"token"`
+	Tokens         map[string]limitSpec `yaml:"tokens"`
+	Plans          map[string]limitSpec `yaml:"plans"`
+	IPRanges       []ipRangeSpec        `yaml:"ip_ranges"`
+	Routes         []routeSpec          `yaml:"routes"`
+	ExemptPaths    []pathSpec           `yaml:"exempt_paths"`
+	TrustedProxies []prefixSpec         `yaml:"trusted_proxies"`
+	AllowList      []prefixSpec         `yaml:"allowlist"`
+	DenyList       []prefixSpec         `yaml:"denylist"`
+}
+
+type limitSpec struct {
+	Requests      *int      `yaml:"requests"`
+	Duration      *duration `yaml:"duration"`
+	BlockDuration duration  `yaml:"block_duration"`
+	Concurrency   int       `yaml:"concurrency"`
+	Daily         int       `yaml:"daily"`
+	Monthly       int       `yaml:"monthly"`
+	Algorithm     Algorithm `yaml:"algorithm"`
+	Shadow        bool      `yaml:"shadow"`
+}
+
+type ipRangeSpec struct {
+	CIDR  netip.Prefix
+	Limit limitSpec
+}
+
+type routeSpec struct {
+	Method    string
+	Pattern   string
+	IP        limitSpec
+	Token     *limitSpec
+	Cost      *int
+	CostBytes *int
+}
+
+type pathSpec string
+
+type prefixSpec netip.Prefix
+
+type duration time.Duration
+
+var httpMethod = regexp.MustCompile(`^[A-Z]+$`)
+
+// LoadPolicyFile lê o arquivo de políticas (YAML ou JSON) e retorna uma cópia de
+// base com as seções do arquivo aplicadas. Erros indicam a linha do problema.
+func LoadPolicyFile(path string, base *Config) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	cfg, err := parsePolicy(data, filepath.Ext(path), base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func parsePolicy(data []byte, ext string, base *Config) (*Config, error) {
+	if strings.EqualFold(ext, ".json") {
+		// JSON é um subconjunto de YAML, exceto pela indentação com tabs
+		data = expandIndentTabs(data)
+	}
+
+	var file policyFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	cfg := *base
+	cfg.env = base.envConfig()
+
+	if file.IP != nil {
+		cfg.IP = file.IP.limit(cfg.IP.Algorithm)
+	}
+	if file.Token != nil {
+		cfg.Token = file.Token.limit(cfg.Token.Algorithm)
+	}
+	if file.Tokens != nil {
+		cfg.Tokens = make(map[string]RateLimitConfig, len(file.Tokens))
+		for token, spec := range file.Tokens {
+			hash := tokenHash(token)
+			if _, exists := cfg.Tokens[hash]; exists {
+				return nil, fmt.Errorf("token %s is configured twice, as the key and as its hash", apikey.HashID(hash))
+			}
+			cfg.Tokens[hash] = spec.limit(cfg.Token.Algorithm)
+		}
+	}
+	if file.Plans != nil {
+		cfg.Plans = make(map[string]RateLimitConfig, len(file.Plans))
+		for plan, spec := range file.Plans {
+			if strings.Contains(plan, ".") {
+				return nil, fmt.Errorf("invalid plan %q: must not contain a dot", plan)
+			}
+			cfg.Plans[plan] = spec.limit(cfg.Token.Algorithm)
+		}
+	}
+	if file.IPRanges != nil {
+		cfg.IPRanges = make([]IPRangePolicy, 0, len(file.IPRanges))
+		for _, spec := range file.IPRanges {
+			cfg.IPRanges = append(cfg.IPRanges, IPRangePolicy{
+				Prefix: spec.CIDR,
+				Limit:  spec.Limit.limit(cfg.IP.Algorithm),
+			})
+		}
+	}
+	if file.Routes != nil {
+		cfg.Routes = make([]RoutePolicy, 0, len(file.Routes))
+		for _, spec := range file.Routes {
+			route := RoutePolicy{
+				Method:  spec.Method,
+				Pattern: spec.Pattern,
+				IP:      spec.IP.limit(cfg.IP.Algorithm),
+			}
+			route.Token = route.IP
+			if spec.Token != nil {
+				route.Token = spec.Token.limit(cfg.Token.Algorithm)
+			}
+			route.Cost = cfg.Cost
+			if spec.Cost != nil {
+				route.Cost.Fixed = *spec.Cost
+			}
+			if spec.CostBytes != nil {
+				route.Cost.Bytes = *spec.CostBytes
+			}
+			cfg.Routes = append(cfg.Routes, route)
+		}
+	}
+	if file.ExemptPaths != nil {
+		cfg.ExemptPaths = make([]string, 0, len(file.ExemptPaths))
+		for _, path := range file.ExemptPaths {
+			cfg.ExemptPaths = append(cfg.ExemptPaths, string(path))
+		}
+	}
+	if file.TrustedProxies != nil {
+		cfg.TrustedProxies = prefixes(file.TrustedProxies)
+	}
+	if file.AllowList != nil {
+		cfg.AllowList = prefixes(file.AllowList)
+	}
+	if file.DenyList != nil {
+		cfg.DenyList = prefixes(file.DenyList)
+	}
+
+	return &cfg, nil
+}
+
+// WatchPolicyFile verifica o arquivo a cada intervalo e chama onChange com a
+// nova configuração quando o conteúdo muda. O arquivo é sempre aplicado sobre a
+// configuração das variáveis de ambiente, então remover uma seção volta ao valor
+// do ambiente. Se o arquivo for inválido, onError é chamado e a configuração em
+// uso é mantida. Bloqueia até ctx ser cancelado.
+func WatchPolicyFile(ctx context.Context, cfg *Config, onChange func(*Config), onError func(error)) {
+	base := cfg.envConfig()
+
+	ticker := time.NewTicker(base.PolicyReloadInterval)
+	defer ticker.Stop()
+
+	var lastSum [sha256.Size]byte
+	if data, err := os.ReadFile(base.PolicyFile); err == nil {
+		lastSum = sha256.Sum256(data)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			data, err := os.ReadFile(base.PolicyFile)
+			if err != nil {
+				onError(fmt.Errorf("failed to read policy file: %w", err))
+				continue
+			}
+
+			sum := sha256.Sum256(data)
+			if sum == lastSum {
+				continue
+			}
+			lastSum = sum
+
+			cfg, err := parsePolicy(data, filepath.Ext(base.PolicyFile), base)
+			if err != nil {
+				onError(fmt.Errorf("%s: %w", base.PolicyFile, err))
+				continue
+			}
+			onChange(cfg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Config) envConfig() *Config {
+	if c.env != nil {
+		return c.env
+	}
+	return c
+}
+
+func (l limitSpec) limit(algorithm Algorithm) RateLimitConfig {
+	if l.Algorithm != "" {
+		algorithm = l.Algorithm
+	}
+	return RateLimitConfig{
+		Requests:      *l.Requests,
+		Duration:      time.Duration(*l.Duration),
+		BlockDuration: time.Duration(l.BlockDuration),
+		Algorithm:     algorithm,
+		Concurrency:   l.Concurrency,
+		Daily:         l.Daily,
+		Monthly:       l.Monthly,
+		Shadow:        l.Shadow,
+	}
+}
+
+func (l *limitSpec) UnmarshalYAML(node *yaml.Node) error {
+	if err := checkFields(node, "requests", "duration", "block_duration", "algorithm", "concurrency", "daily", "monthly", "shadow"); err != nil {
+		return err
+	}
+
+	type plain limitSpec
+	if err := node.Decode((*plain)(l)); err != nil {
+		return err
+	}
+
+	if l.Requests == nil {
+		return fmt.Errorf("line %d: requests is required", node.Line)
+	}
+	if *l.Requests < 0 {
+		return fmt.Errorf("line %d: requests must not be negative", fieldLine(node, "requests"))
+	}
+	if l.Duration == nil {
+		return fmt.Errorf("line %d: duration is required", node.Line)
+	}
+	if *l.Duration <= 0 {
+		return fmt.Errorf("line %d: duration must be positive", fieldLine(node, "duration"))
+	}
+	if l.BlockDuration < 0 {
+		return fmt.Errorf("line %d: block_duration must not be negative", fieldLine(node, "block_duration"))
+	}
+	if l.Concurrency < 0 {
+		return fmt.Errorf("line %d: concurrency must not be negative", fieldLine(node, "concurrency"))
+	}
+	if l.Daily < 0 {
+		return fmt.Errorf("line %d: daily must not be negative", fieldLine(node, "daily"))
+	}
+	if l.Monthly < 0 {
+		return fmt.Errorf("line %d: monthly must not be negative", fieldLine(node, "monthly"))
+	}
+	return nil
+}
+
+func (r *ipRangeSpec) UnmarshalYAML(node *yaml.Node) error {
+	if err := checkFields(node, "cidr", "limit"); err != nil {
+		return err
+	}
+
+	var raw struct {
+		CIDR  *string    `yaml:"cidr"`
+		Limit *limitSpec `yaml:"limit"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	if raw.CIDR == nil {
+		return fmt.Errorf("line %d: cidr is required", node.Line)
+	}
+	prefix, err := netip.ParsePrefix(*raw.CIDR)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid cidr %q", fieldLine(node, "cidr"), *raw.CIDR)
+	}
+	if raw.Limit == nil {
+		return fmt.Errorf("line %d: limit is required", node.Line)
+	}
+
+	r.CIDR = prefix.Masked()
+	r.Limit = *raw.Limit
+	return nil
+}
+
+func (r *routeSpec) UnmarshalYAML(node *yaml.Node) error {
+	if err := checkFields(node, "method", "pattern", "ip", "token", "cost", "cost_bytes"); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Method    string     `yaml:"method"`
+		Pattern   string     `yaml:"pattern"`
+		IP        *limitSpec `yaml:"ip"`
+		Token     *limitSpec `yaml:"token"`
+		Cost      *int       `yaml:"cost"`
+		CostBytes *int       `yaml:"cost_bytes"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	raw.Method = strings.ToUpper(raw.Method)
+	if raw.Method != "" && !httpMethod.MatchString(raw.Method) {
+		return fmt.Errorf("line %d: invalid method %q", fieldLine(node, "method"), raw.Method)
+	}
+	if !strings.HasPrefix(raw.Pattern, "/") {
+		return fmt.Errorf("line %d: pattern must start with /", fieldLine(node, "pattern"))
+	}
+	if raw.IP == nil {
+		return fmt.Errorf("line %d: ip limit is required", node.Line)
+	}
+	if raw.Cost != nil && *raw.Cost < 1 {
+		return fmt.Errorf("line %d: cost must be positive", fieldLine(node, "cost"))
+	}
+	if raw.CostBytes != nil && *raw.CostBytes < 0 {
+		return fmt.Errorf("line %d: cost_bytes must not be negative", fieldLine(node, "cost_bytes"))
+	}
+
+	r.Method = raw.Method
+	r.Pattern = raw.Pattern
+	r.IP = *raw.IP
+	r.Token = raw.Token
+	r.Cost = raw.Cost
+	r.CostBytes = raw.CostBytes
+	return nil
+}
+
+func (p *pathSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode || !strings.HasPrefix(node.Value, "/") {
+		return fmt.Errorf("line %d: exempt path must start with /", node.Line)
+	}
+	*p = pathSpec(node.Value)
+	return nil
+}
+
+func (p *prefixSpec) UnmarshalYAML(node *yaml.Node) error {
+	prefix, err := parsePrefix(node.Value)
+	if node.Kind != yaml.ScalarNode || err != nil {
+		return fmt.Errorf("line %d: invalid cidr %q", node.Line, node.Value)
+	}
+	*p = prefixSpec(prefix)
+	return nil
+}
+
+func prefixes(specs []prefixSpec) []netip.Prefix {
+	result := make([]netip.Prefix, 0, len(specs))
+	for _, spec := range specs {
+		result = append(result, netip.Prefix(spec))
+	}
+	return result
+}
+
+func (d *duration) UnmarshalYAML(node *yaml.Node) error {
+	value, err := time.ParseDuration(node.Value)
+	if node.Kind != yaml.ScalarNode || err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = duration(value)
+	return nil
+}
+
+func (a *Algorithm) UnmarshalYAML(node *yaml.Node) error {
+	algorithm := Algorithm(node.Value)
+	if node.Kind != yaml.ScalarNode || !algorithm.Valid() {
+		return fmt.Errorf("line %d: invalid algorithm %q", node.Line, node.Value)
+	}
+	*a = algorithm
+	return nil
+}
+
+func checkFields(node *yaml.Node, fields ...string) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		known := false
+		for _, field := range fields {
+			if key.Value == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+		}
+	}
+	return nil
+}
+
+func fieldLine(node *yaml.Node, field string) int {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == field {
+			return node.Content[i+1].Line
+		}
+	}
+	return node.Line
+}
+
+func expandIndentTabs(data []byte) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		indent := 0
+		for indent < len(line) && (line[indent] == '\t' || line[indent] == ' ') {
+			indent++
+		}
+		lines[i] = append(bytes.ReplaceAll(line[:indent:indent], []byte("\t"), []byte("  ")), line[indent:]...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicyYAML = `
+ip: {requests: 5, duration: 1s, block_duration: 5m, algorithm: sliding_window}
+tokens:
+  abc123: {requests: 100, duration: 1s, block_duration: 10m, daily: 10000, monthly: 200000}
+ip_ranges:
+  - cidr: 10.0.0.0/8
+    limit: {requests: 50, duration: 1s}
+routes:
+  - method: post
+    pattern: /api/data
+    ip: {requests: 2, duration: 1s, block_duration: 1m, shadow: true}
+    token: {requests: 20, duration: 1s, block_duration: 1m, algorithm: gcra}
+    cost: 5
+    cost_bytes: 1024
+exempt_paths: [/health]
+`
+
+func writePolicyFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func testBaseConfig() *Config {
+	return &Config{
+		IP:     RateLimitConfig{Requests: 1, Duration: time.Second, Algorithm: AlgorithmFixedWindow},
+		Token:  RateLimitConfig{Requests: 10, Duration: time.Second, Algorithm: AlgorithmTokenBucket},
+		Tokens: map[string]RateLimitConfig{apikey.Hash("env-token"): {Requests: 3, Duration: time.Second}},
+		Routes: []RoutePolicy{{Pattern: "/env"}},
+	}
+}
+
+func TestLoadPolicyFile_YAML(t *testing.T) {
+	path := writePolicyFile(t, "policy.yaml", testPolicyYAML)
+
+	cfg, err := LoadPolicyFile(path, testBaseConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, RateLimitConfig{Requests: 5, Duration: time.Second, BlockDuration: 5 * time.Minute, Algorithm: AlgorithmSlidingWindow}, cfg.IP)
+	assert.Equal(t, 10, cfg.Token.Requests, "sections missing from the file keep the base value")
+	assert.Equal(t, map[string]RateLimitConfig{
+		apikey.Hash("abc123"): {Requests: 100, Duration: time.Second, BlockDuration: 10 * time.Minute, Algorithm: AlgorithmTokenBucket, Daily: 10000, Monthly: 200000},
+	}, cfg.Tokens)
+
+	require.Len(t, cfg.IPRanges, 1)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), cfg.IPRanges[0].Prefix)
+	assert.Equal(t, 50, cfg.IPLimit("10.1.2.3").Requests)
+	assert.Equal(t, 5, cfg.IPLimit("192.168.1.1").Requests)
+
+	require.Len(t, cfg.Routes, 1)
+	assert.Equal(t, "POST /api/data", cfg.Routes[0].Name())
+	assert.Equal(t, AlgorithmSlidingWindow, cfg.Routes[0].IP.Algorithm)
+	assert.True(t, cfg.Routes[0].IP.Shadow)
+	assert.False(t, cfg.Routes[0].Token.Shadow)
+	assert.Equal(t, AlgorithmGCRA, cfg.Routes[0].Token.Algorithm)
+	assert.Equal(t, Cost{Fixed: 5, Bytes: 1024}, cfg.Routes[0].Cost)
+	assert.Equal(t, []string{"/health"}, cfg.ExemptPaths)
+}
+
+func TestLoadPolicyFile_JSON(t *testing.T) {
+	path := writePolicyFile(t, "policy.json", "{\n\t\"token\": {\n\t\t\"requests\": 15,\n\t\t\"duration\": \"2s\"\n\t},\n\t\"exempt_paths\": [\"/health\", \"/metrics\"]\n}\n")
+
+	cfg, err := LoadPolicyFile(path, testBaseConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, RateLimitConfig{Requests: 15, Duration: 2 * time.Second, Algorithm: AlgorithmTokenBucket}, cfg.Token)
+	assert.Equal(t, []string{"/health", "/metrics"}, cfg.ExemptPaths)
+	assert.Equal(t, 1, cfg.IP.Requests)
+}
+
+func TestLoadPolicyFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"unknown top-level field", "ip: {requests: 1, duration: 1s}\nroute: []\n", "line 2: field route not found"},
+		{"unknown limit field", "ip:\n  requests: 1\n  duration: 1s\n  burst: 3\n", `line 4: unknown field "burst"`},
+		{"invalid duration", "ip:\n  requests: 1\n  duration: 1x\n", `line 3: invalid duration "1x"`},
+		{"missing requests", "token:\n  duration: 1s\n", "line 2: requests is required"},
+		{"negative requests", "ip:\n  requests: -1\n  duration: 1s\n", "line 2: requests must not be negative"},
+		{"invalid requests", "ip:\n  requests: many\n  duration: 1s\n", "line 2: cannot unmarshal"},
+		{"invalid algorithm", "ip: {requests: 1, duration: 1s, algorithm: leaky}\n", `line 1: invalid algorithm "leaky"`},
+		{"invalid cidr", "ip_ranges:\n  - cidr: 10.0.0.0/33\n    limit: {requests: 1, duration: 1s}\n", `line 2: invalid cidr "10.0.0.0/33"`},
+		{"route without pattern slash", "routes:\n  - method: GET\n    pattern: api\n    ip: {requests: 1, duration: 1s}\n", "line 3: pattern must start with /"},
+		{"route without ip", "routes:\n  - pattern: /api\n", "line 2: ip limit is required"},
+		{"negative quota", "token: {requests: 1, duration: 1s, monthly: -1}\n", "line 1: monthly must not be negative"},
+		{"route with zero cost", "routes:\n  - pattern: /api\n    ip: {requests: 1, duration: 1s}\n    cost: 0\n", "line 4: cost must be positive"},
+		{"duplicated token", "tokens:\n  a: {requests: 1, duration: 1s}\n  a: {requests: 2, duration: 1s}\n", "line 3"},
+		{"invalid exempt path", "exempt_paths:\n  - health\n", "line 2: exempt path must start with /"},
+		{"token and its hash", "tokens:\n  abc123: {requests: 1, duration: 1s}\n  " + apikey.Hash("abc123") + ": {requests: 2, duration: 1s}\n", "token 6ca13d52ca70c883 is configured twice"},
+		{"plan with a dot", "plans:\n  pro.plus: {requests: 1, duration: 1s}\n", `invalid plan "pro.plus"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePolicyFile(t, "policy.yaml", tt.content)
+
+			_, err := LoadPolicyFile(path, testBaseConfig())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), path)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestLoad_PolicyFile(t *testing.T) {
+	path := writePolicyFile(t, "policy.yaml", testPolicyYAML)
+
+	os.Clearenv()
+	os.Setenv("RATE_LIMIT_TOKENS", "env-token:3:1s:1m")
+	os.Setenv("RATE_LIMIT_POLICY_FILE", path)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, path, cfg.PolicyFile)
+	assert.Equal(t, 5*time.Second, cfg.PolicyReloadInterval)
+	assert.Contains(t, cfg.Tokens, apikey.Hash("abc123"))
+	assert.NotContains(t, cfg.Tokens, apikey.Hash("env-token"))
+}
+
+func TestLoad_InvalidPolicyReloadInterval(t *testing.T) {
+	for _, interval := range []string{"0s", "-1s"} {
+		os.Clearenv()
+		os.Setenv("RATE_LIMIT_POLICY_RELOAD_INTERVAL", interval)
+
+		_, err := Load()
+		assert.Error(t, err, interval)
+	}
+}
+
+func TestLoadPolicyFile_PlansAndHashedTokens(t *testing.T) {
+	path := writePolicyFile(t, "policy.yaml", "tokens:\n  "+apikey.Hash("abc123")+": {requests: 100, duration: 1s}\n"+
+		"plans:\n  pro: {requests: 1000, duration: 1m, monthly: 1000000}\n")
+
+	cfg, err := LoadPolicyFile(path, testBaseConfig())
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfg.Tokens[apikey.Hash("abc123")].Requests)
+	assert.Equal(t, RateLimitConfig{Requests: 1000, Duration: time.Minute, Algorithm: AlgorithmTokenBucket, Monthly: 1000000}, cfg.Plans["pro"])
+}
+
+func TestLoad_InvalidTokens(t *testing.T) {
+	tests := []string{
+		"abc123",
+		"abc123:100:1s",
+		"abc123:many:1s:10m",
+		"abc123:100:soon:10m",
+		"abc123:100:1s:10m:leaky",
+		"abc123:100:1s:10m,abc123:5:1s:1m",
+	}
+
+	for _, tokens := range tests {
+		os.Clearenv()
+		os.Setenv("RATE_LIMIT_TOKENS", tokens)
+
+		_, err := Load()
+		assert.Error(t, err, tokens)
+	}
+}
+
+func TestWatchPolicyFile(t *testing.T) {
+	path := writePolicyFile(t, "policy.yaml", "ip: {requests: 5, duration: 1s}\n")
+
+	base := testBaseConfig()
+	base.PolicyFile = path
+	base.PolicyReloadInterval = 10 * time.Millisecond
+
+	cfg, err := LoadPolicyFile(path, base)
+	require.NoError(t, err)
+
+	changes := make(chan *Config, 1)
+	errs := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchPolicyFile(ctx, cfg, func(c *Config) { changes <- c }, func(err error) { errs <- err })
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("ip: {requests: 5, duration: 1x}\n"), 0o644))
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "line 1")
+	case <-changes:
+		t.Fatal("invalid policy should not be applied")
+	case <-time.After(time.Second):
+		t.Fatal("expected reload error")
+	}
+
+	// a seção ip removida do arquivo volta ao valor do ambiente
+	require.NoError(t, os.WriteFile(path, []byte("token: {requests: 42, duration: 1s}\n"), 0o644))
+	select {
+	case c := <-changes:
+		assert.Equal(t, 42, c.Token.Requests)
+		assert.Equal(t, 1, c.IP.Requests)
+	case err := <-errs:
+		t.Fatalf("unexpected reload error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("expected policy reload")
+	}
+}
+
+func TestLoadPolicyFile_IPLists(t *testing.T) {
+	path := writePolicyFile(t, "policy.yaml", "trusted_proxies: [10.0.0.0/8]\nallowlist: [192.168.0.10]\ndenylist:\n  - 203.0.113.0/24\n  - 2001:db8::/33x\n")
+
+	_, err := LoadPolicyFile(path, testBaseConfig())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `line 5: invalid cidr "2001:db8::/33x"`)
+
+	path = writePolicyFile(t, "policy.yaml", "trusted_proxies: [10.0.0.0/8]\nallowlist: [192.168.0.10]\ndenylist: [203.0.113.0/24]\n")
+
+	cfg, err := LoadPolicyFile(path, testBaseConfig())
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, cfg.TrustedProxies)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.168.0.10/32")}, cfg.AllowList)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, cfg.DenyList)
+}
@@ -0,0 +1,186 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// MetadataAPIKey é a chave de metadata com o token, equivalente ao header
+// API_KEY do middleware HTTP.
+const MetadataAPIKey = "api_key"
+
+// MethodGRPC é o método HTTP usado nas políticas por rota: chamadas gRPC são
+// verificadas como "POST /pacote.Servico/Metodo".
+const MethodGRPC = http.MethodPost
+
+// UnaryServerInterceptor aplica as políticas do RateLimiter a cada chamada
+// unária, usando o nome completo do método como rota e o tamanho da mensagem
+// como tamanho da requisição.
+func UnaryServerInterceptor(rl *limiter.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var size int64
+		if message, ok := req.(proto.Message); ok {
+			size = int64(proto.Size(message))
+		}
+
+		decision, err := check(ctx, rl, info.FullMethod, size)
+		if header := rateLimitMetadata(decision, rl.Config().Now()); header != nil {
+			grpc.SetHeader(ctx, header)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		release, err := acquire(ctx, rl, decision)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor aplica as políticas do RateLimiter na abertura de
+// cada stream. As mensagens dentro do stream não são limitadas e a vaga de
+// concorrência fica ocupada até o stream terminar.
+func StreamServerInterceptor(rl *limiter.RateLimiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		decision, err := check(ss.Context(), rl, info.FullMethod, 0)
+		if header := rateLimitMetadata(decision, rl.Config().Now()); header != nil {
+			ss.SetHeader(header)
+		}
+		if err != nil {
+			return err
+		}
+
+		release, err := acquire(ss.Context(), rl, decision)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return handler(srv, ss)
+	}
+}
+
+func check(ctx context.Context, rl *limiter.RateLimiter, fullMethod string, size int64) (limiter.Decision, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+
+	var token string
+	if values := md.Get(MetadataAPIKey); len(values) > 0 {
+		token = values[0]
+	}
+
+	decision, err := rl.Check(ctx, limiter.Request{
+		IP:     middleware.ClientIP(remoteAddr, toHeader(md), rl.Config()),
+		Token:  token,
+		Method: MethodGRPC,
+		Path:   fullMethod,
+		Size:   size,
+	})
+	if err != nil {
+		return limiter.Decision{}, status.Error(codes.Internal, "rate limiter error")
+	}
+
+	switch {
+	case decision.Denied:
+		return decision, status.Error(codes.PermissionDenied, middleware.MessageAccessDenied)
+	case decision.Degraded && !decision.Allowed:
+		return decision, status.Error(codes.Unavailable, middleware.MessageUnavailable)
+	case !decision.Allowed:
+		return decision, resourceExhausted(decision)
+	}
+	return decision, nil
+}
+
+func acquire(ctx context.Context, rl *limiter.RateLimiter, decision limiter.Decision) (func(), error) {
+	release, err := rl.Acquire(ctx, decision)
+	switch {
+	case errors.Is(err, limiter.ErrConcurrencyLimit):
+		return nil, status.Error(codes.ResourceExhausted, middleware.MessageConcurrencyLimit)
+	case errors.Is(err, limiter.ErrUnavailable):
+		return nil, status.Error(codes.Unavailable, middleware.MessageUnavailable)
+	case err != nil:
+		return nil, status.Error(codes.Internal, "rate limiter error")
+	}
+	return release, nil
+}
+
+// resourceExhausted retorna o erro com RetryInfo, para que clientes com retry
+// saibam quanto esperar, e QuotaFailure indicando a política excedida.
+func resourceExhausted(d limiter.Decision) error {
+	st := status.New(codes.ResourceExhausted, middleware.MessageRateLimitExceeded)
+
+	description := d.Policy
+	if d.Route != "" {
+		description += " " + d.Route
+	}
+	if d.Quota != "" {
+		st = status.New(codes.ResourceExhausted, middleware.MessageQuotaExceeded)
+		description += " " + d.Quota + " quota"
+	}
+
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(d.RetryAfter)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     d.Policy,
+			Description: description,
+		}}},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// rateLimitMetadata converte os headers de rate limit do middleware HTTP em
+// metadata de resposta, com as chaves em minúsculas.
+func rateLimitMetadata(d limiter.Decision, now time.Time) metadata.MD {
+	// decisões sem chave (isentas, denylist ou erro) e degradadas não têm contadores
+	if d.Key == "" || d.Degraded {
+		return nil
+	}
+
+	h := http.Header{}
+	if d.Shadow {
+		h.Set(middleware.HeaderRateLimitShadow, middleware.ShadowResult(d))
+	} else {
+		middleware.SetRateLimitHeaders(h, d, now)
+	}
+
+	md := metadata.MD{}
+	for key, values := range h {
+		md.Set(strings.ToLower(key), values...)
+	}
+	return md
+}
+
+func toHeader(md metadata.MD) http.Header {
+	h := http.Header{}
+	for key, values := range md {
+		for _, value := range values {
+			h.Add(key, value)
+		}
+	}
+	return h
+}
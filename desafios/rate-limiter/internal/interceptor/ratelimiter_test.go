@@ -0,0 +1,198 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newTestLimiter(t *testing.T) *limiter.RateLimiter {
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: time.Minute, BlockDuration: time.Minute},
+		Token:  config.RateLimitConfig{Requests: 5, Duration: time.Minute, BlockDuration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Routes: []config.RoutePolicy{{
+			Pattern: "/pb.CategoryService/CreateCategory",
+			IP:      config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+			Token:   config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+		}},
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		DenyList:       []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}
+
+	return limiter.New(store, cfg)
+}
+
+func incomingContext(remote string, md metadata.MD) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: net.TCPAddrFromAddrPort(netip.MustParseAddrPort(remote))})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// headerStream captura o metadata de resposta enviado com SetHeader.
+type headerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *headerStream) Context() context.Context { return s.ctx }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(md metadata.MD) {}
+
+func unary(rl *limiter.RateLimiter, ctx context.Context, method string) (metadata.MD, error) {
+	stream := &headerStream{ctx: ctx}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, &transportStream{stream: stream, method: method})
+
+	interceptor := UnaryServerInterceptor(rl)
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	return stream.header, err
+}
+
+// transportStream permite que grpc.SetHeader funcione fora de um servidor real.
+type transportStream struct {
+	stream *headerStream
+	method string
+}
+
+func (s *transportStream) Method() string                  { return s.method }
+func (s *transportStream) SetHeader(md metadata.MD) error  { return s.stream.SetHeader(md) }
+func (s *transportStream) SendHeader(md metadata.MD) error { return s.stream.SendHeader(md) }
+func (s *transportStream) SetTrailer(md metadata.MD) error { return nil }
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	rl := newTestLimiter(t)
+	ctx := incomingContext("192.168.1.1:5000", nil)
+
+	for i := 1; i <= 2; i++ {
+		header, err := unary(rl, ctx, "/pb.CategoryService/ListCategories")
+		require.NoError(t, err, "Call %d should be allowed", i)
+		assert.Equal(t, []string{"2"}, header.Get("x-ratelimit-limit"))
+	}
+
+	header, err := unary(rl, ctx, "/pb.CategoryService/ListCategories")
+	require.Error(t, err)
+	assert.Equal(t, []string{"0"}, header.Get("x-ratelimit-remaining"))
+	assert.NotEmpty(t, header.Get("retry-after"))
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+	var retryInfo *errdetails.RetryInfo
+	var quotaFailure *errdetails.QuotaFailure
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.RetryInfo:
+			retryInfo = d
+		case *errdetails.QuotaFailure:
+			quotaFailure = d
+		}
+	}
+	require.NotNil(t, retryInfo)
+	assert.Equal(t, time.Minute, retryInfo.RetryDelay.AsDuration())
+	require.NotNil(t, quotaFailure)
+	assert.Equal(t, limiter.PolicyIP, quotaFailure.Violations[0].Subject)
+}
+
+func TestUnaryServerInterceptor_MethodPolicyAndAPIKey(t *testing.T) {
+	rl := newTestLimiter(t)
+	ctx := incomingContext("192.168.1.1:5000", metadata.Pairs(MetadataAPIKey, "abc123"))
+
+	_, err := unary(rl, ctx, "/pb.CategoryService/CreateCategory")
+	require.NoError(t, err)
+
+	_, err = unary(rl, ctx, "/pb.CategoryService/CreateCategory")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	st, err := rl.Status(context.Background(), limiter.Request{Token: "abc123"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), st.Keys[1].State.Count)
+	assert.Equal(t, "token:"+apikey.HashID(apikey.Hash("abc123"))+":* /pb.CategoryService/CreateCategory", st.Keys[1].Key)
+
+	// o limite global do token não foi consumido
+	_, err = unary(rl, ctx, "/pb.CategoryService/ListCategories")
+	assert.NoError(t, err)
+}
+
+func TestUnaryServerInterceptor_ClientIP(t *testing.T) {
+	rl := newTestLimiter(t)
+
+	// x-forwarded-for só é considerado quando vem de um proxy confiável
+	spoofed := incomingContext("192.168.1.1:5000", metadata.Pairs("x-forwarded-for", "203.0.113.5"))
+	_, err := unary(rl, spoofed, "/pb.CategoryService/ListCategories")
+	assert.NoError(t, err)
+
+	proxied := incomingContext("10.0.0.1:5000", metadata.Pairs("x-forwarded-for", "203.0.113.5"))
+	_, err = unary(rl, proxied, "/pb.CategoryService/ListCategories")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestUnaryServerInterceptor_Shadow(t *testing.T) {
+	rl := newTestLimiter(t)
+	cfg := *rl.Config()
+	cfg.IP.Shadow = true
+	rl.SetConfig(&cfg)
+	ctx := incomingContext("192.168.1.1:5000", nil)
+
+	for i := 1; i <= 3; i++ {
+		header, err := unary(rl, ctx, "/pb.CategoryService/ListCategories")
+		require.NoError(t, err, "Call %d should be allowed in shadow mode", i)
+		assert.Empty(t, header.Get("x-ratelimit-limit"))
+
+		want := middleware.ShadowAllow
+		if i > 2 {
+			want = middleware.ShadowDeny
+		}
+		assert.Equal(t, []string{want}, header.Get("x-ratelimit-shadow"), "Call %d", i)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	rl := newTestLimiter(t)
+	interceptor := StreamServerInterceptor(rl)
+	info := &grpc.StreamServerInfo{FullMethod: "/pb.CategoryService/CreateCategoryStream", IsClientStream: true}
+
+	calls := 0
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		calls++
+		return nil
+	}
+
+	for i := 1; i <= 2; i++ {
+		stream := &headerStream{ctx: incomingContext("192.168.1.1:5000", nil)}
+		require.NoError(t, interceptor(nil, stream, info, handler))
+		assert.Equal(t, []string{"2"}, stream.header.Get("x-ratelimit-limit"))
+	}
+
+	stream := &headerStream{ctx: incomingContext("192.168.1.1:5000", nil)}
+	err := interceptor(nil, stream, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 2, calls)
+}
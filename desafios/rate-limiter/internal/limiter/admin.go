@@ -0,0 +1,253 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+// ErrInvalidOverride é retornado por SetOverride quando o limite ou o ttl são
+// inválidos.
+var ErrInvalidOverride = errors.New("invalid override")
+
+// Override substitui temporariamente o limite de um IP ou token, inclusive nas
+// rotas com política própria. Overrides ficam no storage, então valem em todas
+// as instâncias que o compartilham.
+type Override struct {
+	Limit     config.RateLimitConfig
+	ExpiresAt time.Time
+}
+
+// Status é o estado de um IP ou token: a política efetiva, o override ativo e
+// o estado de cada chave usada por ele, a global e uma por rota configurada.
+type Status struct {
+	Key      string
+	Policy   string
+	Limit    config.RateLimitConfig
+	Override *Override
+	Keys     []KeyStatus
+}
+
+type KeyStatus struct {
+	Key   string
+	Route string
+	Limit config.RateLimitConfig
+	State storage.State
+}
+
+func (rl *RateLimiter) Status(ctx context.Context, req Request) (Status, error) {
+	cfg := rl.config.Load()
+	key, policy, limit := resolve(cfg, req)
+
+	status := Status{Key: key, Policy: policy, Limit: limit}
+	override, found, err := rl.override(ctx, key)
+	if err != nil {
+		return Status{}, err
+	}
+	if found {
+		status.Override = &override
+		status.Limit = override.Limit
+	}
+
+	for _, k := range keys(cfg, key, policy, status.Limit, status.Override != nil) {
+		state, err := rl.storage.Inspect(ctx, k.Key, toLimit(cfg, k.Limit))
+		if err != nil {
+			return Status{}, fmt.Errorf("error inspecting key: %w", err)
+		}
+		k.State = state
+		status.Keys = append(status.Keys, k)
+	}
+
+	return status, nil
+}
+
+// Unblock remove o bloqueio do IP ou token em todas as suas chaves, mantendo
+// os contadores.
+func (rl *RateLimiter) Unblock(ctx context.Context, req Request) error {
+	cfg := rl.config.Load()
+	key, policy, limit := resolve(cfg, req)
+
+	for _, k := range keys(cfg, key, policy, limit, false) {
+		if err := rl.storage.Unblock(ctx, k.Key); err != nil {
+			return fmt.Errorf("error unblocking key: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reset apaga os contadores e bloqueios do IP ou token em todas as suas chaves.
+func (rl *RateLimiter) Reset(ctx context.Context, req Request) error {
+	cfg := rl.config.Load()
+	key, policy, limit := resolve(cfg, req)
+
+	for _, k := range keys(cfg, key, policy, limit, false) {
+		if err := rl.storage.Reset(ctx, k.Key); err != nil {
+			return fmt.Errorf("error resetting key: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetOverride aplica limit ao IP ou token até ttl expirar.
+func (rl *RateLimiter) SetOverride(ctx context.Context, req Request, limit config.RateLimitConfig, ttl time.Duration) (Override, error) {
+	if _, err := rl.algorithm(limit.Algorithm); err != nil {
+		return Override{}, fmt.Errorf("%w: %w", ErrInvalidOverride, err)
+	}
+	if limit.Requests <= 0 || limit.Duration <= 0 || limit.BlockDuration < 0 {
+		return Override{}, fmt.Errorf("%w: requests and duration must be positive", ErrInvalidOverride)
+	}
+	if limit.Concurrency < 0 {
+		return Override{}, fmt.Errorf("%w: concurrency must not be negative", ErrInvalidOverride)
+	}
+	if limit.Daily < 0 || limit.Monthly < 0 {
+		return Override{}, fmt.Errorf("%w: quotas must not be negative", ErrInvalidOverride)
+	}
+	if ttl <= 0 {
+		return Override{}, fmt.Errorf("%w: ttl %v must be positive", ErrInvalidOverride, ttl)
+	}
+
+	cfg := rl.config.Load()
+	key, _, _ := resolve(cfg, req)
+	override := Override{Limit: limit, ExpiresAt: cfg.Now().Add(ttl)}
+
+	value, err := json.Marshal(override)
+	if err != nil {
+		return Override{}, fmt.Errorf("error encoding override: %w", err)
+	}
+	if err := rl.storage.SetOverride(ctx, key, value, ttl); err != nil {
+		return Override{}, fmt.Errorf("error setting override: %w", err)
+	}
+	rl.overrides.delete(key)
+
+	return override, nil
+}
+
+// RemoveOverride remove o override do IP ou token e informa se ele existia.
+func (rl *RateLimiter) RemoveOverride(ctx context.Context, req Request) (bool, error) {
+	key, _, _ := resolve(rl.config.Load(), req)
+
+	found, err := rl.storage.DeleteOverride(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("error removing override: %w", err)
+	}
+	rl.overrides.delete(key)
+	return found, nil
+}
+
+// cachedOverride é o override usado nas decisões: lido do storage no máximo uma
+// vez a cada OverrideCacheTTL por chave, para que cada requisição não faça uma
+// leitura a mais no storage. Um override alterado em outra instância passa a
+// valer aqui em até OverrideCacheTTL.
+func (rl *RateLimiter) cachedOverride(ctx context.Context, cfg *config.Config, key string) (Override, bool, error) {
+	if cfg.OverrideCacheTTL <= 0 {
+		return rl.override(ctx, key)
+	}
+
+	now := cfg.Now()
+	if entry, ok := rl.overrides.get(key, now); ok {
+		return entry.override, entry.found && !now.After(entry.override.ExpiresAt), nil
+	}
+
+	override, found, err := rl.override(ctx, key)
+	if err != nil {
+		return Override{}, false, err
+	}
+	rl.overrides.set(key, cachedOverride{override: override, found: found, expiresAt: now.Add(cfg.OverrideCacheTTL)}, now)
+	return override, found, nil
+}
+
+func (rl *RateLimiter) override(ctx context.Context, key string) (Override, bool, error) {
+	value, err := rl.storage.GetOverride(ctx, key)
+	if err != nil || value == nil {
+		return Override{}, false, err
+	}
+
+	var override Override
+	if err := json.Unmarshal(value, &override); err != nil {
+		return Override{}, false, fmt.Errorf("error decoding override: %w", err)
+	}
+	if rl.config.Load().Now().After(override.ExpiresAt) {
+		return Override{}, false, nil
+	}
+	return override, true, nil
+}
+
+// overrideCacheMaxKeys limita as chaves guardadas em overrideCache, que inclui
+// as chaves sem override, uma por IP ou token recente.
+const overrideCacheMaxKeys = 100000
+
+// overrideCache guarda os overrides lidos do storage, inclusive a ausência de
+// override, até expiresAt.
+type overrideCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedOverride
+}
+
+type cachedOverride struct {
+	override  Override
+	found     bool
+	expiresAt time.Time
+}
+
+func (c *overrideCache) get(key string, now time.Time) (cachedOverride, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return cachedOverride{}, false
+	}
+	return entry, true
+}
+
+// set guarda entry; com o cache cheio, as entradas expiradas são descartadas
+// e, se não houver nenhuma, o cache recomeça vazio.
+func (c *overrideCache) set(key string, entry cachedOverride, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cachedOverride)
+	}
+	if len(c.entries) >= overrideCacheMaxKeys {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= overrideCacheMaxKeys {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = entry
+}
+
+func (c *overrideCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// keys lista a chave global e as chaves por rota de um IP ou token, com o
+// limite efetivo de cada uma.
+func keys(cfg *config.Config, key, policy string, limit config.RateLimitConfig, overridden bool) []KeyStatus {
+	result := []KeyStatus{{Key: storageKey(key, limit), Limit: limit}}
+	for _, route := range cfg.Routes {
+		routeStatus := KeyStatus{
+			Route: route.Name(),
+			Limit: routeLimit(route, policy),
+		}
+		if overridden {
+			routeStatus.Limit = limit
+		}
+		routeStatus.Key = storageKey(fmt.Sprintf("%s:%s", key, route.Name()), routeStatus.Limit)
+		result = append(result, routeStatus)
+	}
+	return result
+}
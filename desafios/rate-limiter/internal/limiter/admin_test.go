@@ -0,0 +1,213 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTestLimiter(t *testing.T) (*RateLimiter, *clock.Fake) {
+	clk := clock.NewFake(time.Now())
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: time.Minute, BlockDuration: time.Minute},
+		Token:  config.RateLimitConfig{Requests: 10, Duration: time.Minute, BlockDuration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Routes: []config.RoutePolicy{{
+			Method:  "POST",
+			Pattern: "/api/data",
+			IP:      config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute},
+			Token:   config.RateLimitConfig{Requests: 5, Duration: time.Minute, BlockDuration: time.Minute},
+		}},
+		Clock: clk,
+	}
+
+	return New(store, cfg), clk
+}
+
+func TestRateLimiter_StatusUnblockAndReset(t *testing.T) {
+	rl, _ := newAdminTestLimiter(t)
+	ctx := context.Background()
+	ip := Request{IP: "192.168.1.1"}
+
+	for i := 0; i < 3; i++ {
+		_, err := rl.CheckLimit(ctx, ip.IP, "")
+		require.NoError(t, err)
+	}
+	_, err := rl.Check(ctx, Request{IP: ip.IP, Method: "POST", Path: "/api/data"})
+	require.NoError(t, err)
+
+	status, err := rl.Status(ctx, ip)
+	require.NoError(t, err)
+	assert.Equal(t, "ip:192.168.1.1", status.Key)
+	assert.Equal(t, PolicyIP, status.Policy)
+	assert.Equal(t, 2, status.Limit.Requests)
+	assert.Nil(t, status.Override)
+	require.Len(t, status.Keys, 2)
+
+	assert.Equal(t, "ip:192.168.1.1", status.Keys[0].Key)
+	assert.Equal(t, int64(3), status.Keys[0].State.Count)
+	assert.True(t, status.Keys[0].State.BlockedFor > 0)
+
+	assert.Equal(t, "ip:192.168.1.1:POST /api/data", status.Keys[1].Key)
+	assert.Equal(t, "POST /api/data", status.Keys[1].Route)
+	assert.Equal(t, 1, status.Keys[1].Limit.Requests)
+	assert.Equal(t, int64(1), status.Keys[1].State.Count)
+
+	require.NoError(t, rl.Unblock(ctx, ip))
+
+	status, err = rl.Status(ctx, ip)
+	require.NoError(t, err)
+	assert.Zero(t, status.Keys[0].State.BlockedFor)
+	assert.Equal(t, int64(3), status.Keys[0].State.Count, "Unblock should keep the counters")
+
+	require.NoError(t, rl.Reset(ctx, ip))
+
+	status, err = rl.Status(ctx, ip)
+	require.NoError(t, err)
+	for _, k := range status.Keys {
+		assert.Zero(t, k.State.Count, k.Key)
+	}
+
+	decision, err := rl.CheckLimit(ctx, ip.IP, "")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestRateLimiter_Override(t *testing.T) {
+	rl, clk := newAdminTestLimiter(t)
+	ctx := context.Background()
+	token := Request{Token: "abc123"}
+
+	_, err := rl.SetOverride(ctx, token, config.RateLimitConfig{Requests: 1, Duration: time.Minute, Algorithm: "unknown"}, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidOverride)
+	_, err = rl.SetOverride(ctx, token, config.RateLimitConfig{Requests: 0, Duration: time.Minute}, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidOverride)
+	_, err = rl.SetOverride(ctx, token, config.RateLimitConfig{Requests: 1, Duration: time.Minute}, 0)
+	assert.ErrorIs(t, err, ErrInvalidOverride)
+
+	override, err := rl.SetOverride(ctx, token, config.RateLimitConfig{Requests: 1, Duration: time.Minute}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, clk.Now().Add(time.Minute), override.ExpiresAt)
+
+	decision, err := rl.CheckLimit(ctx, "", token.Token)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Limit)
+
+	decision, err = rl.Check(ctx, Request{Token: token.Token, Method: "POST", Path: "/api/data"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, decision.Limit, "Override should also replace route limits")
+
+	decision, err = rl.CheckLimit(ctx, "", token.Token)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	status, err := rl.Status(ctx, token)
+	require.NoError(t, err)
+	require.NotNil(t, status.Override)
+	assert.Equal(t, 1, status.Limit.Requests)
+	assert.Equal(t, 1, status.Keys[1].Limit.Requests)
+
+	removed, err := rl.RemoveOverride(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = rl.RemoveOverride(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, removed)
+	require.NoError(t, rl.Reset(ctx, token))
+
+	decision, err = rl.CheckLimit(ctx, "", token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, 10, decision.Limit)
+}
+
+func TestRateLimiter_OverrideSharedByInstances(t *testing.T) {
+	rl, _ := newAdminTestLimiter(t)
+	other := New(rl.storage, rl.Config())
+	ctx := context.Background()
+
+	_, err := rl.SetOverride(ctx, Request{IP: "10.0.0.1"}, config.RateLimitConfig{Requests: 100, Duration: time.Minute}, time.Hour)
+	require.NoError(t, err)
+
+	decision, err := other.CheckLimit(ctx, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 100, decision.Limit, "Override should apply to every instance sharing the storage")
+
+	removed, err := other.RemoveOverride(ctx, Request{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	decision, err = rl.CheckLimit(ctx, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, decision.Limit)
+}
+
+// overrideCountingStorage conta as leituras de override no storage.
+type overrideCountingStorage struct {
+	*storage.MemoryStorage
+	reads int
+}
+
+func (s *overrideCountingStorage) GetOverride(ctx context.Context, key string) ([]byte, error) {
+	s.reads++
+	return s.MemoryStorage.GetOverride(ctx, key)
+}
+
+func TestRateLimiter_OverrideCache(t *testing.T) {
+	rl, clk := newAdminTestLimiter(t)
+	store := &overrideCountingStorage{MemoryStorage: rl.storage.(*storage.MemoryStorage)}
+	cfg := *rl.Config()
+	cfg.OverrideCacheTTL = time.Second
+	rl = New(store, &cfg)
+	other := New(store, &cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := rl.Check(ctx, Request{IP: "10.0.0.1"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, store.reads, "Checks within the cache TTL read the override once")
+
+	// o override da própria instância vale na hora, o de outra depois do TTL
+	_, err := other.SetOverride(ctx, Request{IP: "10.0.0.1"}, config.RateLimitConfig{Requests: 100, Duration: time.Minute}, time.Hour)
+	require.NoError(t, err)
+	decision, err := rl.Check(ctx, Request{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, decision.Limit)
+	decision, err = other.Check(ctx, Request{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, 100, decision.Limit)
+
+	clk.Advance(time.Second)
+	decision, err = rl.Check(ctx, Request{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, 100, decision.Limit)
+	assert.Equal(t, 3, store.reads)
+}
+
+func TestRateLimiter_OverrideExpires(t *testing.T) {
+	rl, clk := newAdminTestLimiter(t)
+	ctx := context.Background()
+
+	_, err := rl.SetOverride(ctx, Request{IP: "10.0.0.1"}, config.RateLimitConfig{Requests: 100, Duration: time.Minute}, time.Hour)
+	require.NoError(t, err)
+
+	decision, err := rl.CheckLimit(ctx, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 100, decision.Limit)
+
+	clk.Advance(time.Hour + time.Second)
+
+	decision, err = rl.CheckLimit(ctx, "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, decision.Limit)
+}
@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+// Algorithm consome limit.Cost unidades do limite associado à chave. A
+// verificação e a criação do bloqueio fazem parte da mesma operação atômica no
+// storage.
+type Algorithm interface {
+	Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error)
+}
+
+func newAlgorithms(store storage.Storage) map[config.Algorithm]Algorithm {
+	return map[config.Algorithm]Algorithm{
+		config.AlgorithmFixedWindow:   fixedWindow{store},
+		config.AlgorithmSlidingLog:    slidingLog{store},
+		config.AlgorithmSlidingWindow: slidingWindow{store},
+		config.AlgorithmTokenBucket:   tokenBucket{store},
+		config.AlgorithmGCRA:          gcra{store},
+	}
+}
+
+// NewAlgorithm retorna o algoritmo name sobre o storage, para quem aplica os
+// limites sem o RateLimiter, como o pacote pkg/ratelimit.
+func NewAlgorithm(store storage.Storage, name config.Algorithm) (Algorithm, error) {
+	if name == "" {
+		name = config.AlgorithmFixedWindow
+	}
+
+	alg, exists := newAlgorithms(store)[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", name)
+	}
+	return alg, nil
+}
+
+func (rl *RateLimiter) algorithm(name config.Algorithm) (Algorithm, error) {
+	if name == "" {
+		name = config.AlgorithmFixedWindow
+	}
+
+	alg, exists := rl.algorithms[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", name)
+	}
+	return alg, nil
+}
+
+// toLimit converte o limite da política, com o aumento progressivo do bloqueio
+// configurado globalmente.
+func toLimit(cfg *config.Config, limit config.RateLimitConfig) storage.Limit {
+	return storage.Limit{
+		Requests:      int64(limit.Requests),
+		Window:        limit.Duration,
+		BlockDuration: limit.BlockDuration,
+		Escalation: storage.Escalation{
+			Factor: cfg.BlockFactor,
+			Max:    cfg.MaxBlockDuration,
+			Decay:  cfg.ViolationDecay,
+		},
+	}
+}
+
+type fixedWindow struct {
+	storage storage.Storage
+}
+
+func (a fixedWindow) Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return a.storage.FixedWindow(ctx, key, limit)
+}
+
+type slidingLog struct {
+	storage storage.Storage
+}
+
+func (a slidingLog) Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return a.storage.SlidingLog(ctx, key, limit)
+}
+
+type slidingWindow struct {
+	storage storage.Storage
+}
+
+func (a slidingWindow) Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return a.storage.SlidingWindow(ctx, key, limit)
+}
+
+type tokenBucket struct {
+	storage storage.Storage
+}
+
+func (a tokenBucket) Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return a.storage.TokenBucket(ctx, key, limit)
+}
+
+type gcra struct {
+	storage storage.Storage
+}
+
+func (a gcra) Allow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return a.storage.GCRA(ctx, key, limit)
+}
@@ -0,0 +1,91 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+)
+
+var (
+	// ErrConcurrencyLimit indica que a chave já tem o máximo de requisições
+	// simultâneas em andamento.
+	ErrConcurrencyLimit = errors.New("concurrency limit exceeded")
+	// ErrUnavailable indica que o storage falhou e o FailureMode é fail_closed.
+	ErrUnavailable = errors.New("rate limiter unavailable")
+)
+
+// Acquire reserva uma vaga de concorrência para a requisição permitida por
+// decision e retorna a função que a libera ao fim da requisição. Enquanto a
+// vaga estiver em uso o lease é renovado em segundo plano; se a instância cair,
+// a vaga expira após RATE_LIMIT_CONCURRENCY_LEASE. Decisões sem limite de
+// concorrência, isentas ou degradadas não reservam vaga. Em modo shadow, a
+// requisição sem vaga passa sem reservá-la.
+func (rl *RateLimiter) Acquire(ctx context.Context, decision Decision) (func(), error) {
+	noop := func() {}
+	if !decision.Allowed || decision.Exempt || decision.Degraded || decision.Concurrency <= 0 {
+		return noop, nil
+	}
+
+	cfg := rl.config.Load()
+	lease := cfg.ConcurrencyLease
+	id, err := leaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := rl.storage.Acquire(ctx, decision.Key, id, int64(decision.Concurrency), lease)
+	if err != nil {
+		switch cfg.FailureMode {
+		case config.FailOpen, config.FailLocal:
+			return noop, nil
+		case config.FailClosed:
+			return nil, ErrUnavailable
+		}
+		return nil, fmt.Errorf("error acquiring concurrency slot: %w", err)
+	}
+	if !acquired {
+		if decision.Shadow {
+			return noop, nil
+		}
+		return nil, ErrConcurrencyLimit
+	}
+
+	// a liberação não deve ser perdida quando o contexto da requisição já
+	// foi cancelado
+	ctx = context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rl.storage.Renew(ctx, decision.Key, id, lease)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			rl.storage.Release(ctx, decision.Key, id)
+		})
+	}, nil
+}
+
+func leaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating lease id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
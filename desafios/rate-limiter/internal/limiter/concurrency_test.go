@@ -0,0 +1,145 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Concurrency(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 100, Duration: time.Second, Concurrency: 2},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		ConcurrencyLease: 40 * time.Millisecond,
+	}
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	acquire := func() (func(), error) {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+		assert.Equal(t, 2, decision.Concurrency)
+		return rl.Acquire(ctx, decision)
+	}
+
+	release1, err := acquire()
+	require.NoError(t, err)
+	release2, err := acquire()
+	require.NoError(t, err)
+
+	// as vagas continuam ocupadas depois de vários leases porque são renovadas
+	time.Sleep(100 * time.Millisecond)
+	_, err = acquire()
+	assert.ErrorIs(t, err, ErrConcurrencyLimit)
+
+	release1()
+	release1()
+	release3, err := acquire()
+	require.NoError(t, err)
+
+	release2()
+	release3()
+
+	state, err := store.Inspect(ctx, "ip:192.168.1.1", storage.Limit{Requests: 100, Window: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), state.InFlight)
+}
+
+func TestRateLimiter_ConcurrencyShadow(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 100, Duration: time.Second, Concurrency: 1, Shadow: true},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		ConcurrencyLease: time.Minute,
+	}
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	release1, err := rl.Acquire(ctx, decision)
+	require.NoError(t, err)
+	defer release1()
+
+	// sem vaga, a requisição em modo shadow passa sem reservar nenhuma
+	release2, err := rl.Acquire(ctx, decision)
+	require.NoError(t, err)
+	release2()
+
+	state, err := store.Inspect(ctx, "shadow:ip:192.168.1.1", storage.Limit{Requests: 100, Window: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), state.InFlight)
+}
+
+func TestRateLimiter_ConcurrencyUnlimited(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 100, Duration: time.Second},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		ConcurrencyLease: time.Second,
+	}
+	rl := New(store, cfg)
+
+	for i := 0; i < 10; i++ {
+		decision, err := rl.CheckLimit(context.Background(), "192.168.1.1", "")
+		require.NoError(t, err)
+		_, err = rl.Acquire(context.Background(), decision)
+		require.NoError(t, err)
+	}
+}
+
+type unavailableSemaphore struct {
+	*storage.MemoryStorage
+}
+
+func (unavailableSemaphore) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error) {
+	return false, storage.ErrUnavailable
+}
+
+func TestRateLimiter_ConcurrencyFailureModes(t *testing.T) {
+	store := unavailableSemaphore{storage.NewMemoryStorage()}
+	defer store.Close()
+
+	tests := []struct {
+		mode config.FailureMode
+		err  error
+	}{
+		{config.FailOpen, nil},
+		{config.FailLocal, nil},
+		{config.FailClosed, ErrUnavailable},
+		{"", storage.ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			IP:               config.RateLimitConfig{Requests: 5, Duration: time.Second, Concurrency: 1},
+			Tokens:           make(map[string]config.RateLimitConfig),
+			ConcurrencyLease: time.Second,
+			FailureMode:      tt.mode,
+		}
+		rl := New(store, cfg)
+
+		decision, err := rl.CheckLimit(context.Background(), "192.168.1.1", "")
+		require.NoError(t, err)
+
+		release, err := rl.Acquire(context.Background(), decision)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.mode)
+			continue
+		}
+		require.NoError(t, err, tt.mode)
+		release()
+	}
+}
@@ -3,78 +3,309 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
 )
 
+const (
+	PolicyIP    = "ip"
+	PolicyToken = "token"
+)
+
 type RateLimiter struct {
-	storage storage.Storage
-	config  *config.Config
+	storage    storage.Storage
+	config     atomic.Pointer[config.Config]
+	algorithms map[config.Algorithm]Algorithm
+
+	quotaMu     sync.Mutex
+	dirtyQuotas map[string]storage.Quota
+
+	overrides overrideCache
+
+	observers []Observer
+}
+
+// Observer recebe cada decisão tomada pelo RateLimiter, usado para métricas e
+// logs. ObserveDecision é chamado de forma síncrona e não deve bloquear.
+type Observer interface {
+	ObserveDecision(ctx context.Context, req Request, decision Decision)
+}
+
+// ChargeObserver é implementado pelos observadores que também acompanham as
+// falhas de Charge, como as métricas.
+type ChargeObserver interface {
+	ObserveChargeError(ctx context.Context, decision Decision, err error)
+}
+
+// Request identifica quem faz a requisição e, opcionalmente, a rota acessada,
+// usada para aplicar políticas por rota e caminhos isentos. Size é o tamanho do
+// corpo em bytes, usado quando o custo depende do tamanho da requisição. KeyID
+// identifica um token pelo ID da chave, sem o seu valor, e é usado pela API
+// administrativa no lugar de Token.
+type Request struct {
+	IP     string
+	Token  string
+	KeyID  string
+	Method string
+	Path   string
+	Size   int64
+}
+
+// Decision descreve o resultado da verificação de uma requisição. BlockedUntil
+// só é preenchido quando a chave está bloqueada e BlockIssued indica que o
+// bloqueio foi criado por esta requisição. Exempt indica que o caminho ou o IP
+// não é limitado e Denied que o IP está na denylist. Degraded indica que o
+// storage falhou e a decisão seguiu o FailureMode configurado. Concurrency é o
+// limite de requisições simultâneas da chave, aplicado por Acquire, e Cost o
+// quanto a requisição consumiu do limite. Quota indica o período (daily ou
+// monthly) cuja cota do token foi esgotada; nesse caso Limit, Remaining e
+// ResetAt se referem à cota. Violations é o número de violações recentes da
+// chave bloqueada, que com RATE_LIMIT_BLOCK_FACTOR aumentam o bloqueio.
+// Shadow indica que a política está em modo shadow: a requisição é sempre
+// permitida e ShadowDenied informa se ela seria negada.
+type Decision struct {
+	Allowed      bool
+	Exempt       bool
+	Denied       bool
+	Degraded     bool
+	BlockIssued  bool
+	Shadow       bool
+	ShadowDenied bool
+	Policy       string
+	Key          string
+	Route        string
+	Quota        string
+	Limit        int
+	Cost         int64
+	Algorithm    config.Algorithm
+	Remaining    int64
+	Window       time.Duration
+	ResetAt      time.Time
+	RetryAfter   time.Duration
+	BlockedUntil time.Time
+	Concurrency  int
+	Violations   int64
+
+	// costPolicy é o custo da política aplicada, usado por SizeCost, e quotas
+	// as cotas consumidas em Check, cobradas também por Charge
+	costPolicy config.Cost
+	quotas     []storage.Quota
+}
+
+// SizeCost retorna o custo da requisição para um corpo de size bytes, conforme
+// o custo da política aplicada. Serve para cobrar com Charge o tamanho do corpo
+// que não era conhecido em Check, como o de um corpo sem Content-Length.
+func (d Decision) SizeCost(size int64) int64 {
+	return d.costPolicy.Of(size)
 }
 
 func New(storage storage.Storage, cfg *config.Config) *RateLimiter {
-	return &RateLimiter{
-		storage: storage,
-		config:  cfg,
+	rl := &RateLimiter{
+		storage:    storage,
+		algorithms: newAlgorithms(storage),
 	}
+	rl.config.Store(cfg)
+	return rl
+}
+
+// Config retorna a configuração em uso.
+func (rl *RateLimiter) Config() *config.Config {
+	return rl.config.Load()
+}
+
+// SetConfig troca as políticas em uso sem afetar os contadores já armazenados.
+func (rl *RateLimiter) SetConfig(cfg *config.Config) {
+	rl.config.Store(cfg)
+}
+
+// Observe registra observadores para as decisões. Deve ser chamado antes de o
+// RateLimiter começar a receber requisições.
+func (rl *RateLimiter) Observe(observers ...Observer) {
+	rl.observers = append(rl.observers, observers...)
+}
+
+func (rl *RateLimiter) CheckLimit(ctx context.Context, identifier, token string) (Decision, error) {
+	return rl.Check(ctx, Request{IP: identifier, Token: token})
 }
 
-func (rl *RateLimiter) CheckLimit(ctx context.Context, identifier, token string) (bool, error) {
-	var key string
-	var limit config.RateLimitConfig
+func (rl *RateLimiter) Check(ctx context.Context, req Request) (Decision, error) {
+	decision, err := rl.check(ctx, req)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	for _, observer := range rl.observers {
+		observer.ObserveDecision(ctx, req, decision)
+	}
+	return decision, nil
+}
 
-	if token != "" {
-		key = fmt.Sprintf("token:%s", token)
+func (rl *RateLimiter) check(ctx context.Context, req Request) (Decision, error) {
+	cfg := rl.config.Load()
 
-		if tokenConfig, exists := rl.config.Tokens[token]; exists {
-			limit = tokenConfig
-		} else {
-			limit = rl.config.Token
+	if cfg.IsDenied(req.IP) {
+		return Decision{Allowed: false, Denied: true, Policy: PolicyIP}, nil
+	}
+	if cfg.IsAllowed(req.IP) {
+		return Decision{Allowed: true, Exempt: true, Policy: PolicyIP}, nil
+	}
+	if req.Path != "" && cfg.IsExempt(req.Path) {
+		policy := PolicyIP
+		if req.Token != "" {
+			policy = PolicyToken
 		}
-	} else {
-		key = fmt.Sprintf("ip:%s", identifier)
-		limit = rl.config.IP
+		return Decision{Allowed: true, Exempt: true, Policy: policy}, nil
 	}
 
-	blocked, err := rl.storage.IsBlocked(ctx, key)
+	key, policy, limit := resolve(cfg, req)
+	override, overridden, err := rl.cachedOverride(ctx, cfg, key)
 	if err != nil {
-		return false, fmt.Errorf("error checking block status: %w", err)
+		return degraded(cfg, policy, key, "", fmt.Errorf("error checking override: %w", err))
 	}
-	if blocked {
-		return false, nil
+
+	// as cotas valem para o token como um todo, independente da rota
+	quotaKey, quotaLimit := key, limit
+	if overridden {
+		quotaLimit = override.Limit
+	}
+
+	var route string
+	cost := cfg.Cost
+	if req.Path != "" {
+		if routePolicy, found := cfg.Route(req.Method, req.Path); found {
+			route = routePolicy.Name()
+			key = fmt.Sprintf("%s:%s", key, route)
+			limit = routeLimit(routePolicy, policy)
+			cost = routePolicy.Cost
+		}
+	}
+	if overridden {
+		limit = override.Limit
+	}
+	key = storageKey(key, limit)
+
+	alg, err := rl.algorithm(limit.Algorithm)
+	if err != nil {
+		return Decision{}, err
 	}
 
-	count, err := rl.storage.Increment(ctx, key, limit.Duration)
+	storageLimit := toLimit(cfg, limit)
+	storageLimit.Cost = cost.Of(req.Size)
+
+	result, err := alg.Allow(ctx, key, storageLimit)
 	if err != nil {
-		return false, fmt.Errorf("error incrementing counter: %w", err)
+		return degraded(cfg, policy, key, route, fmt.Errorf("error checking limit: %w", err))
+	}
+
+	now := cfg.Now()
+	decision := Decision{
+		Allowed:     result.Allowed,
+		BlockIssued: result.BlockIssued,
+		Policy:      policy,
+		Key:         key,
+		Route:       route,
+		Limit:       limit.Requests,
+		Cost:        storageLimit.Cost,
+		Algorithm:   limit.Algorithm,
+		Remaining:   result.Remaining,
+		Window:      limit.Duration,
+		ResetAt:     now.Add(result.ResetAfter),
+		RetryAfter:  result.RetryAfter,
+		Concurrency: limit.Concurrency,
+		Violations:  result.Violations,
+		costPolicy:  cost,
+	}
+	if result.Blocked {
+		decision.BlockedUntil = now.Add(result.RetryAfter)
 	}
 
-	if count > int64(limit.Requests) {
-		if err := rl.storage.SetBlock(ctx, key, limit.BlockDuration); err != nil {
-			return false, fmt.Errorf("error setting block: %w", err)
+	// a cota é real: em modo shadow ela não é consumida
+	if decision.Allowed && policy == PolicyToken && !limit.Shadow {
+		decision, err = rl.consumeQuota(ctx, cfg, quotaKey, quotaLimit, storageLimit.Cost, decision)
+		if err != nil {
+			return degraded(cfg, policy, key, route, fmt.Errorf("error checking quota: %w", err))
 		}
-		return false, nil
 	}
 
-	return true, nil
+	// em modo shadow a decisão é registrada, mas a requisição sempre passa
+	if limit.Shadow {
+		decision.Shadow = true
+		decision.ShadowDenied = !decision.Allowed
+		decision.Allowed = true
+	}
+
+	return decision, nil
 }
 
-func (rl *RateLimiter) GetRemainingRequests(ctx context.Context, identifier, token string) (int64, error) {
-	var key string
-	var limit config.RateLimitConfig
-
-	if token != "" {
-		key = fmt.Sprintf("token:%s", token)
-		if tokenConfig, exists := rl.config.Tokens[token]; exists {
-			limit = tokenConfig
-		} else {
-			limit = rl.config.Token
+// degraded decide a requisição quando o storage falha, conforme o FailureMode.
+func degraded(cfg *config.Config, policy, key, route string, err error) (Decision, error) {
+	switch cfg.FailureMode {
+	case config.FailOpen, config.FailLocal:
+		return Decision{Allowed: true, Degraded: true, Policy: policy, Key: key, Route: route}, nil
+	case config.FailClosed:
+		return Decision{Allowed: false, Degraded: true, Policy: policy, Key: key, Route: route}, nil
+	}
+	return Decision{}, err
+}
+
+// Charge cobra o custo final de uma requisição permitida quando ele é maior que
+// o cobrado em Check, como o informado pelo handler depois de processá-la. A
+// diferença é consumida do limite e das cotas do token mesmo que exceda o
+// saldo, sem bloquear a chave, e reduz o que as próximas requisições podem
+// consumir.
+func (rl *RateLimiter) Charge(ctx context.Context, decision Decision, cost int64) error {
+	err := rl.charge(ctx, decision, cost)
+	if err != nil {
+		for _, observer := range rl.observers {
+			if observer, ok := observer.(ChargeObserver); ok {
+				observer.ObserveChargeError(ctx, decision, err)
+			}
 		}
-	} else {
-		key = fmt.Sprintf("ip:%s", identifier)
-		limit = rl.config.IP
 	}
+	return err
+}
+
+func (rl *RateLimiter) charge(ctx context.Context, decision Decision, cost int64) error {
+	if !decision.Allowed || decision.ShadowDenied || decision.Exempt || decision.Degraded || cost <= decision.Cost {
+		return nil
+	}
+
+	alg, err := rl.algorithm(decision.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	_, err = alg.Allow(ctx, decision.Key, storage.Limit{
+		Requests: int64(decision.Limit),
+		Window:   decision.Window,
+		Cost:     cost - decision.Cost,
+		Force:    true,
+	})
+	if err != nil {
+		return fmt.Errorf("error charging cost: %w", err)
+	}
+
+	if len(decision.quotas) == 0 {
+		return nil
+	}
+	quotas := make([]storage.Quota, len(decision.quotas))
+	for i, quota := range decision.quotas {
+		quota.Force = true
+		quotas[i] = quota
+	}
+	if _, err := rl.storage.ConsumeQuota(ctx, quotas, cost-decision.Cost); err != nil {
+		return fmt.Errorf("error charging quota: %w", err)
+	}
+	rl.markQuotas(decision.quotas)
+	return nil
+}
+
+func (rl *RateLimiter) GetRemainingRequests(ctx context.Context, identifier, token string) (int64, error) {
+	key, _, limit := resolve(rl.config.Load(), Request{IP: identifier, Token: token})
 
 	count, err := rl.storage.Get(ctx, key)
 	if err != nil {
@@ -88,3 +319,34 @@ func (rl *RateLimiter) GetRemainingRequests(ctx context.Context, identifier, tok
 
 	return remaining, nil
 }
+
+// storageKey é a chave dos contadores de key. Em modo shadow os contadores,
+// bloqueios e violações ficam em chaves próprias, então a política começa do
+// zero quando passa a valer.
+func storageKey(key string, limit config.RateLimitConfig) string {
+	if limit.Shadow {
+		return "shadow:" + key
+	}
+	return key
+}
+
+func routeLimit(route config.RoutePolicy, policy string) config.RateLimitConfig {
+	if policy == PolicyToken {
+		return route.Token
+	}
+	return route.IP
+}
+
+// resolve retorna a chave, a política e o limite de quem faz a requisição.
+// Tokens são identificados pelo ID da chave, nunca pelo valor.
+func resolve(cfg *config.Config, req Request) (string, string, config.RateLimitConfig) {
+	switch {
+	case req.Token != "":
+		id, limit := cfg.TokenLimit(req.Token)
+		return fmt.Sprintf("token:%s", id), PolicyToken, limit
+	case req.KeyID != "":
+		return fmt.Sprintf("token:%s", req.KeyID), PolicyToken, cfg.KeyLimit(req.KeyID)
+	}
+
+	return fmt.Sprintf("ip:%s", cfg.IPKey(req.IP)), PolicyIP, cfg.IPLimit(req.IP)
+}
@@ -1,10 +1,14 @@
 package limiter
 
 import (
+	"bytes"
 	"context"
+	"net/netip"
 	"testing"
 	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
 	"github.com/stretchr/testify/assert"
@@ -30,14 +34,14 @@ func TestRateLimiter_IPBasedLimiting(t *testing.T) {
 	ip := "192.168.1.1"
 
 	for i := 1; i <= 5; i++ {
-		allowed, err := rl.CheckLimit(ctx, ip, "")
+		decision, err := rl.CheckLimit(ctx, ip, "")
 		require.NoError(t, err)
-		assert.True(t, allowed, "Request %d should be allowed", i)
+		assert.True(t, decision.Allowed, "Request %d should be allowed", i)
 	}
 
-	allowed, err := rl.CheckLimit(ctx, ip, "")
+	decision, err := rl.CheckLimit(ctx, ip, "")
 	require.NoError(t, err)
-	assert.False(t, allowed, "Request 6 should be blocked")
+	assert.False(t, decision.Allowed, "Request 6 should be blocked")
 }
 
 func TestRateLimiter_TokenBasedLimiting(t *testing.T) {
@@ -56,12 +60,536 @@ func TestRateLimiter_TokenBasedLimiting(t *testing.T) {
 	token := "test-token"
 
 	for i := 1; i <= 10; i++ {
-		allowed, err := rl.CheckLimit(ctx, ip, token)
+		decision, err := rl.CheckLimit(ctx, ip, token)
 		require.NoError(t, err)
-		assert.True(t, allowed, "Request %d should be allowed", i)
+		assert.True(t, decision.Allowed, "Request %d should be allowed", i)
 	}
 
-	allowed, err := rl.CheckLimit(ctx, ip, token)
+	decision, err := rl.CheckLimit(ctx, ip, token)
 	require.NoError(t, err)
-	assert.False(t, allowed, "Request 11 should be blocked")
+	assert.False(t, decision.Allowed, "Request 11 should be blocked")
+}
+
+func TestRateLimiter_WindowBoundary(t *testing.T) {
+	window := 500 * time.Millisecond
+
+	tests := []struct {
+		algorithm config.Algorithm
+		allowed   int
+	}{
+		{config.AlgorithmFixedWindow, 4},
+		{config.AlgorithmSlidingLog, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			store := storage.NewMemoryStorage(storage.WithClock(clk))
+			defer store.Close()
+
+			cfg := &config.Config{
+				IP:     config.RateLimitConfig{Requests: 4, Duration: window, Algorithm: tt.algorithm},
+				Tokens: make(map[string]config.RateLimitConfig),
+				Clock:  clk,
+			}
+
+			rl := New(store, cfg)
+			ctx := context.Background()
+			ip := "192.168.1.1"
+
+			decision, err := rl.CheckLimit(ctx, ip, "")
+			require.NoError(t, err)
+			require.True(t, decision.Allowed)
+
+			clk.Advance(400 * time.Millisecond)
+			for i := 0; i < 3; i++ {
+				decision, err := rl.CheckLimit(ctx, ip, "")
+				require.NoError(t, err)
+				require.True(t, decision.Allowed)
+			}
+
+			// cruza a fronteira da primeira janela: a janela fixa zera o contador
+			clk.Advance(150 * time.Millisecond)
+			count := 0
+			for i := 0; i < 4; i++ {
+				decision, err := rl.CheckLimit(ctx, ip, "")
+				require.NoError(t, err)
+				if decision.Allowed {
+					count++
+				}
+			}
+			assert.Equal(t, tt.allowed, count)
+		})
+	}
+}
+
+func TestRateLimiter_AlgorithmPerPolicy(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:    config.RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Token: config.RateLimitConfig{Requests: 10, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("bucket-token"): {Requests: 3, Duration: time.Second, Algorithm: config.AlgorithmTokenBucket},
+		},
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "bucket-token")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "Request %d should be allowed", i)
+	}
+
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "bucket-token")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "Request 4 should be denied")
+	assert.True(t, decision.BlockedUntil.IsZero())
+
+	blocked, err := store.IsBlocked(ctx, "token:"+apikey.HashID(apikey.Hash("bucket-token")))
+	require.NoError(t, err)
+	assert.False(t, blocked, "Policies without block duration should not block")
+
+	cfg.IP.Algorithm = "leaky"
+	_, err = rl.CheckLimit(ctx, "192.168.1.1", "")
+	assert.Error(t, err)
+}
+
+func TestRateLimiter_Decision(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Clock:  clk,
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+	ip := "192.168.1.1"
+
+	decision, err := rl.CheckLimit(ctx, ip, "")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, PolicyIP, decision.Policy)
+	assert.Equal(t, 2, decision.Limit)
+	assert.Equal(t, int64(1), decision.Remaining)
+	assert.Equal(t, time.Second, decision.Window)
+	assert.Equal(t, clk.Now().Add(time.Second), decision.ResetAt)
+	assert.True(t, decision.BlockedUntil.IsZero())
+
+	_, err = rl.CheckLimit(ctx, ip, "")
+	require.NoError(t, err)
+
+	decision, err = rl.CheckLimit(ctx, ip, "")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, int64(0), decision.Remaining)
+	assert.Equal(t, 5*time.Second, decision.RetryAfter)
+	assert.Equal(t, clk.Now().Add(5*time.Second), decision.BlockedUntil)
+}
+
+func TestRateLimiter_RoutePolicies(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 5, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Token:  config.RateLimitConfig{Requests: 10, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Routes: []config.RoutePolicy{
+			{
+				Method:  "POST",
+				Pattern: "/api/data",
+				IP:      config.RateLimitConfig{Requests: 1, Duration: time.Second, BlockDuration: 5 * time.Second},
+				Token:   config.RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: 5 * time.Second},
+			},
+		},
+		ExemptPaths: []string{"/health"},
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+	post := Request{IP: "192.168.1.1", Method: "POST", Path: "/api/data"}
+
+	decision, err := rl.Check(ctx, post)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "POST /api/data", decision.Route)
+	assert.Equal(t, 1, decision.Limit)
+
+	decision, err = rl.Check(ctx, post)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "POST /api/data should have its own stricter budget")
+
+	// o bloqueio da rota não afeta o orçamento global nem outros métodos
+	for i := 1; i <= 5; i++ {
+		decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Method: "GET", Path: "/api/data"})
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "Request %d should be allowed", i)
+		assert.Empty(t, decision.Route)
+	}
+
+	for i := 1; i <= 2; i++ {
+		decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc", Method: "POST", Path: "/api/data"})
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "Token request %d should be allowed", i)
+		assert.Equal(t, 2, decision.Limit)
+	}
+
+	for i := 1; i <= 10; i++ {
+		decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Method: "GET", Path: "/health"})
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.True(t, decision.Exempt)
+	}
+}
+
+func TestRateLimiter_SetConfigKeepsCounters(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 3, Duration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+	ip := "10.0.0.1"
+
+	for i := 1; i <= 3; i++ {
+		decision, err := rl.CheckLimit(ctx, ip, "")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "Request %d should be allowed", i)
+	}
+
+	updated := *cfg
+	updated.IPRanges = []config.IPRangePolicy{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Limit: config.RateLimitConfig{Requests: 4, Duration: time.Minute}},
+	}
+	rl.SetConfig(&updated)
+	assert.Same(t, &updated, rl.Config())
+
+	decision, err := rl.CheckLimit(ctx, ip, "")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed, "The new range limit should apply to the existing counter")
+	assert.Equal(t, 4, decision.Limit)
+
+	decision, err = rl.CheckLimit(ctx, ip, "")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "Counters should survive the reload")
+
+	decision, err = rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, decision.Limit)
+}
+
+type unavailableStorage struct {
+	*storage.MemoryStorage
+}
+
+func (unavailableStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return storage.Result{}, storage.ErrUnavailable
+}
+
+func TestRateLimiter_FailureModes(t *testing.T) {
+	store := unavailableStorage{storage.NewMemoryStorage()}
+	defer store.Close()
+
+	tests := []struct {
+		mode    config.FailureMode
+		allowed bool
+		err     bool
+	}{
+		{config.FailOpen, true, false},
+		{config.FailLocal, true, false},
+		{config.FailClosed, false, false},
+		{"", false, true},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			IP:          config.RateLimitConfig{Requests: 5, Duration: time.Second},
+			Tokens:      make(map[string]config.RateLimitConfig),
+			FailureMode: tt.mode,
+		}
+
+		decision, err := New(store, cfg).CheckLimit(context.Background(), "192.168.1.1", "")
+		if tt.err {
+			assert.ErrorIs(t, err, storage.ErrUnavailable)
+			continue
+		}
+		require.NoError(t, err, tt.mode)
+		assert.True(t, decision.Degraded, tt.mode)
+		assert.Equal(t, tt.allowed, decision.Allowed, tt.mode)
+		assert.Equal(t, "ip:192.168.1.1", decision.Key)
+	}
+}
+
+func TestRateLimiter_WeightedCost(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 100, Duration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Cost:   config.Cost{Bytes: 1000},
+		Routes: []config.RoutePolicy{
+			{Pattern: "/report", IP: config.RateLimitConfig{Requests: 100, Duration: time.Minute}, Cost: config.Cost{Fixed: 50}},
+		},
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	decision, err := rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/report"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), decision.Cost)
+	assert.Equal(t, int64(50), decision.Remaining)
+
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/report"})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/report"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "two expensive requests use the whole budget")
+
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/upload", Size: 2500})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), decision.Cost, "1 plus 1 per started 1000 bytes")
+	assert.Equal(t, int64(96), decision.Remaining)
+
+	// o custo informado depois da resposta consome a diferença
+	require.NoError(t, rl.Charge(ctx, decision, 40))
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/upload"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(59), decision.Remaining)
+
+	require.NoError(t, rl.Charge(ctx, decision, 1), "costs already charged are not charged twice")
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Path: "/upload"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(58), decision.Remaining)
+}
+
+func TestRateLimiter_ProgressiveBlocks(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: 5 * time.Minute},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		BlockFactor:      6,
+		MaxBlockDuration: 24 * time.Hour,
+		ViolationDecay:   24 * time.Hour,
+		Clock:            clk,
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+	req := Request{IP: "192.168.1.1"}
+
+	decision, err := rl.Check(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	for i, block := range []time.Duration{5 * time.Minute, 30 * time.Minute, 3 * time.Hour, 18 * time.Hour, 24 * time.Hour} {
+		decision, err = rl.Check(ctx, req)
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+		assert.True(t, decision.BlockIssued)
+		assert.Equal(t, int64(i+1), decision.Violations)
+		assert.Equal(t, block, decision.RetryAfter)
+		assert.Equal(t, clk.Now().Add(block), decision.BlockedUntil)
+
+		require.NoError(t, rl.Unblock(ctx, req))
+	}
+
+	status, err := rl.Status(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), status.Keys[0].State.Violations)
+
+	// cada dia sem violar esquece uma violação
+	clk.Advance(48 * time.Hour)
+	decision, err = rl.Check(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = rl.Check(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), decision.Violations)
+	assert.Equal(t, 18*time.Hour, decision.RetryAfter)
+	require.NoError(t, rl.Unblock(ctx, req))
+
+	// o fator padrão mantém o bloqueio fixo
+	cfg.BlockFactor = 1
+	decision, err = rl.Check(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, decision.Violations)
+	assert.Equal(t, 5*time.Minute, decision.RetryAfter)
+}
+
+func TestRateLimiter_APIKeys(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	secret := []byte("current")
+	cfg := &config.Config{
+		IP:         config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+		Token:      config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute},
+		Tokens:     map[string]config.RateLimitConfig{apikey.Hash("abc123"): {Requests: 2, Duration: time.Minute}},
+		Plans:      map[string]config.RateLimitConfig{"pro": {Requests: 5, Duration: time.Minute}},
+		KeySecrets: [][]byte{secret},
+	}
+
+	var buf bytes.Buffer
+	rl := New(store, cfg)
+	rl.Observe(NewDenialLogger(&buf))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc123"})
+		require.NoError(t, err)
+	}
+	decision, err := rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Limit)
+	assert.Equal(t, "token:6ca13d52ca70c883", decision.Key)
+	assert.NotEmpty(t, buf.String())
+	assert.NotContains(t, buf.String(), "abc123", "the key never reaches the logs")
+
+	// a chave assinada usa o limite do plano sem estar configurada
+	key, err := apikey.Sign(apikey.Claims{ID: "k1", Plan: "pro"}, secret)
+	require.NoError(t, err)
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Token: key})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 5, decision.Limit)
+	assert.Equal(t, "token:pro.k1", decision.Key)
+
+	// a API administrativa encontra o limite pelo ID
+	status, err := rl.Status(ctx, Request{KeyID: "pro.k1"})
+	require.NoError(t, err)
+	assert.Equal(t, "token:pro.k1", status.Key)
+	assert.Equal(t, 5, status.Limit.Requests)
+	assert.Equal(t, int64(1), status.Keys[0].State.Count)
+}
+
+func TestRateLimiter_Shadow(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: time.Minute, BlockDuration: 5 * time.Minute, Shadow: true},
+		Token:  config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Routes: []config.RoutePolicy{{
+			Pattern: "/api/data",
+			IP:      config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+			Token:   config.RateLimitConfig{Requests: 1, Duration: time.Minute, Shadow: true},
+		}},
+		Clock: clk,
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	for i := 1; i <= 4; i++ {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "Request %d should pass in shadow mode", i)
+		assert.True(t, decision.Shadow)
+		assert.Equal(t, i > 2, decision.ShadowDenied, "Request %d", i)
+	}
+
+	// o bloqueio também é simulado: a chave continua "negada" até ele expirar
+	clk.Advance(time.Minute)
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.True(t, decision.ShadowDenied)
+	assert.False(t, decision.BlockedUntil.IsZero())
+
+	clk.Advance(5 * time.Minute)
+	decision, err = rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.False(t, decision.ShadowDenied)
+
+	// a política da rota decide se a requisição é shadow, não a global
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.2", Path: "/api/data"})
+	require.NoError(t, err)
+	assert.False(t, decision.Shadow)
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.2", Path: "/api/data"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	for i := 1; i <= 2; i++ {
+		decision, err = rl.Check(ctx, Request{Token: "abc123", Path: "/api/data"})
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.True(t, decision.Shadow)
+		assert.Equal(t, i > 1, decision.ShadowDenied)
+	}
+}
+
+func TestRateLimiter_ShadowDoesNotAffectEnforcement(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	shadow := config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Hour, Shadow: true}
+	cfg := &config.Config{
+		IP: shadow,
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 1, Shadow: true},
+		},
+		BlockFactor: 2,
+		Clock:       clk,
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+		assert.Equal(t, i > 1, decision.ShadowDenied, "Request %d", i)
+	}
+	for i := 1; i <= 3; i++ {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "abc123")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Empty(t, decision.Quota, "shadow requests should not consume the quota")
+	}
+
+	// a recarga passa a aplicar as políticas: os clientes negados em shadow
+	// não herdam o bloqueio nem as violações, e a cota está intacta
+	enforced := *cfg
+	enforced.IP.Shadow = false
+	enforced.Tokens = map[string]config.RateLimitConfig{
+		apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 1},
+	}
+	rl.SetConfig(&enforced)
+
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.False(t, decision.Shadow)
+	assert.True(t, decision.BlockedUntil.IsZero())
+
+	decision, err = rl.CheckLimit(ctx, "192.168.1.1", "abc123")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, time.Hour, decision.RetryAfter, "the first enforced block should not be escalated")
 }
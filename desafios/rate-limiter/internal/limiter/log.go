@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// DenialLogger registra cada requisição negada como uma linha JSON com a chave,
+// a política e o orçamento restante. As que seriam negadas por uma política em
+// modo shadow são registradas com nível INFO e shadow=true.
+type DenialLogger struct {
+	logger *slog.Logger
+}
+
+func NewDenialLogger(w io.Writer) *DenialLogger {
+	return &DenialLogger{logger: slog.New(slog.NewJSONHandler(w, nil))}
+}
+
+func (l *DenialLogger) ObserveDecision(ctx context.Context, req Request, d Decision) {
+	if d.Allowed && !d.ShadowDenied {
+		return
+	}
+
+	if d.Denied {
+		l.logger.LogAttrs(ctx, slog.LevelWarn, "access denied",
+			slog.String("ip", req.IP),
+			slog.String("method", req.Method),
+			slog.String("path", req.Path),
+		)
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("key", d.Key),
+		slog.String("policy", d.Policy),
+		slog.String("route", d.Route),
+		slog.String("ip", req.IP),
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.Int("limit", d.Limit),
+		slog.Int64("remaining", d.Remaining),
+		slog.Duration("window", d.Window),
+		slog.Duration("retry_after", d.RetryAfter),
+	}
+	if d.Quota != "" {
+		attrs = append(attrs, slog.String("quota", d.Quota))
+	}
+	if !d.BlockedUntil.IsZero() {
+		attrs = append(attrs,
+			slog.Time("blocked_until", d.BlockedUntil),
+			slog.Bool("block_issued", d.BlockIssued),
+			slog.Int64("violations", d.Violations),
+		)
+	}
+
+	if d.Shadow {
+		attrs = append(attrs, slog.Bool("shadow", true))
+		l.logger.LogAttrs(ctx, slog.LevelInfo, "rate limit exceeded", attrs...)
+		return
+	}
+	l.logger.LogAttrs(ctx, slog.LevelWarn, "rate limit exceeded", attrs...)
+}
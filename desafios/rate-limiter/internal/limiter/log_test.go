@@ -0,0 +1,83 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenialLogger(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	var buf bytes.Buffer
+	rl := New(store, cfg)
+	rl.Observe(NewDenialLogger(&buf))
+	ctx := context.Background()
+	req := Request{IP: "192.168.1.1", Method: "GET", Path: "/api/info"}
+
+	_, err := rl.Check(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, buf.String(), "Allowed requests should not be logged")
+
+	_, err = rl.Check(ctx, req)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "rate limit exceeded", entry["msg"])
+	assert.Equal(t, "ip:192.168.1.1", entry["key"])
+	assert.Equal(t, PolicyIP, entry["policy"])
+	assert.Equal(t, "/api/info", entry["path"])
+	assert.Equal(t, float64(1), entry["limit"])
+	assert.Equal(t, float64(0), entry["remaining"])
+	assert.Equal(t, true, entry["block_issued"])
+	assert.Contains(t, entry, "blocked_until")
+}
+
+func TestDenialLogger_Shadow(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 1, Duration: time.Minute, Shadow: true},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	var buf bytes.Buffer
+	rl := New(store, cfg)
+	rl.Observe(NewDenialLogger(&buf))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1, "Only the request the policy would deny is logged")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "rate limit exceeded", entry["msg"])
+	assert.Equal(t, true, entry["shadow"])
+}
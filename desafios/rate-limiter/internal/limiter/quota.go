@@ -0,0 +1,175 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+)
+
+// Usage é o consumo das cotas de um token nos períodos atuais.
+type Usage struct {
+	Key    string
+	Quotas []QuotaUsage
+}
+
+type QuotaUsage struct {
+	Period    string
+	Limit     int
+	Used      int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// quotaPeriod é uma cota do token no período de calendário que contém agora.
+type quotaPeriod struct {
+	name  string
+	limit int
+	start time.Time
+	end   time.Time
+}
+
+// quotaPeriods retorna as cotas configuradas em limit, com os períodos
+// alinhados ao calendário no fuso loc: o dia e o mês começam à meia-noite local.
+func quotaPeriods(limit config.RateLimitConfig, loc *time.Location, now time.Time) []quotaPeriod {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+
+	var periods []quotaPeriod
+	if limit.Daily > 0 {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		periods = append(periods, quotaPeriod{QuotaDaily, limit.Daily, start, start.AddDate(0, 0, 1)})
+	}
+	if limit.Monthly > 0 {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		periods = append(periods, quotaPeriod{QuotaMonthly, limit.Monthly, start, start.AddDate(0, 1, 0)})
+	}
+	return periods
+}
+
+// storageQuota identifica o contador pelo início do período, então cada dia
+// ou mês tem sua própria chave, ex: quota:token:abc123:daily:2024-01-31.
+func (p quotaPeriod) storageQuota(key string) storage.Quota {
+	layout := "2006-01-02"
+	if p.name == QuotaMonthly {
+		layout = "2006-01"
+	}
+	return storage.Quota{
+		Key:       fmt.Sprintf("quota:%s:%s:%s", key, p.name, p.start.Format(layout)),
+		Limit:     int64(p.limit),
+		ExpiresAt: p.end,
+	}
+}
+
+// consumeQuota consome cost das cotas do token. Quando alguma cota não tem
+// saldo, retorna a decisão negada com o limite e o fim do período da cota.
+func (rl *RateLimiter) consumeQuota(ctx context.Context, cfg *config.Config, key string, limit config.RateLimitConfig, cost int64, decision Decision) (Decision, error) {
+	now := cfg.Now()
+	periods := quotaPeriods(limit, cfg.QuotaLocation, now)
+	if len(periods) == 0 {
+		return decision, nil
+	}
+
+	quotas := make([]storage.Quota, len(periods))
+	for i, period := range periods {
+		quotas[i] = period.storageQuota(key)
+	}
+
+	result, err := rl.storage.ConsumeQuota(ctx, quotas, cost)
+	if err != nil {
+		return Decision{}, err
+	}
+	if result.Allowed {
+		rl.markQuotas(quotas)
+		decision.quotas = quotas
+		return decision, nil
+	}
+
+	period := periods[result.Exceeded]
+	return Decision{
+		Allowed:   false,
+		Policy:    decision.Policy,
+		Key:       decision.Key,
+		Route:     decision.Route,
+		Quota:     period.name,
+		Limit:     period.limit,
+		Cost:      cost,
+		Algorithm: decision.Algorithm,
+		Remaining: max(int64(period.limit)-result.Used[result.Exceeded], 0),
+		Window:    period.end.Sub(period.start),
+		ResetAt:   period.end,
+		// a cota só volta no próximo período
+		RetryAfter: period.end.Sub(now),
+	}, nil
+}
+
+func (rl *RateLimiter) markQuotas(quotas []storage.Quota) {
+	rl.quotaMu.Lock()
+	defer rl.quotaMu.Unlock()
+
+	if rl.dirtyQuotas == nil {
+		rl.dirtyQuotas = make(map[string]storage.Quota)
+	}
+	for _, quota := range quotas {
+		rl.dirtyQuotas[quota.Key] = quota
+	}
+}
+
+// DirtyQuotas retorna as cotas consumidas por esta instância desde a última
+// chamada, para que sejam persistidas.
+func (rl *RateLimiter) DirtyQuotas() []storage.Quota {
+	rl.quotaMu.Lock()
+	defer rl.quotaMu.Unlock()
+
+	quotas := make([]storage.Quota, 0, len(rl.dirtyQuotas))
+	for key, quota := range rl.dirtyQuotas {
+		quotas = append(quotas, quota)
+		delete(rl.dirtyQuotas, key)
+	}
+	return quotas
+}
+
+// Usage retorna o consumo das cotas do token nos períodos atuais.
+func (rl *RateLimiter) Usage(ctx context.Context, token string) (Usage, error) {
+	cfg := rl.config.Load()
+	key, _, limit := resolve(cfg, Request{Token: token})
+	override, found, err := rl.override(ctx, key)
+	if err != nil {
+		return Usage{}, err
+	}
+	if found {
+		limit = override.Limit
+	}
+
+	periods := quotaPeriods(limit, cfg.QuotaLocation, cfg.Now())
+	keys := make([]string, len(periods))
+	for i, period := range periods {
+		keys[i] = period.storageQuota(key).Key
+	}
+
+	used, err := rl.storage.QuotaUsage(ctx, keys)
+	if err != nil {
+		return Usage{}, fmt.Errorf("error getting quota usage: %w", err)
+	}
+
+	usage := Usage{Key: key, Quotas: make([]QuotaUsage, 0, len(periods))}
+	for i, period := range periods {
+		usage.Quotas = append(usage.Quotas, QuotaUsage{
+			Period:    period.name,
+			Limit:     period.limit,
+			Used:      used[i],
+			Remaining: max(int64(period.limit)-used[i], 0),
+			ResetAt:   period.end,
+		})
+	}
+	return usage, nil
+}
@@ -0,0 +1,125 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaPeriods(t *testing.T) {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	require.NoError(t, err)
+
+	// 01:30 UTC de 1º de março ainda é 28 de fevereiro em São Paulo
+	now := time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC)
+	periods := quotaPeriods(config.RateLimitConfig{Daily: 10, Monthly: 100}, loc, now)
+	require.Len(t, periods, 2)
+
+	assert.Equal(t, QuotaDaily, periods[0].name)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, loc), periods[0].start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, loc), periods[0].end)
+	assert.Equal(t, "quota:token:abc:daily:2024-02-29", periods[0].storageQuota("token:abc").Key)
+
+	assert.Equal(t, QuotaMonthly, periods[1].name)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, loc), periods[1].start)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, loc), periods[1].end)
+	assert.Equal(t, "quota:token:abc:monthly:2024-02", periods[1].storageQuota("token:abc").Key)
+
+	assert.Empty(t, quotaPeriods(config.RateLimitConfig{}, loc, now))
+}
+
+func TestRateLimiter_Quotas(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP: config.RateLimitConfig{Requests: 100, Duration: time.Minute},
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 3, Monthly: 5},
+		},
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		decision, err := rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc123"})
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+		assert.Empty(t, decision.Quota)
+	}
+
+	decision, err := rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, QuotaDaily, decision.Quota)
+	assert.Equal(t, 3, decision.Limit)
+	assert.Equal(t, int64(0), decision.Remaining)
+
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, midnight, decision.ResetAt)
+	assert.InDelta(t, time.Until(midnight).Seconds(), decision.RetryAfter.Seconds(), 1)
+
+	// cotas valem apenas para tokens
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1"})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	usage, err := rl.Usage(ctx, "abc123")
+	require.NoError(t, err)
+	require.Len(t, usage.Quotas, 2)
+	assert.Equal(t, QuotaUsage{Period: QuotaDaily, Limit: 3, Used: 3, Remaining: 0, ResetAt: midnight}, usage.Quotas[0])
+	assert.Equal(t, int64(3), usage.Quotas[1].Used)
+	assert.Equal(t, int64(2), usage.Quotas[1].Remaining)
+
+	dirty := rl.DirtyQuotas()
+	assert.Len(t, dirty, 2)
+	assert.Empty(t, rl.DirtyQuotas(), "dirty quotas are drained")
+
+	// o override também substitui as cotas
+	_, err = rl.SetOverride(ctx, Request{Token: "abc123"}, config.RateLimitConfig{Requests: 100, Duration: time.Minute, Daily: 10}, time.Minute)
+	require.NoError(t, err)
+	decision, err = rl.Check(ctx, Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestRateLimiter_ChargeConsumesQuotas(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 10, Monthly: 20},
+		},
+	}
+
+	rl := New(store, cfg)
+	ctx := context.Background()
+
+	decision, err := rl.Check(ctx, Request{Token: "abc123"})
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+	rl.DirtyQuotas()
+
+	// o custo final passa do saldo da cota diária e é cobrado mesmo assim
+	require.NoError(t, rl.Charge(ctx, decision, 12))
+
+	usage, err := rl.Usage(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), usage.Quotas[0].Used)
+	assert.Equal(t, int64(12), usage.Quotas[1].Used)
+	assert.Len(t, rl.DirtyQuotas(), 2, "charged quotas are persisted")
+
+	decision, err = rl.Check(ctx, Request{Token: "abc123"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, QuotaDaily, decision.Quota)
+}
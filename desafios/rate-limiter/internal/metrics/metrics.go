@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ResultAllowed = "allowed"
+	ResultDenied  = "denied"
+	ResultExempt  = "exempt"
+
+	// decisões tomadas com o storage indisponível
+	ResultFailOpen   = "fail_open"
+	ResultFailClosed = "fail_closed"
+
+	// decisões de políticas em modo shadow, que nunca negam a requisição
+	ResultShadowAllowed = "shadow_allowed"
+	ResultShadowDenied  = "shadow_denied"
+
+	// políticas que não correspondem a uma rota configurada
+	PolicyGlobal   = "global"
+	PolicyDenylist = "denylist"
+	PolicyExempt   = "exempt"
+
+	// negações por cota esgotada, em vez da rota
+	PolicyQuotaDaily   = "quota_daily"
+	PolicyQuotaMonthly = "quota_monthly"
+)
+
+// Metrics contabiliza as decisões do RateLimiter e a latência do storage. Os
+// labels usam apenas o tipo da chave e a política, nunca o IP ou o token.
+type Metrics struct {
+	decisions       *prometheus.CounterVec
+	blocks          *prometheus.CounterVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+	chargeErrors    *prometheus.CounterVec
+	circuitOpen     prometheus.Gauge
+}
+
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_decisions_total",
+			Help: "Rate limit decisions by key type, policy and result.",
+		}, []string{"key_type", "policy", "result"}),
+		blocks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_blocks_total",
+			Help: "Blocks issued after a key exceeded its limit.",
+		}, []string{"key_type", "policy"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ratelimit_storage_duration_seconds",
+			Help:    "Latency of rate limit storage operations.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 15),
+		}, []string{"operation"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_storage_errors_total",
+			Help: "Failed rate limit storage operations.",
+		}, []string{"operation"}),
+		chargeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_charge_errors_total",
+			Help: "Failures charging the final cost of a request after the response.",
+		}, []string{"key_type", "policy"}),
+		circuitOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ratelimit_storage_circuit_open",
+			Help: "Whether the circuit breaker around the storage is open (1) or closed (0).",
+		}),
+	}
+
+	reg.MustRegister(m.decisions, m.blocks, m.storageDuration, m.storageErrors, m.chargeErrors, m.circuitOpen)
+	return m
+}
+
+func (m *Metrics) ObserveDecision(ctx context.Context, req limiter.Request, d limiter.Decision) {
+	policy, result := d.Route, ResultAllowed
+	if policy == "" {
+		policy = PolicyGlobal
+	}
+
+	switch {
+	case d.Degraded && d.Allowed:
+		result = ResultFailOpen
+	case d.Degraded:
+		result = ResultFailClosed
+	case d.Denied:
+		policy, result = PolicyDenylist, ResultDenied
+	case d.Exempt:
+		policy, result = PolicyExempt, ResultExempt
+	case d.Quota == limiter.QuotaDaily:
+		policy, result = PolicyQuotaDaily, ResultDenied
+	case d.Quota == limiter.QuotaMonthly:
+		policy, result = PolicyQuotaMonthly, ResultDenied
+	case !d.Allowed:
+		result = ResultDenied
+	}
+	if d.Shadow {
+		result = ResultShadowAllowed
+		if d.ShadowDenied {
+			result = ResultShadowDenied
+		}
+	}
+
+	m.decisions.WithLabelValues(d.Policy, policy, result).Inc()
+	if d.BlockIssued && !d.Shadow {
+		m.blocks.WithLabelValues(d.Policy, policy).Inc()
+	}
+}
+
+// ObserveChargeError conta as falhas ao cobrar o custo final de uma requisição,
+// que não mudam a resposta e só aparecem aqui e nos logs.
+func (m *Metrics) ObserveChargeError(ctx context.Context, d limiter.Decision, err error) {
+	policy := d.Route
+	if policy == "" {
+		policy = PolicyGlobal
+	}
+	m.chargeErrors.WithLabelValues(d.Policy, policy).Inc()
+}
+
+// SetCircuitOpen registra o estado do circuit breaker do storage.
+func (m *Metrics) SetCircuitOpen(open bool) {
+	if open {
+		m.circuitOpen.Set(1)
+		return
+	}
+	m.circuitOpen.Set(0)
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Decisions(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:          config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute},
+		Token:       config.RateLimitConfig{Requests: 5, Duration: time.Minute},
+		Tokens:      make(map[string]config.RateLimitConfig),
+		ExemptPaths: []string{"/health"},
+		Routes: []config.RoutePolicy{{
+			Method:  "POST",
+			Pattern: "/api/data",
+			IP:      config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+			Token:   config.RateLimitConfig{Requests: 1, Duration: time.Minute},
+		}},
+		DenyList: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}
+
+	m := New(prometheus.NewRegistry())
+	rl := limiter.New(m.Storage(store), cfg)
+	rl.Observe(m)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+		require.NoError(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := rl.Check(ctx, limiter.Request{Token: "abc123", Method: "POST", Path: "/api/data"})
+		require.NoError(t, err)
+	}
+	_, err := rl.Check(ctx, limiter.Request{IP: "192.168.1.1", Method: "GET", Path: "/health"})
+	require.NoError(t, err)
+	_, err = rl.CheckLimit(ctx, "203.0.113.5", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyGlobal, ResultAllowed)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyGlobal, ResultDenied)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("token", "POST /api/data", ResultAllowed)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("token", "POST /api/data", ResultDenied)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyExempt, ResultExempt)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyDenylist, ResultDenied)))
+
+	// apenas a requisição que excedeu o limite cria o bloqueio
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.blocks.WithLabelValues("ip", PolicyGlobal)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.blocks.WithLabelValues("token", "POST /api/data")))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.storageDuration), "get_override and fixed_window are the only operations used")
+	assert.Equal(t, 0, testutil.CollectAndCount(m.storageErrors))
+}
+
+func TestMetrics_Shadow(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: time.Minute, Shadow: true},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	m := New(prometheus.NewRegistry())
+	rl := limiter.New(store, cfg)
+	rl.Observe(m)
+
+	for i := 0; i < 3; i++ {
+		_, err := rl.CheckLimit(context.Background(), "192.168.1.1", "")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyGlobal, ResultShadowAllowed)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyGlobal, ResultShadowDenied)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.decisions.WithLabelValues("ip", PolicyGlobal, ResultDenied)))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.blocks), "Shadow blocks are not counted as real blocks")
+}
+
+func TestMetrics_StorageErrors(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	store := m.Storage(failingStorage{Storage: storage.NewMemoryStorage()})
+	defer store.Close()
+
+	_, err := store.FixedWindow(context.Background(), "key", storage.Limit{Requests: 1, Window: time.Second})
+	assert.Error(t, err)
+
+	_, err = store.Get(context.Background(), "key")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.storageErrors.WithLabelValues("fixed_window")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.storageDuration))
+}
+
+type failingStorage struct {
+	storage.Storage
+}
+
+func (failingStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return storage.Result{}, errors.New("connection refused")
+}
+
+type chargeFailingStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s chargeFailingStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	if limit.Force {
+		return storage.Result{}, errors.New("storage down")
+	}
+	return s.MemoryStorage.FixedWindow(ctx, key, limit)
+}
+
+func TestMetrics_ChargeErrors(t *testing.T) {
+	store := chargeFailingStorage{storage.NewMemoryStorage()}
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 10, Duration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	m := New(prometheus.NewRegistry())
+	rl := limiter.New(store, cfg)
+	rl.Observe(m)
+	ctx := context.Background()
+
+	decision, err := rl.CheckLimit(ctx, "192.168.1.1", "")
+	require.NoError(t, err)
+	assert.Error(t, rl.Charge(ctx, decision, 5))
+	require.NoError(t, rl.Charge(ctx, decision, 1), "costs already charged do not reach the storage")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.chargeErrors.WithLabelValues("ip", PolicyGlobal)))
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+// Storage envolve store medindo a latência e os erros de cada operação.
+func (m *Metrics) Storage(store storage.Storage) storage.Storage {
+	return &instrumentedStorage{next: store, metrics: m}
+}
+
+type instrumentedStorage struct {
+	next    storage.Storage
+	metrics *Metrics
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.metrics.storageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (s *instrumentedStorage) Increment(ctx context.Context, key string, expiration time.Duration) (count int64, err error) {
+	defer func(start time.Time) { s.observe("increment", start, err) }(time.Now())
+	return s.next.Increment(ctx, key, expiration)
+}
+
+func (s *instrumentedStorage) IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (count int64, err error) {
+	defer func(start time.Time) { s.observe("increment_by", start, err) }(time.Now())
+	return s.next.IncrementBy(ctx, key, n, expiration)
+}
+
+func (s *instrumentedStorage) Get(ctx context.Context, key string) (count int64, err error) {
+	defer func(start time.Time) { s.observe("get", start, err) }(time.Now())
+	return s.next.Get(ctx, key)
+}
+
+func (s *instrumentedStorage) SetBlock(ctx context.Context, key string, duration time.Duration) (err error) {
+	defer func(start time.Time) { s.observe("set_block", start, err) }(time.Now())
+	return s.next.SetBlock(ctx, key, duration)
+}
+
+func (s *instrumentedStorage) IsBlocked(ctx context.Context, key string) (blocked bool, err error) {
+	defer func(start time.Time) { s.observe("is_blocked", start, err) }(time.Now())
+	return s.next.IsBlocked(ctx, key)
+}
+
+func (s *instrumentedStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (result storage.Result, err error) {
+	defer func(start time.Time) { s.observe("fixed_window", start, err) }(time.Now())
+	return s.next.FixedWindow(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) SlidingLog(ctx context.Context, key string, limit storage.Limit) (result storage.Result, err error) {
+	defer func(start time.Time) { s.observe("sliding_log", start, err) }(time.Now())
+	return s.next.SlidingLog(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) SlidingWindow(ctx context.Context, key string, limit storage.Limit) (result storage.Result, err error) {
+	defer func(start time.Time) { s.observe("sliding_window", start, err) }(time.Now())
+	return s.next.SlidingWindow(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) TokenBucket(ctx context.Context, key string, limit storage.Limit) (result storage.Result, err error) {
+	defer func(start time.Time) { s.observe("token_bucket", start, err) }(time.Now())
+	return s.next.TokenBucket(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) GCRA(ctx context.Context, key string, limit storage.Limit) (result storage.Result, err error) {
+	defer func(start time.Time) { s.observe("gcra", start, err) }(time.Now())
+	return s.next.GCRA(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) Inspect(ctx context.Context, key string, limit storage.Limit) (state storage.State, err error) {
+	defer func(start time.Time) { s.observe("inspect", start, err) }(time.Now())
+	return s.next.Inspect(ctx, key, limit)
+}
+
+func (s *instrumentedStorage) Unblock(ctx context.Context, key string) (err error) {
+	defer func(start time.Time) { s.observe("unblock", start, err) }(time.Now())
+	return s.next.Unblock(ctx, key)
+}
+
+func (s *instrumentedStorage) Reset(ctx context.Context, key string) (err error) {
+	defer func(start time.Time) { s.observe("reset", start, err) }(time.Now())
+	return s.next.Reset(ctx, key)
+}
+
+func (s *instrumentedStorage) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (acquired bool, err error) {
+	defer func(start time.Time) { s.observe("acquire", start, err) }(time.Now())
+	return s.next.Acquire(ctx, key, id, limit, lease)
+}
+
+func (s *instrumentedStorage) Renew(ctx context.Context, key, id string, lease time.Duration) (err error) {
+	defer func(start time.Time) { s.observe("renew", start, err) }(time.Now())
+	return s.next.Renew(ctx, key, id, lease)
+}
+
+func (s *instrumentedStorage) Release(ctx context.Context, key, id string) (err error) {
+	defer func(start time.Time) { s.observe("release", start, err) }(time.Now())
+	return s.next.Release(ctx, key, id)
+}
+
+func (s *instrumentedStorage) ConsumeQuota(ctx context.Context, quotas []storage.Quota, cost int64) (result storage.QuotaResult, err error) {
+	defer func(start time.Time) { s.observe("consume_quota", start, err) }(time.Now())
+	return s.next.ConsumeQuota(ctx, quotas, cost)
+}
+
+func (s *instrumentedStorage) QuotaUsage(ctx context.Context, keys []string) (used []int64, err error) {
+	defer func(start time.Time) { s.observe("quota_usage", start, err) }(time.Now())
+	return s.next.QuotaUsage(ctx, keys)
+}
+
+func (s *instrumentedStorage) RestoreQuota(ctx context.Context, quota storage.Quota, used int64) (err error) {
+	defer func(start time.Time) { s.observe("restore_quota", start, err) }(time.Now())
+	return s.next.RestoreQuota(ctx, quota, used)
+}
+
+func (s *instrumentedStorage) SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	defer func(start time.Time) { s.observe("set_override", start, err) }(time.Now())
+	return s.next.SetOverride(ctx, key, value, ttl)
+}
+
+func (s *instrumentedStorage) GetOverride(ctx context.Context, key string) (value []byte, err error) {
+	defer func(start time.Time) { s.observe("get_override", start, err) }(time.Now())
+	return s.next.GetOverride(ctx, key)
+}
+
+func (s *instrumentedStorage) DeleteOverride(ctx context.Context, key string) (deleted bool, err error) {
+	defer func(start time.Time) { s.observe("delete_override", start, err) }(time.Now())
+	return s.next.DeleteOverride(ctx, key)
+}
+
+func (s *instrumentedStorage) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+)
+
+func getClientIP(r *http.Request, cfg *config.Config) string {
+	return ClientIP(r.RemoteAddr, r.Header, cfg)
+}
+
+// ClientIP retorna o IP do cliente a partir do endereço da conexão e dos
+// headers. Os headers Forwarded (RFC 7239), X-Forwarded-For e X-Real-IP só são
+// considerados quando a conexão vem de um proxy confiável; nesse caso a cadeia é
+// percorrida da direita para a esquerda e o primeiro endereço que não é de um
+// proxy confiável é o cliente. Assim, um cliente não consegue forjar sua
+// identidade adicionando entradas à esquerda.
+func ClientIP(remoteAddr string, header http.Header, cfg *config.Config) string {
+	remote, ok := parseRemoteAddr(remoteAddr)
+	if !ok {
+		return remoteAddr
+	}
+	if !cfg.IsTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	hops, found := forwardedFor(header)
+	if !found {
+		hops, found = xForwardedFor(header)
+	}
+	if !found {
+		if addr, ok := parseAddr(header.Get("X-Real-IP")); ok {
+			return addr.String()
+		}
+		return remote.String()
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			// identificador ofuscado ou inválido: o último proxy conhecido é o
+			// endereço mais próximo do cliente em que podemos confiar
+			break
+		}
+		client = addr
+		if !cfg.IsTrustedProxy(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return parseAddr(host)
+}
+
+// parseAddr aceita endereços IPv4 e IPv6, com ou sem porta e colchetes, e
+// normaliza IPv4 mapeado em IPv6 e zonas.
+func parseAddr(value string) (netip.Addr, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+func xForwardedFor(h http.Header) ([]string, bool) {
+	values := h.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	var hops []string
+	for _, value := range values {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	return hops, true
+}
+
+// forwardedFor extrai os parâmetros for= do header Forwarded, por exemplo
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711".
+func forwardedFor(h http.Header) ([]string, bool) {
+	values := h.Values("Forwarded")
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, val)
+				}
+			}
+		}
+	}
+	return hops, len(hops) > 0
+}
+
+func splitQuoted(value string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case sep:
+			if !quoted {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, value[start:])
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClientIP(t *testing.T) {
+	cfg := &config.Config{
+		TrustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("fd00::/8"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"IPv4 remote address", "192.168.1.1:1234", nil, "192.168.1.1"},
+		{"IPv6 remote address", "[2001:db8::1]:1234", nil, "2001:db8::1"},
+		{"IPv6 remote address with zone", "[fe80::1%eth0]:1234", nil, "fe80::1"},
+		{"IPv4-mapped remote address", "[::ffff:192.168.1.1]:1234", nil, "192.168.1.1"},
+		{
+			"untrusted client cannot spoof X-Forwarded-For",
+			"192.168.1.1:1234",
+			map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			"192.168.1.1",
+		},
+		{
+			"untrusted client cannot spoof X-Real-IP",
+			"192.168.1.1:1234",
+			map[string]string{"X-Real-IP": "1.2.3.4"},
+			"192.168.1.1",
+		},
+		{
+			"trusted proxy X-Forwarded-For",
+			"10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			"203.0.113.7",
+		},
+		{
+			"spoofed entries left of the real client are ignored",
+			"10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.2"},
+			"203.0.113.7",
+		},
+		{
+			"trusted proxy X-Real-IP",
+			"10.0.0.1:1234",
+			map[string]string{"X-Real-IP": "203.0.113.7"},
+			"203.0.113.7",
+		},
+		{
+			"Forwarded header takes precedence",
+			"10.0.0.1:1234",
+			map[string]string{
+				"Forwarded":       `for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			"2001:db8:cafe::17",
+		},
+		{
+			"Forwarded with IPv4 and port",
+			"[fd00::1]:1234",
+			map[string]string{"Forwarded": `for="192.0.2.60:8080";by=10.0.0.1`},
+			"192.0.2.60",
+		},
+		{
+			"obfuscated Forwarded identifier falls back to the nearest proxy",
+			"10.0.0.1:1234",
+			map[string]string{"Forwarded": `for=203.0.113.7, for=_hidden, for=10.0.0.2`},
+			"10.0.0.2",
+		},
+		{
+			"all hops trusted uses the leftmost",
+			"10.0.0.1:1234",
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			"10.0.0.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			assert.Equal(t, tt.expected, getClientIP(req, cfg))
+		})
+	}
+}
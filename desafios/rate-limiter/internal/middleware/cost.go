@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// costWriter captura o header de custo definido pelo handler e o remove antes
+// de a resposta ser enviada, já que ele só interessa ao rate limiter.
+type costWriter struct {
+	http.ResponseWriter
+	header      string
+	cost        int64
+	wroteHeader bool
+}
+
+func (w *costWriter) WriteHeader(code int) {
+	w.capture()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *costWriter) Write(b []byte) (int, error) {
+	w.capture()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *costWriter) Flush() {
+	w.capture()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *costWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *costWriter) capture() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	if value := h.Get(w.header); value != "" {
+		if cost, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && cost > 0 {
+			w.cost = cost
+		}
+		h.Del(w.header)
+	}
+}
+
+// countingReader conta os bytes do corpo lidos pelo handler.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.n += int64(n)
+	return n, err
+}
+
+// requestSize retorna o tamanho declarado do corpo, ou 0 quando desconhecido.
+func requestSize(r *http.Request) int64 {
+	if r.ContentLength > 0 {
+		return r.ContentLength
+	}
+	return 0
+}
@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
 )
@@ -10,50 +15,223 @@ import (
 const (
 	HeaderAPIKey             = "API_KEY"
 	MessageRateLimitExceeded = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+	MessageAccessDenied      = "access denied"
+	MessageUnavailable       = "rate limiter unavailable"
+	MessageConcurrencyLimit  = "too many concurrent requests"
+	MessageQuotaExceeded     = "you have reached your usage quota for the current period"
 )
 
-func RateLimiterMiddleware(rl *limiter.RateLimiter) func(http.Handler) http.Handler {
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimit          = "RateLimit"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+	HeaderRateLimitShadow    = "X-RateLimit-Shadow"
+)
+
+// Valores de X-RateLimit-Shadow: a decisão que a política em modo shadow teria
+// tomado.
+const (
+	ShadowAllow = "allow"
+	ShadowDeny  = "deny"
+)
+
+// Option configura o RateLimiterMiddleware.
+type Option func(*options)
+
+type options struct {
+	logger *slog.Logger
+	ipOnly bool
+}
+
+// WithLogger troca o logger das falhas que não mudam a resposta, como a
+// cobrança do custo final. O padrão é slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithIPOnly limita as requisições só pelo IP, ignorando o header API_KEY,
+// para rotas que não devem consumir o limite nem as cotas do token, como
+// /usage.
+func WithIPOnly() Option {
+	return func(o *options) {
+		o.ipOnly = true
+	}
+}
+
+func RateLimiterMiddleware(rl *limiter.RateLimiter, opts ...Option) func(http.Handler) http.Handler {
+	o := options{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-			token := r.Header.Get(HeaderAPIKey)
-
-			allowed, err := rl.CheckLimit(r.Context(), ip, token)
+			req := limiter.Request{
+				IP:     getClientIP(r, rl.Config()),
+				Token:  r.Header.Get(HeaderAPIKey),
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Size:   requestSize(r),
+			}
+			if o.ipOnly {
+				req.Token = ""
+			}
+			decision, err := rl.Check(r.Context(), req)
 			if err != nil {
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				return
 			}
 
-			if !allowed {
+			if decision.Denied {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"message":"` + MessageAccessDenied + `"}`))
+				return
+			}
+
+			if decision.Exempt {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// com o storage indisponível não há contadores para informar nos headers
+			if decision.Degraded {
+				if !decision.Allowed {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"message":"` + MessageUnavailable + `"}`))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// a política em modo shadow não é aplicada, então o cliente só vê a
+			// decisão que ela teria tomado, sem os headers de limite
+			if decision.Shadow {
+				w.Header().Set(HeaderRateLimitShadow, ShadowResult(decision))
+			} else {
+				SetRateLimitHeaders(w.Header(), decision, rl.Config().Now())
+			}
+
+			if !decision.Allowed {
+				message := MessageRateLimitExceeded
+				if decision.Quota != "" {
+					message = MessageQuotaExceeded
+				}
+				WriteTooManyRequests(w, message, decision)
+				return
+			}
+
+			release, err := rl.Acquire(r.Context(), decision)
+			switch {
+			case errors.Is(err, limiter.ErrConcurrencyLimit):
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
-				w.Write([]byte(`{"message":"` + MessageRateLimitExceeded + `"}`))
+				w.Write([]byte(`{"message":"` + MessageConcurrencyLimit + `"}`))
+				return
+			case errors.Is(err, limiter.ErrUnavailable):
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"message":"` + MessageUnavailable + `"}`))
 				return
+			case err != nil:
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			defer release()
+
+			// o corpo é contado enquanto o handler o lê, já que sem Content-Length
+			// (ex: Transfer-Encoding: chunked) o tamanho só é conhecido no fim
+			body := &countingReader{ReadCloser: http.NoBody}
+			if r.Body != nil {
+				body.ReadCloser = r.Body
 			}
+			r.Body = body
 
+			// o handler pode informar o custo real da requisição em um header de
+			// resposta, cobrado depois que ela termina
+			var cw *costWriter
+			if costHeader := rl.Config().CostHeader; costHeader != "" {
+				cw = &costWriter{ResponseWriter: w, header: costHeader}
+				w = cw
+			}
 			next.ServeHTTP(w, r)
+
+			cost := decision.SizeCost(body.n)
+			if cw != nil {
+				cw.capture()
+				cost = max(cost, cw.cost)
+			}
+			// a resposta já foi enviada: a falha só é registrada, e contada pelos
+			// observadores do RateLimiter
+			if err := rl.Charge(context.WithoutCancel(r.Context()), decision, cost); err != nil {
+				o.logger.LogAttrs(r.Context(), slog.LevelError, "failed to charge request cost",
+					slog.String("key", decision.Key),
+					slog.String("policy", decision.Policy),
+					slog.String("route", decision.Route),
+					slog.Int64("cost", cost),
+					slog.String("error", err.Error()),
+				)
+			}
 		})
 	}
 }
 
-func getClientIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+// SetRateLimitHeaders escreve os headers X-RateLimit-* de uso comum e os campos
+// RateLimit e RateLimit-Policy do draft da IETF (draft-ietf-httpapi-ratelimit-headers).
+func SetRateLimitHeaders(h http.Header, d limiter.Decision, now time.Time) {
+	resetAt := d.ResetAt
+	if !d.BlockedUntil.IsZero() && d.BlockedUntil.After(resetAt) {
+		resetAt = d.BlockedUntil
 	}
+	resetIn := seconds(resetAt.Sub(now))
 
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
+	h.Set(HeaderRateLimitLimit, strconv.Itoa(d.Limit))
+	h.Set(HeaderRateLimitRemaining, strconv.FormatInt(d.Remaining, 10))
+	h.Set(HeaderRateLimitReset, strconv.FormatInt(now.Unix()+resetIn, 10))
+	h.Set(HeaderRateLimit, fmt.Sprintf(`"%s";r=%d;t=%d`, d.Policy, d.Remaining, resetIn))
+	h.Set(HeaderRateLimitPolicy, fmt.Sprintf(`"%s";q=%d;w=%d`, d.Policy, d.Limit, seconds(d.Window)))
+
+	if !d.Allowed {
+		h.Set(HeaderRetryAfter, strconv.FormatInt(seconds(d.RetryAfter), 10))
 	}
+}
 
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+// ShadowResult é o valor de X-RateLimit-Shadow para uma decisão em modo shadow.
+func ShadowResult(d limiter.Decision) string {
+	if d.ShadowDenied {
+		return ShadowDeny
 	}
+	return ShadowAllow
+}
 
-	return ip
+// WriteTooManyRequests responde 429 com a mensagem e, quando a chave está
+// bloqueada, o fim do bloqueio.
+func WriteTooManyRequests(w http.ResponseWriter, message string, d limiter.Decision) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write(tooManyRequestsBody(message, d))
+}
+
+// tooManyRequestsBody informa, quando a chave está bloqueada, até quando e
+// quantas violações recentes levaram ao bloqueio.
+func tooManyRequestsBody(message string, d limiter.Decision) []byte {
+	if d.BlockedUntil.IsZero() {
+		return []byte(`{"message":"` + message + `"}`)
+	}
+	return []byte(fmt.Sprintf(`{"message":%q,"blocked_until":%q,"violations":%d}`,
+		message, d.BlockedUntil.UTC().Format(time.RFC3339), d.Violations))
+}
+
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
 }
@@ -1,15 +1,25 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
 	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRateLimiterMiddleware_AllowsRequestsUnderLimit(t *testing.T) {
@@ -79,3 +89,519 @@ func TestRateLimiterMiddleware_BlocksRequestsOverLimit(t *testing.T) {
 	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Request 4 should return 429")
 	assert.Contains(t, w.Body.String(), MessageRateLimitExceeded)
 }
+
+func TestRateLimiterMiddleware_SetsRateLimitHeaders(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1700000000, 0))
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: 10 * time.Second, BlockDuration: time.Minute},
+		Token:  config.RateLimitConfig{Requests: 10, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Clock:  clk,
+	}
+
+	rl := limiter.New(store, cfg)
+	handler := RateLimiterMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(HeaderRateLimitLimit))
+	assert.Equal(t, "1", w.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "1700000010", w.Header().Get(HeaderRateLimitReset))
+	assert.Equal(t, `"ip";r=1;t=10`, w.Header().Get(HeaderRateLimit))
+	assert.Equal(t, `"ip";q=2;w=10`, w.Header().Get(HeaderRateLimitPolicy))
+	assert.Empty(t, w.Header().Get(HeaderRetryAfter))
+
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderRateLimitRemaining))
+	assert.Equal(t, "60", w.Header().Get(HeaderRetryAfter))
+	assert.Equal(t, `"ip";r=0;t=60`, w.Header().Get(HeaderRateLimit))
+
+	// continua informando o Retry-After restante enquanto a chave estiver bloqueada
+	clk.Advance(45 * time.Second)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "15", w.Header().Get(HeaderRetryAfter))
+	assert.Equal(t, "1700000060", w.Header().Get(HeaderRateLimitReset))
+}
+
+func TestRateLimiterMiddleware_RoutePoliciesAndExemptPaths(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 5, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Token:  config.RateLimitConfig{Requests: 10, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Routes: []config.RoutePolicy{
+			{
+				Method:  "POST",
+				Pattern: "/api/data",
+				IP:      config.RateLimitConfig{Requests: 1, Duration: time.Second, BlockDuration: 5 * time.Second},
+			},
+		},
+		ExemptPaths: []string{"/health"},
+	}
+
+	rl := limiter.New(store, cfg)
+	handler := RateLimiterMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("POST", "/api/data").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("POST", "/api/data").Code)
+	assert.Equal(t, http.StatusOK, serve("GET", "/api/info").Code)
+
+	for i := 1; i <= 10; i++ {
+		w := serve("GET", "/health")
+		assert.Equal(t, http.StatusOK, w.Code, "Health check %d should not be limited", i)
+		assert.Empty(t, w.Header().Get(HeaderRateLimitLimit))
+	}
+}
+
+func TestRateLimiterMiddleware_AllowAndDenyLists(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:        config.RateLimitConfig{Requests: 1, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Token:     config.RateLimitConfig{Requests: 10, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens:    make(map[string]config.RateLimitConfig),
+		AllowList: []netip.Prefix{netip.MustParsePrefix("192.168.0.0/24")},
+		DenyList:  []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}
+
+	rl := limiter.New(store, cfg)
+	handler := RateLimiterMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set(HeaderAPIKey, token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 1; i <= 5; i++ {
+		assert.Equal(t, http.StatusOK, serve("192.168.0.10:1234", "").Code, "Allowlisted request %d should not be limited", i)
+	}
+
+	w := serve("203.0.113.5:1234", "abc123")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), MessageAccessDenied)
+
+	assert.Equal(t, http.StatusOK, serve("198.51.100.1:1234", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("198.51.100.1:1234", "").Code)
+}
+
+func TestRateLimiterMiddleware_IPv6Aggregation(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:         config.RateLimitConfig{Requests: 2, Duration: time.Second, BlockDuration: 5 * time.Second},
+		Tokens:     make(map[string]config.RateLimitConfig),
+		IPv6Prefix: 64,
+	}
+
+	rl := limiter.New(store, cfg)
+	handler := RateLimiterMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("[2001:db8:1:2::1]:1234"))
+	assert.Equal(t, http.StatusOK, serve("[2001:db8:1:2::ffff]:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("[2001:db8:1:2:aaaa::1]:1234"), "Addresses in the same /64 share the budget")
+	assert.Equal(t, http.StatusOK, serve("[2001:db8:1:3::1]:1234"))
+}
+
+type unavailableStorage struct {
+	*storage.MemoryStorage
+}
+
+func (unavailableStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	return storage.Result{}, storage.ErrUnavailable
+}
+
+func TestRateLimiterMiddleware_FailureModes(t *testing.T) {
+	store := unavailableStorage{storage.NewMemoryStorage()}
+	defer store.Close()
+
+	tests := []struct {
+		mode config.FailureMode
+		code int
+	}{
+		{config.FailOpen, http.StatusOK},
+		{config.FailClosed, http.StatusServiceUnavailable},
+		{"", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{
+			IP:          config.RateLimitConfig{Requests: 5, Duration: time.Second},
+			Tokens:      make(map[string]config.RateLimitConfig),
+			FailureMode: tt.mode,
+		}
+
+		handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.code, w.Code, tt.mode)
+		assert.Empty(t, w.Header().Get(HeaderRateLimitLimit), "Degraded responses have no counters")
+		if tt.mode == config.FailClosed {
+			assert.Contains(t, w.Body.String(), MessageUnavailable)
+		}
+	}
+}
+
+func TestRateLimiterMiddleware_Concurrency(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 100, Duration: time.Second, Concurrency: 2},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		ConcurrencyLease: time.Second,
+	}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- serve("/slow").Code }()
+		<-started
+	}
+
+	w := serve("/fast")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), MessageConcurrencyLimit)
+
+	close(unblock)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+
+	assert.Equal(t, http.StatusOK, serve("/fast").Code, "Slots are released when requests finish")
+}
+
+func TestRateLimiterMiddleware_CostHeader(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:         config.RateLimitConfig{Requests: 10, Duration: time.Minute},
+		Tokens:     make(map[string]config.RateLimitConfig),
+		CostHeader: "X-RateLimit-Cost",
+	}
+
+	handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expensive" {
+			w.Header().Set("X-RateLimit-Cost", "8")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/expensive")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Cost"), "The cost header is not sent to the client")
+	assert.Equal(t, "9", w.Header().Get(HeaderRateLimitRemaining))
+
+	w = serve("/cheap")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(HeaderRateLimitRemaining), "The handler cost is charged after the response")
+
+	serve("/cheap")
+	assert.Equal(t, http.StatusTooManyRequests, serve("/cheap").Code)
+}
+
+func TestRateLimiterMiddleware_ChunkedBodyCost(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 10, Duration: time.Minute},
+		Tokens: make(map[string]config.RateLimitConfig),
+		Cost:   config.Cost{Bytes: 1000},
+	}
+
+	var transferEncoding []string
+	handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transferEncoding = r.TransferEncoding
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// sem Content-Length, o corpo é enviado com Transfer-Encoding: chunked
+	post := func() *http.Response {
+		req, err := http.NewRequest("POST", server.URL, io.MultiReader(bytes.NewReader(make([]byte, 2500))))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := post()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"chunked"}, transferEncoding)
+	assert.Equal(t, "9", resp.Header.Get(HeaderRateLimitRemaining), "The body size is unknown in Check")
+
+	resp = post()
+	assert.Equal(t, "5", resp.Header.Get(HeaderRateLimitRemaining), "The bytes read are charged after the response")
+}
+
+// chargeFailingStorage falha só ao cobrar o custo final (Limit.Force).
+type chargeFailingStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s chargeFailingStorage) FixedWindow(ctx context.Context, key string, limit storage.Limit) (storage.Result, error) {
+	if limit.Force {
+		return storage.Result{}, storage.ErrUnavailable
+	}
+	return s.MemoryStorage.FixedWindow(ctx, key, limit)
+}
+
+func TestRateLimiterMiddleware_IPOnly(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP: config.RateLimitConfig{Requests: 2, Duration: time.Minute},
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 100},
+		},
+	}
+	rl := limiter.New(store, cfg)
+
+	handler := RateLimiterMiddleware(rl, WithIPOnly())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/usage", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set(HeaderAPIKey, "abc123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// o token não escapa do limite de IP, e a cota dele não é consumida
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, http.StatusTooManyRequests, serve())
+
+	usage, err := rl.Usage(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Zero(t, usage.Quotas[0].Used)
+}
+
+func TestRateLimiterMiddleware_LogsChargeErrors(t *testing.T) {
+	store := chargeFailingStorage{storage.NewMemoryStorage()}
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:         config.RateLimitConfig{Requests: 10, Duration: time.Minute},
+		Tokens:     make(map[string]config.RateLimitConfig),
+		CostHeader: "X-RateLimit-Cost",
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := RateLimiterMiddleware(limiter.New(store, cfg), WithLogger(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Cost", "8")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/expensive", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "The response is already sent when the charge fails")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "failed to charge request cost", entry["msg"])
+	assert.Equal(t, "ip:192.168.1.1", entry["key"])
+	assert.Equal(t, float64(8), entry["cost"])
+	assert.Contains(t, entry["error"], storage.ErrUnavailable.Error())
+}
+
+func TestRateLimiterMiddleware_Quota(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP: config.RateLimitConfig{Requests: 10, Duration: time.Minute},
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 10, Duration: time.Minute, Daily: 1},
+		},
+	}
+
+	handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		req.Header.Set(HeaderAPIKey, "abc123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve().Code)
+
+	w := serve()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), MessageQuotaExceeded)
+	assert.Equal(t, "1", w.Header().Get(HeaderRateLimitLimit), "The headers describe the exhausted quota")
+	assert.Equal(t, "0", w.Header().Get(HeaderRateLimitRemaining))
+	assert.NotEmpty(t, w.Header().Get(HeaderRetryAfter))
+}
+
+func TestRateLimiterMiddleware_Shadow(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:     config.RateLimitConfig{Requests: 2, Duration: time.Minute, BlockDuration: time.Minute, Shadow: true},
+		Tokens: make(map[string]config.RateLimitConfig),
+	}
+
+	handler := RateLimiterMiddleware(limiter.New(store, cfg))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 1; i <= 4; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		want := ShadowAllow
+		if i > 2 {
+			want = ShadowDeny
+		}
+		assert.Equal(t, http.StatusOK, w.Code, "Request %d should pass in shadow mode", i)
+		assert.Equal(t, want, w.Header().Get(HeaderRateLimitShadow), "Request %d", i)
+		assert.Empty(t, w.Header().Get(HeaderRateLimitLimit), "The shadow policy is not advertised")
+		assert.Empty(t, w.Header().Get(HeaderRetryAfter))
+	}
+}
+
+func TestRateLimiterMiddleware_ReportsBlock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+
+	cfg := &config.Config{
+		IP:               config.RateLimitConfig{Requests: 1, Duration: time.Minute, BlockDuration: 5 * time.Minute},
+		Tokens:           make(map[string]config.RateLimitConfig),
+		BlockFactor:      6,
+		MaxBlockDuration: 24 * time.Hour,
+		ViolationDecay:   24 * time.Hour,
+		Clock:            clk,
+	}
+
+	rl := limiter.New(store, cfg)
+	handler := RateLimiterMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve().Code)
+	serve()
+	require.NoError(t, rl.Unblock(context.Background(), limiter.Request{IP: "192.168.1.1"}))
+
+	w := serve()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	var body struct {
+		Message      string    `json:"message"`
+		BlockedUntil time.Time `json:"blocked_until"`
+		Violations   int64     `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, MessageRateLimitExceeded, body.Message)
+	assert.Equal(t, int64(2), body.Violations)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), body.BlockedUntil)
+	assert.Equal(t, strconv.Itoa(30*60), w.Header().Get(HeaderRetryAfter))
+}
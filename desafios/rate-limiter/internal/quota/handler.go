@@ -0,0 +1,62 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+)
+
+type usageResponse struct {
+	Quotas []quotaResponse `json:"quotas"`
+}
+
+type quotaResponse struct {
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// NewUsageHandler retorna o consumo das cotas do token enviado no header
+// API_KEY. Fica fora do rate limiting para não consumir a própria cota.
+func NewUsageHandler(rl *limiter.RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(middleware.HeaderAPIKey)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "missing "+middleware.HeaderAPIKey+" header")
+			return
+		}
+
+		usage, err := rl.Usage(r.Context(), token)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
+		response := usageResponse{Quotas: make([]quotaResponse, 0, len(usage.Quotas))}
+		for _, q := range usage.Quotas {
+			response.Quotas = append(response.Quotas, quotaResponse{
+				Period:    q.Period,
+				Limit:     q.Limit,
+				Used:      q.Used,
+				Remaining: q.Remaining,
+				ResetAt:   q.ResetAt,
+			})
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,42 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageHandler(t *testing.T) {
+	rl, _ := newTestLimiter(t)
+	handler := NewUsageHandler(rl)
+
+	for i := 0; i < 4; i++ {
+		_, err := rl.Check(context.Background(), limiter.Request{IP: "192.168.1.1", Token: "abc123"})
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/usage", nil)
+	req.Header.Set(middleware.HeaderAPIKey, "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response usageResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	require.Len(t, response.Quotas, 1)
+	assert.Equal(t, limiter.QuotaDaily, response.Quotas[0].Period)
+	assert.Equal(t, 10, response.Quotas[0].Limit)
+	assert.Equal(t, int64(4), response.Quotas[0].Used)
+	assert.Equal(t, int64(6), response.Quotas[0].Remaining)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/usage", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+// Persister copia periodicamente o consumo das cotas do storage para o
+// SQLStore e, na inicialização, restaura no storage o que foi salvo. Os
+// períodos seguem o relógio da configuração do RateLimiter, o mesmo usado para
+// criar as cotas.
+type Persister struct {
+	rl      *limiter.RateLimiter
+	storage storage.Storage
+	store   *SQLStore
+
+	// cotas cujo snapshot falhou, tentadas de novo no próximo
+	pending map[string]storage.Quota
+
+	// OnError recebe os erros do snapshot periódico em Run.
+	OnError func(err error)
+}
+
+func NewPersister(rl *limiter.RateLimiter, backend storage.Storage, store *SQLStore) *Persister {
+	return &Persister{
+		rl:      rl,
+		storage: backend,
+		store:   store,
+		pending: make(map[string]storage.Quota),
+	}
+}
+
+// Restore devolve ao storage o consumo salvo das cotas do período atual.
+// Como RestoreQuota mantém o maior valor, pode ser chamado com o servidor
+// já recebendo requisições.
+func (p *Persister) Restore(ctx context.Context) error {
+	records, err := p.store.Load(ctx, p.rl.Config().Now())
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := p.storage.RestoreQuota(ctx, record.Quota, record.Used); err != nil {
+			return fmt.Errorf("error restoring quota %s: %w", record.Quota.Key, err)
+		}
+	}
+	return nil
+}
+
+// Snapshot salva o consumo das cotas usadas desde o último snapshot. Quando o
+// valor salvo é maior que o do storage, o storage perdeu dados (ou outra
+// instância salvou depois) e é corrigido.
+func (p *Persister) Snapshot(ctx context.Context) error {
+	now := p.rl.Config().Now()
+	for _, quota := range p.rl.DirtyQuotas() {
+		p.pending[quota.Key] = quota
+	}
+
+	quotas := make([]storage.Quota, 0, len(p.pending))
+	keys := make([]string, 0, len(p.pending))
+	for key, quota := range p.pending {
+		if !quota.ExpiresAt.After(now) {
+			delete(p.pending, key)
+			continue
+		}
+		quotas = append(quotas, quota)
+		keys = append(keys, key)
+	}
+	if len(quotas) == 0 {
+		return p.store.Purge(ctx, now)
+	}
+
+	used, err := p.storage.QuotaUsage(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("error getting quota usage: %w", err)
+	}
+
+	var failed error
+	for i, quota := range quotas {
+		saved, err := p.store.Save(ctx, quota, used[i])
+		if err != nil {
+			failed = err
+			continue
+		}
+		if saved > used[i] {
+			if err := p.storage.RestoreQuota(ctx, quota, saved); err != nil {
+				failed = fmt.Errorf("error restoring quota %s: %w", quota.Key, err)
+				continue
+			}
+		}
+		delete(p.pending, quota.Key)
+	}
+	if failed != nil {
+		return failed
+	}
+
+	return p.store.Purge(ctx, now)
+}
+
+// Run executa Snapshot a cada interval até ctx ser cancelado, com um último
+// snapshot na saída.
+func (p *Persister) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.snapshot(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			p.snapshot(ctx)
+		}
+	}
+}
+
+func (p *Persister) snapshot(ctx context.Context) {
+	if err := p.Snapshot(ctx); err != nil && p.OnError != nil {
+		p.OnError(err)
+	}
+}
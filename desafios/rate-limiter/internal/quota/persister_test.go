@@ -0,0 +1,145 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T) (*limiter.RateLimiter, storage.Storage) {
+	t.Helper()
+
+	store := storage.NewMemoryStorage()
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		IP: config.RateLimitConfig{Requests: 100, Duration: time.Minute},
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 10},
+		},
+	}
+	return limiter.New(store, cfg), store
+}
+
+func TestPersister_Restore(t *testing.T) {
+	rl, store := newTestLimiter(t)
+	sqlStore, mock := newTestSQLStore(t)
+	ctx := context.Background()
+
+	usage, err := rl.Usage(ctx, "abc123")
+	require.NoError(t, err)
+	key := "quota:" + usage.Key + ":daily:" + time.Now().UTC().Format("2006-01-02")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT key, quota_limit, used, expires_at")).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "quota_limit", "used", "expires_at"}).
+			AddRow(key, 10, 9, usage.Quotas[0].ResetAt))
+
+	require.NoError(t, NewPersister(rl, store, sqlStore).Restore(ctx))
+
+	usage, err = rl.Usage(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), usage.Quotas[0].Used)
+
+	decision, err := rl.Check(ctx, limiter.Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = rl.Check(ctx, limiter.Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed, "restored usage counts towards the quota")
+}
+
+func TestPersister_UsesTheLimiterClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC))
+	store := storage.NewMemoryStorage(storage.WithClock(clk))
+	defer store.Close()
+	rl := limiter.New(store, &config.Config{
+		Tokens: map[string]config.RateLimitConfig{
+			apikey.Hash("abc123"): {Requests: 100, Duration: time.Minute, Daily: 10},
+		},
+		Clock: clk,
+	})
+	sqlStore, mock := newTestSQLStore(t)
+	ctx := context.Background()
+
+	// o período restaurado é o do relógio do RateLimiter, não o do sistema
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT key, quota_limit, used, expires_at")).
+		WithArgs(clk.Now()).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "quota_limit", "used", "expires_at"}))
+	persister := NewPersister(rl, store, sqlStore)
+	require.NoError(t, persister.Restore(ctx))
+
+	_, err := rl.Check(ctx, limiter.Request{Token: "abc123"})
+	require.NoError(t, err)
+
+	// a cota de 31/01 ainda não expirou pelo relógio do RateLimiter
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WithArgs(sqlmock.AnyArg(), int64(10), int64(1), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_quota_usage")).
+		WithArgs(clk.Now()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, persister.Snapshot(ctx))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPersister_Snapshot(t *testing.T) {
+	rl, store := newTestLimiter(t)
+	sqlStore, mock := newTestSQLStore(t)
+	ctx := context.Background()
+	persister := NewPersister(rl, store, sqlStore)
+
+	for i := 0; i < 2; i++ {
+		_, err := rl.Check(ctx, limiter.Request{IP: "192.168.1.1", Token: "abc123"})
+		require.NoError(t, err)
+	}
+
+	// outra instância já salvou um consumo maior, então o storage é corrigido
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WithArgs(sqlmock.AnyArg(), int64(10), int64(2), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(6))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_quota_usage")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, persister.Snapshot(ctx))
+
+	usage, err := rl.Usage(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), usage.Quotas[0].Used)
+
+	// sem consumo novo, nada é salvo
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_quota_usage")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, persister.Snapshot(ctx))
+}
+
+func TestPersister_SnapshotRetriesFailures(t *testing.T) {
+	rl, store := newTestLimiter(t)
+	sqlStore, mock := newTestSQLStore(t)
+	ctx := context.Background()
+	persister := NewPersister(rl, store, sqlStore)
+
+	_, err := rl.Check(ctx, limiter.Request{IP: "192.168.1.1", Token: "abc123"})
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WillReturnError(errors.New("connection refused"))
+	assert.Error(t, persister.Snapshot(ctx))
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WithArgs(sqlmock.AnyArg(), int64(10), int64(1), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM rate_limit_quota_usage")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, persister.Snapshot(ctx), "failed quotas are saved on the next snapshot")
+}
@@ -0,0 +1,103 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+const createTable = `
+CREATE TABLE IF NOT EXISTS rate_limit_quota_usage (
+	key         TEXT PRIMARY KEY,
+	quota_limit BIGINT NOT NULL,
+	used        BIGINT NOT NULL,
+	expires_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// o consumo nunca diminui dentro do período, então o maior valor vence: uma
+// instância com o Redis zerado não apaga o que outra já salvou
+const upsertUsage = `
+INSERT INTO rate_limit_quota_usage (key, quota_limit, used, expires_at, updated_at)
+VALUES ($1, $2, $3, $4, now())
+ON CONFLICT (key) DO UPDATE SET
+	quota_limit = EXCLUDED.quota_limit,
+	used        = GREATEST(rate_limit_quota_usage.used, EXCLUDED.used),
+	expires_at  = EXCLUDED.expires_at,
+	updated_at  = now()
+RETURNING used`
+
+const selectUsage = `
+SELECT key, quota_limit, used, expires_at
+FROM rate_limit_quota_usage
+WHERE expires_at > $1`
+
+const deleteExpired = `DELETE FROM rate_limit_quota_usage WHERE expires_at <= $1`
+
+// Record é o consumo salvo de uma cota.
+type Record struct {
+	Quota storage.Quota
+	Used  int64
+}
+
+// SQLStore salva o consumo das cotas em uma tabela do PostgreSQL, para que
+// sobreviva à perda dos dados do Redis.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Migrate cria a tabela de consumo, se ainda não existir.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("error creating quota table: %w", err)
+	}
+	return nil
+}
+
+// Save grava o consumo da cota e retorna o valor salvo, que pode ser maior que
+// used quando outra instância já gravou um consumo maior.
+func (s *SQLStore) Save(ctx context.Context, quota storage.Quota, used int64) (int64, error) {
+	var saved int64
+	err := s.db.QueryRowContext(ctx, upsertUsage, quota.Key, quota.Limit, used, quota.ExpiresAt).Scan(&saved)
+	if err != nil {
+		return 0, fmt.Errorf("error saving quota %s: %w", quota.Key, err)
+	}
+	return saved, nil
+}
+
+// Load retorna o consumo das cotas cujo período ainda não terminou em now.
+func (s *SQLStore) Load(ctx context.Context, now time.Time) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, selectUsage, now)
+	if err != nil {
+		return nil, fmt.Errorf("error loading quotas: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.Quota.Key, &record.Quota.Limit, &record.Used, &record.Quota.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("error loading quotas: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading quotas: %w", err)
+	}
+	return records, nil
+}
+
+// Purge apaga as cotas de períodos que já terminaram em now.
+func (s *SQLStore) Purge(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, deleteExpired, now); err != nil {
+		return fmt.Errorf("error purging quotas: %w", err)
+	}
+	return nil
+}
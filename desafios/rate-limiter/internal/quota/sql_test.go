@@ -0,0 +1,73 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLStore(t *testing.T) (*SQLStore, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return NewSQLStore(db), mock
+}
+
+func TestSQLStore_Migrate(t *testing.T) {
+	store, mock := newTestSQLStore(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS rate_limit_quota_usage")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, store.Migrate(context.Background()))
+}
+
+func TestSQLStore_Save(t *testing.T) {
+	store, mock := newTestSQLStore(t)
+	quota := storage.Quota{Key: "quota:token:abc123:daily:2024-01-31", Limit: 1000, ExpiresAt: time.Now().Add(time.Hour)}
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WithArgs(quota.Key, quota.Limit, int64(10), quota.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(25))
+
+	saved, err := store.Save(context.Background(), quota, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), saved, "the greatest usage wins")
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO rate_limit_quota_usage")).
+		WillReturnError(errors.New("connection refused"))
+
+	_, err = store.Save(context.Background(), quota, 10)
+	assert.ErrorContains(t, err, quota.Key)
+}
+
+func TestSQLStore_Load(t *testing.T) {
+	store, mock := newTestSQLStore(t)
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT key, quota_limit, used, expires_at")).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "quota_limit", "used", "expires_at"}).
+			AddRow("quota:token:abc123:daily:2024-01-31", 1000, 25, expiresAt).
+			AddRow("quota:token:abc123:monthly:2024-01", 20000, 400, expiresAt))
+
+	records, err := store.Load(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, []Record{
+		{Quota: storage.Quota{Key: "quota:token:abc123:daily:2024-01-31", Limit: 1000, ExpiresAt: expiresAt}, Used: 25},
+		{Quota: storage.Quota{Key: "quota:token:abc123:monthly:2024-01", Limit: 20000, ExpiresAt: expiresAt}, Used: 400},
+	}, records)
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnavailable é retornado pelo Breaker enquanto o circuito está aberto.
+var ErrUnavailable = errors.New("storage unavailable: circuit breaker open")
+
+// Breaker envolve um Storage remoto com um circuit breaker. Após failures erros
+// consecutivos o circuito abre e as operações falham imediatamente com
+// ErrUnavailable, sem esperar pelos timeouts do storage. Enquanto aberto, probe
+// é executado a cada interval e o circuito fecha no primeiro sucesso.
+type Breaker struct {
+	next     Storage
+	probe    func(ctx context.Context) error
+	failures int
+	interval time.Duration
+
+	// OnStateChange, se definido, é chamado quando o circuito abre ou fecha.
+	OnStateChange func(open bool)
+
+	open        atomic.Bool
+	mu          sync.Mutex
+	consecutive int
+	stop        chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewBreaker(next Storage, probe func(ctx context.Context) error, failures int, interval time.Duration) *Breaker {
+	return &Breaker{
+		next:     next,
+		probe:    probe,
+		failures: failures,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Open indica se o circuito está aberto.
+func (b *Breaker) Open() bool {
+	return b.open.Load()
+}
+
+func call[T any](b *Breaker, ctx context.Context, fn func() (T, error)) (T, error) {
+	if b.open.Load() {
+		var zero T
+		return zero, ErrUnavailable
+	}
+
+	value, err := fn()
+	switch {
+	case err == nil:
+		b.succeed()
+	case ctx.Err() == nil:
+		// cancelamentos de quem chamou não indicam falha do storage
+		b.fail()
+	}
+	return value, err
+}
+
+func (b *Breaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consecutive = 0
+}
+
+func (b *Breaker) fail() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutive++
+	if b.consecutive < b.failures || b.open.Load() {
+		return
+	}
+
+	b.open.Store(true)
+	b.notify(true)
+	go b.probeUntilHealthy()
+}
+
+func (b *Breaker) probeUntilHealthy() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), b.interval)
+			err := b.probe(ctx)
+			cancel()
+			if err != nil {
+				continue
+			}
+
+			b.mu.Lock()
+			b.consecutive = 0
+			b.open.Store(false)
+			b.notify(false)
+			b.mu.Unlock()
+			return
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *Breaker) notify(open bool) {
+	if b.OnStateChange != nil {
+		b.OnStateChange(open)
+	}
+}
+
+func (b *Breaker) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return call(b, ctx, func() (int64, error) { return b.next.Increment(ctx, key, expiration) })
+}
+
+func (b *Breaker) IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	return call(b, ctx, func() (int64, error) { return b.next.IncrementBy(ctx, key, n, expiration) })
+}
+
+func (b *Breaker) Get(ctx context.Context, key string) (int64, error) {
+	return call(b, ctx, func() (int64, error) { return b.next.Get(ctx, key) })
+}
+
+func (b *Breaker) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.SetBlock(ctx, key, duration) })
+	return err
+}
+
+func (b *Breaker) IsBlocked(ctx context.Context, key string) (bool, error) {
+	return call(b, ctx, func() (bool, error) { return b.next.IsBlocked(ctx, key) })
+}
+
+func (b *Breaker) FixedWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return call(b, ctx, func() (Result, error) { return b.next.FixedWindow(ctx, key, limit) })
+}
+
+func (b *Breaker) SlidingLog(ctx context.Context, key string, limit Limit) (Result, error) {
+	return call(b, ctx, func() (Result, error) { return b.next.SlidingLog(ctx, key, limit) })
+}
+
+func (b *Breaker) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return call(b, ctx, func() (Result, error) { return b.next.SlidingWindow(ctx, key, limit) })
+}
+
+func (b *Breaker) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	return call(b, ctx, func() (Result, error) { return b.next.TokenBucket(ctx, key, limit) })
+}
+
+func (b *Breaker) GCRA(ctx context.Context, key string, limit Limit) (Result, error) {
+	return call(b, ctx, func() (Result, error) { return b.next.GCRA(ctx, key, limit) })
+}
+
+func (b *Breaker) Inspect(ctx context.Context, key string, limit Limit) (State, error) {
+	return call(b, ctx, func() (State, error) { return b.next.Inspect(ctx, key, limit) })
+}
+
+func (b *Breaker) Unblock(ctx context.Context, key string) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.Unblock(ctx, key) })
+	return err
+}
+
+func (b *Breaker) Reset(ctx context.Context, key string) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.Reset(ctx, key) })
+	return err
+}
+
+func (b *Breaker) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error) {
+	return call(b, ctx, func() (bool, error) { return b.next.Acquire(ctx, key, id, limit, lease) })
+}
+
+func (b *Breaker) Renew(ctx context.Context, key, id string, lease time.Duration) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.Renew(ctx, key, id, lease) })
+	return err
+}
+
+func (b *Breaker) Release(ctx context.Context, key, id string) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.Release(ctx, key, id) })
+	return err
+}
+
+func (b *Breaker) ConsumeQuota(ctx context.Context, quotas []Quota, cost int64) (QuotaResult, error) {
+	return call(b, ctx, func() (QuotaResult, error) { return b.next.ConsumeQuota(ctx, quotas, cost) })
+}
+
+func (b *Breaker) QuotaUsage(ctx context.Context, keys []string) ([]int64, error) {
+	return call(b, ctx, func() ([]int64, error) { return b.next.QuotaUsage(ctx, keys) })
+}
+
+func (b *Breaker) RestoreQuota(ctx context.Context, quota Quota, used int64) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.RestoreQuota(ctx, quota, used) })
+	return err
+}
+
+func (b *Breaker) SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := call(b, ctx, func() (struct{}, error) { return struct{}{}, b.next.SetOverride(ctx, key, value, ttl) })
+	return err
+}
+
+func (b *Breaker) GetOverride(ctx context.Context, key string) ([]byte, error) {
+	return call(b, ctx, func() ([]byte, error) { return b.next.GetOverride(ctx, key) })
+}
+
+func (b *Breaker) DeleteOverride(ctx context.Context, key string) (bool, error) {
+	return call(b, ctx, func() (bool, error) { return b.next.DeleteOverride(ctx, key) })
+}
+
+func (b *Breaker) Close() error {
+	b.closeOnce.Do(func() { close(b.stop) })
+	return b.next.Close()
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStorage falha todas as operações de limite enquanto down for verdadeiro.
+type flakyStorage struct {
+	*MemoryStorage
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func newFlakyStorage() *flakyStorage {
+	return &flakyStorage{MemoryStorage: NewMemoryStorage()}
+}
+
+func (f *flakyStorage) FixedWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return Result{}, errors.New("connection refused")
+	}
+	return f.MemoryStorage.FixedWindow(ctx, key, limit)
+}
+
+func (f *flakyStorage) ping(ctx context.Context) error {
+	if f.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	flaky := newFlakyStorage()
+	breaker := NewBreaker(flaky, flaky.ping, 3, 20*time.Millisecond)
+	defer breaker.Close()
+
+	var mu sync.Mutex
+	var changes []bool
+	breaker.OnStateChange = func(open bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, open)
+	}
+
+	ctx := context.Background()
+	limit := Limit{Requests: 100, Window: time.Minute}
+
+	flaky.down.Store(true)
+	for i := 0; i < 3; i++ {
+		_, err := breaker.FixedWindow(ctx, "key", limit)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrUnavailable)
+	}
+	assert.True(t, breaker.Open())
+
+	// com o circuito aberto o storage não é mais chamado
+	_, err := breaker.FixedWindow(ctx, "key", limit)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, int64(3), flaky.calls.Load())
+
+	flaky.down.Store(false)
+	assert.Eventually(t, func() bool { return !breaker.Open() }, time.Second, 5*time.Millisecond)
+
+	result, err := breaker.FixedWindow(ctx, "key", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestBreaker_IgnoresCallerCancellation(t *testing.T) {
+	flaky := newFlakyStorage()
+	breaker := NewBreaker(flaky, flaky.ping, 1, time.Minute)
+	defer breaker.Close()
+
+	flaky.down.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := breaker.FixedWindow(ctx, "key", Limit{Requests: 1, Window: time.Minute})
+	require.Error(t, err)
+	assert.False(t, breaker.Open())
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	flaky := newFlakyStorage()
+	breaker := NewBreaker(flaky, flaky.ping, 2, time.Minute)
+	defer breaker.Close()
+
+	ctx := context.Background()
+	limit := Limit{Requests: 100, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		flaky.down.Store(true)
+		breaker.FixedWindow(ctx, "key", limit)
+		flaky.down.Store(false)
+		_, err := breaker.FixedWindow(ctx, "key", limit)
+		require.NoError(t, err)
+	}
+	assert.False(t, breaker.Open())
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Fallback usa o storage primário e, quando ele falha, um storage local com os
+// limites multiplicados por scale. Com N instâncias, scale = 1/N mantém o
+// limite global aproximado enquanto cada instância conta isoladamente.
+type Fallback struct {
+	primary Storage
+	local   Storage
+	scale   float64
+}
+
+func NewFallback(primary, local Storage, scale float64) *Fallback {
+	return &Fallback{primary: primary, local: local, scale: scale}
+}
+
+func fallback[T any](primary func() (T, error), local func() (T, error)) (T, error) {
+	if value, err := primary(); err == nil {
+		return value, nil
+	}
+	return local()
+}
+
+func (f *Fallback) scaled(limit Limit) Limit {
+	limit.Requests = f.scale64(limit.Requests)
+	return limit
+}
+
+func (f *Fallback) scale64(n int64) int64 {
+	scaled := int64(math.Ceil(float64(n) * f.scale))
+	if scaled < 1 && n > 0 {
+		scaled = 1
+	}
+	return scaled
+}
+
+func (f *Fallback) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return fallback(
+		func() (int64, error) { return f.primary.Increment(ctx, key, expiration) },
+		func() (int64, error) { return f.local.Increment(ctx, key, expiration) },
+	)
+}
+
+func (f *Fallback) IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	return fallback(
+		func() (int64, error) { return f.primary.IncrementBy(ctx, key, n, expiration) },
+		func() (int64, error) { return f.local.IncrementBy(ctx, key, n, expiration) },
+	)
+}
+
+func (f *Fallback) Get(ctx context.Context, key string) (int64, error) {
+	return fallback(
+		func() (int64, error) { return f.primary.Get(ctx, key) },
+		func() (int64, error) { return f.local.Get(ctx, key) },
+	)
+}
+
+func (f *Fallback) SetBlock(ctx context.Context, key string, duration time.Duration) error {
+	if err := f.primary.SetBlock(ctx, key, duration); err == nil {
+		return nil
+	}
+	return f.local.SetBlock(ctx, key, duration)
+}
+
+func (f *Fallback) IsBlocked(ctx context.Context, key string) (bool, error) {
+	return fallback(
+		func() (bool, error) { return f.primary.IsBlocked(ctx, key) },
+		func() (bool, error) { return f.local.IsBlocked(ctx, key) },
+	)
+}
+
+func (f *Fallback) FixedWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return fallback(
+		func() (Result, error) { return f.primary.FixedWindow(ctx, key, limit) },
+		func() (Result, error) { return f.local.FixedWindow(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) SlidingLog(ctx context.Context, key string, limit Limit) (Result, error) {
+	return fallback(
+		func() (Result, error) { return f.primary.SlidingLog(ctx, key, limit) },
+		func() (Result, error) { return f.local.SlidingLog(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return fallback(
+		func() (Result, error) { return f.primary.SlidingWindow(ctx, key, limit) },
+		func() (Result, error) { return f.local.SlidingWindow(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	return fallback(
+		func() (Result, error) { return f.primary.TokenBucket(ctx, key, limit) },
+		func() (Result, error) { return f.local.TokenBucket(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) GCRA(ctx context.Context, key string, limit Limit) (Result, error) {
+	return fallback(
+		func() (Result, error) { return f.primary.GCRA(ctx, key, limit) },
+		func() (Result, error) { return f.local.GCRA(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) Inspect(ctx context.Context, key string, limit Limit) (State, error) {
+	return fallback(
+		func() (State, error) { return f.primary.Inspect(ctx, key, limit) },
+		func() (State, error) { return f.local.Inspect(ctx, key, f.scaled(limit)) },
+	)
+}
+
+func (f *Fallback) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error) {
+	return fallback(
+		func() (bool, error) { return f.primary.Acquire(ctx, key, id, limit, lease) },
+		func() (bool, error) { return f.local.Acquire(ctx, key, id, f.scale64(limit), lease) },
+	)
+}
+
+// Renew e Release atuam nos dois storages porque o lease pode ter sido obtido
+// em qualquer um deles.
+func (f *Fallback) Renew(ctx context.Context, key, id string, lease time.Duration) error {
+	f.local.Renew(ctx, key, id, lease)
+	return f.primary.Renew(ctx, key, id, lease)
+}
+
+func (f *Fallback) Release(ctx context.Context, key, id string) error {
+	f.local.Release(ctx, key, id)
+	return f.primary.Release(ctx, key, id)
+}
+
+// Cotas não são escaladas: o storage local só conhece o consumo feito durante
+// a indisponibilidade, então a cota completa vale para cada instância.
+func (f *Fallback) ConsumeQuota(ctx context.Context, quotas []Quota, cost int64) (QuotaResult, error) {
+	return fallback(
+		func() (QuotaResult, error) { return f.primary.ConsumeQuota(ctx, quotas, cost) },
+		func() (QuotaResult, error) { return f.local.ConsumeQuota(ctx, quotas, cost) },
+	)
+}
+
+func (f *Fallback) QuotaUsage(ctx context.Context, keys []string) ([]int64, error) {
+	return fallback(
+		func() ([]int64, error) { return f.primary.QuotaUsage(ctx, keys) },
+		func() ([]int64, error) { return f.local.QuotaUsage(ctx, keys) },
+	)
+}
+
+func (f *Fallback) RestoreQuota(ctx context.Context, quota Quota, used int64) error {
+	return f.primary.RestoreQuota(ctx, quota, used)
+}
+
+// Unblock e Reset também limpam o estado local, que pode ter sido criado
+// durante uma indisponibilidade do primário.
+func (f *Fallback) Unblock(ctx context.Context, key string) error {
+	f.local.Unblock(ctx, key)
+	return f.primary.Unblock(ctx, key)
+}
+
+func (f *Fallback) Reset(ctx context.Context, key string) error {
+	f.local.Reset(ctx, key)
+	return f.primary.Reset(ctx, key)
+}
+
+// Os overrides seguem o primário e, enquanto ele estiver indisponível, valem
+// só na instância que os recebeu.
+func (f *Fallback) SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := fallback(
+		func() (struct{}, error) { return struct{}{}, f.primary.SetOverride(ctx, key, value, ttl) },
+		func() (struct{}, error) { return struct{}{}, f.local.SetOverride(ctx, key, value, ttl) },
+	)
+	return err
+}
+
+func (f *Fallback) GetOverride(ctx context.Context, key string) ([]byte, error) {
+	return fallback(
+		func() ([]byte, error) { return f.primary.GetOverride(ctx, key) },
+		func() ([]byte, error) { return f.local.GetOverride(ctx, key) },
+	)
+}
+
+func (f *Fallback) DeleteOverride(ctx context.Context, key string) (bool, error) {
+	deletedLocal, _ := f.local.DeleteOverride(ctx, key)
+	deleted, err := f.primary.DeleteOverride(ctx, key)
+	return deleted || deletedLocal, err
+}
+
+func (f *Fallback) Close() error {
+	f.local.Close()
+	return f.primary.Close()
+}
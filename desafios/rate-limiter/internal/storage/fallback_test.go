@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallback_UsesScaledLocalLimitsWhileRedisIsDown(t *testing.T) {
+	redisStore, mr := newTestRedisStorage(t)
+	breaker := NewBreaker(redisStore, redisStore.Ping, 1, 20*time.Millisecond)
+	local := NewMemoryStorage()
+	store := NewFallback(breaker, local, 0.5)
+	defer store.Close()
+
+	ctx := context.Background()
+	limit := Limit{Requests: 4, Window: time.Minute}
+
+	result, err := store.FixedWindow(ctx, "key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Remaining)
+
+	mr.Close()
+
+	// o limite local é metade do global: 2 requisições
+	for i := 1; i <= 2; i++ {
+		result, err = store.FixedWindow(ctx, "key", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+		assert.Equal(t, int64(2-i), result.Remaining)
+	}
+	result, err = store.FixedWindow(ctx, "key", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.True(t, breaker.Open())
+
+	require.NoError(t, mr.Restart())
+	assert.Eventually(t, func() bool { return !breaker.Open() }, 2*time.Second, 10*time.Millisecond)
+
+	// de volta ao Redis, o contador global continua de onde parou
+	result, err = store.FixedWindow(ctx, "key", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(2), result.Remaining)
+}
+
+func TestFallback_ScaledLimitIsAtLeastOne(t *testing.T) {
+	f := NewFallback(nil, nil, 0.1)
+
+	assert.Equal(t, int64(1), f.scaled(Limit{Requests: 3}).Requests)
+	assert.Equal(t, int64(10), f.scaled(Limit{Requests: 100}).Requests)
+	assert.Equal(t, int64(0), f.scaled(Limit{Requests: 0}).Requests)
+}
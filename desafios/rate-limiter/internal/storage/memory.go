@@ -1,58 +1,109 @@
 package storage
 
 import (
+	"container/list"
 	"context"
-	"sync"
+	"hash/maphash"
+	"math"
+	"sort"
 	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
+)
+
+const (
+	defaultShards = 64
+
+	// a wheel verifica as expirações a cada segundo e alcança pouco mais de 4
+	// minutos; chaves que expiram depois disso dão mais de uma volta
+	wheelTick  = time.Second
+	wheelSlots = 256
 )
 
+// MemoryStorage guarda as chaves em shards, cada um com seu lock, para que
+// requisições de chaves diferentes não disputem o mesmo mutex. As entradas
+// derivadas de uma chave (key:blocked, key:log...) ficam no shard da chave,
+// então os algoritmos continuam atômicos com um único lock.
 type MemoryStorage struct {
-	data      map[string]*entry
-	mu        sync.RWMutex
+	clock     clock.Clock
+	shards    []*shard
+	seed      maphash.Seed
 	stopClean chan struct{}
 }
 
 type entry struct {
 	value      int64
 	expiration time.Time
+
+	// estado usado pelos algoritmos além da janela fixa
+	log         []time.Time
+	previous    int64
+	windowStart time.Time
+	tokens      float64
+	updated     time.Time
+	tat         time.Time
+	leases      map[string]time.Time
+	data        []byte
+
+	// posição na lista de uso e tick da próxima verificação de expiração
+	element *list.Element
+	due     uint64
 }
 
-func NewMemoryStorage() *MemoryStorage {
+// NewMemoryStorage cria um storage sem limite de chaves.
+func NewMemoryStorage(opts ...Option) *MemoryStorage {
+	return NewBoundedMemoryStorage(0, opts...)
+}
+
+// NewBoundedMemoryStorage cria um storage com no máximo maxKeys chaves. Acima
+// disso as menos usadas são descartadas, o que limita a memória quando muitos
+// IPs diferentes fazem requisições. maxKeys 0 não limita.
+func NewBoundedMemoryStorage(maxKeys int, opts ...Option) *MemoryStorage {
+	return newMemoryStorage(defaultShards, maxKeys, wheelTick, opts...)
+}
+
+func newMemoryStorage(shards, maxKeys int, tick time.Duration, opts ...Option) *MemoryStorage {
 	m := &MemoryStorage{
-		data:      make(map[string]*entry),
+		clock:     newOptions(opts).clock,
+		shards:    make([]*shard, shards),
+		seed:      maphash.MakeSeed(),
 		stopClean: make(chan struct{}),
 	}
 
-	go m.cleanupExpired()
+	// o limite é dividido entre os shards, arredondado para cima
+	perShard := 0
+	if maxKeys > 0 {
+		perShard = (maxKeys + shards - 1) / shards
+	}
+	for i := range m.shards {
+		m.shards[i] = newShard(perShard, wheelSlots, tick, m.clock)
+	}
+
+	go m.cleanupExpired(tick)
 
 	return m
 }
 
 func (m *MemoryStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	s := m.lock(key)
+	defer s.mu.Unlock()
 
-	now := time.Now()
-	e, exists := m.data[key]
+	return s.increment(key, 1, expiration, m.clock.Now()), nil
+}
 
-	if !exists || e.expiration.Before(now) {
-		m.data[key] = &entry{
-			value:      1,
-			expiration: now.Add(expiration),
-		}
-		return 1, nil
-	}
+func (m *MemoryStorage) IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
 
-	e.value++
-	return e.value, nil
+	return s.increment(key, n, expiration, m.clock.Now()), nil
 }
 
 func (m *MemoryStorage) Get(ctx context.Context, key string) (int64, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	s := m.lock(key)
+	defer s.mu.Unlock()
 
-	e, exists := m.data[key]
-	if !exists || e.expiration.Before(time.Now()) {
+	e := s.get(key)
+	if e == nil || e.expiration.Before(m.clock.Now()) {
 		return 0, nil
 	}
 
@@ -60,29 +111,521 @@ func (m *MemoryStorage) Get(ctx context.Context, key string) (int64, error) {
 }
 
 func (m *MemoryStorage) SetBlock(ctx context.Context, key string, duration time.Duration) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	s := m.lock(key)
+	defer s.mu.Unlock()
 
-	blockKey := key + ":blocked"
-	m.data[blockKey] = &entry{
-		value:      1,
-		expiration: time.Now().Add(duration),
+	s.setBlock(key, duration, m.clock.Now())
+	return nil
+}
+
+func (m *MemoryStorage) IsBlocked(ctx context.Context, key string) (bool, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	return s.blockedFor(key, m.clock.Now()) > 0, nil
+}
+
+func (m *MemoryStorage) FixedWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return m.limit(key, limit, func(s *shard, now time.Time) Result {
+		count := s.increment(key, limit.cost(), limit.Window, now)
+		resetAfter := s.data[key].expiration.Sub(now)
+		if count > limit.Requests && !limit.Force {
+			return Result{Allowed: false, RetryAfter: resetAfter, ResetAfter: resetAfter}
+		}
+		return Result{Allowed: true, Remaining: max(limit.Requests-count, 0), ResetAfter: resetAfter}
+	})
+}
+
+func (m *MemoryStorage) SlidingLog(ctx context.Context, key string, limit Limit) (Result, error) {
+	return m.limit(key, limit, func(s *shard, now time.Time) Result {
+		e := s.entry(key + ":log")
+
+		cutoff := now.Add(-limit.Window)
+		i := 0
+		for i < len(e.log) && !e.log[i].After(cutoff) {
+			i++
+		}
+		e.log = e.log[i:]
+
+		// as entradas têm o mesmo instante, então mais de Requests delas não
+		// mudam quando o limite volta a ter espaço
+		cost := min(limit.cost(), max(limit.Requests, 1))
+		if int64(len(e.log))+limit.cost() > limit.Requests && !limit.Force {
+			retryAfter, resetAfter := limit.Window, limit.Window
+			if len(e.log) > 0 {
+				// espera expirarem entradas suficientes para caber o custo
+				if i := int64(len(e.log)) + cost - limit.Requests - 1; limit.cost() <= limit.Requests {
+					retryAfter = e.log[i].Add(limit.Window).Sub(now)
+				}
+				resetAfter = e.log[len(e.log)-1].Add(limit.Window).Sub(now)
+			}
+			return Result{Allowed: false, RetryAfter: retryAfter, ResetAfter: resetAfter}
+		}
+
+		for i := int64(0); i < cost; i++ {
+			e.log = append(e.log, now)
+		}
+		e.expiration = now.Add(limit.Window)
+
+		return Result{Allowed: true, Remaining: max(limit.Requests-int64(len(e.log)), 0), ResetAfter: limit.Window}
+	})
+}
+
+func (m *MemoryStorage) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return m.limit(key, limit, func(s *shard, now time.Time) Result {
+		e := s.entry(key + ":sw")
+		window := limit.Window
+
+		start := now.Truncate(window)
+		if !e.windowStart.Equal(start) {
+			if e.windowStart.Add(window).Equal(start) {
+				e.previous = e.value
+			} else {
+				e.previous = 0
+			}
+			e.value = 0
+			e.windowStart = start
+		}
+
+		cost := limit.cost()
+		elapsed := now.Sub(start)
+		estimated := float64(e.previous)*float64(window-elapsed)/float64(window) + float64(e.value)
+
+		if estimated+float64(cost) > float64(limit.Requests) && !limit.Force {
+			retryAfter, resetAfter := window-elapsed, window-elapsed
+			if e.value+cost <= limit.Requests && e.previous > 0 {
+				retryAfter = time.Duration(float64(window)*(1-float64(limit.Requests-e.value-cost)/float64(e.previous))) - elapsed
+			}
+			if e.value > 0 {
+				resetAfter += window
+			}
+			return Result{Allowed: false, RetryAfter: retryAfter, ResetAfter: resetAfter}
+		}
+
+		e.value += cost
+		e.expiration = start.Add(2 * window)
+
+		return Result{
+			Allowed:    true,
+			Remaining:  int64(math.Max(float64(limit.Requests)-estimated-float64(cost), 0)),
+			ResetAfter: 2*window - elapsed,
+		}
+	})
+}
+
+func (m *MemoryStorage) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	return m.limit(key, limit, func(s *shard, now time.Time) Result {
+		capacity := float64(limit.Requests)
+		if capacity <= 0 {
+			return Result{Allowed: false, RetryAfter: limit.Window}
+		}
+
+		e := s.get(key + ":tb")
+		if e == nil {
+			e = &entry{tokens: capacity, updated: now}
+			s.put(key+":tb", e)
+		}
+
+		rate := capacity / float64(limit.Window)
+		if elapsed := now.Sub(e.updated); elapsed > 0 {
+			e.tokens = math.Min(capacity, e.tokens+float64(elapsed)*rate)
+		}
+		e.updated = now
+
+		// com Force o saldo pode ficar negativo, e a dívida é paga com o tempo
+		cost := float64(limit.cost())
+		result := Result{Allowed: true}
+		if e.tokens < cost && !limit.Force {
+			result = Result{Allowed: false, RetryAfter: time.Duration(math.Ceil((cost - e.tokens) / rate))}
+		} else {
+			e.tokens -= cost
+		}
+
+		result.Remaining = int64(math.Max(e.tokens, 0))
+		result.ResetAfter = time.Duration(math.Ceil((capacity - e.tokens) / rate))
+		e.expiration = now.Add(result.ResetAfter)
+
+		return result
+	})
+}
+
+func (m *MemoryStorage) GCRA(ctx context.Context, key string, limit Limit) (Result, error) {
+	return m.limit(key, limit, func(s *shard, now time.Time) Result {
+		if limit.Requests <= 0 {
+			return Result{Allowed: false, RetryAfter: limit.Window}
+		}
+
+		e := s.entry(key + ":gcra")
+		window := limit.Window
+		interval := window / time.Duration(limit.Requests)
+
+		tat := e.tat
+		if tat.Before(now) {
+			tat = now
+		}
+
+		newTat := tat.Add(interval * time.Duration(limit.cost()))
+		diff := newTat.Sub(now)
+		if diff > window && !limit.Force {
+			return Result{Allowed: false, RetryAfter: diff - window, ResetAfter: tat.Sub(now)}
+		}
+
+		e.tat = newTat
+		e.expiration = newTat
+
+		return Result{Allowed: true, Remaining: int64(max(window-diff, 0) / interval), ResetAfter: diff}
+	})
+}
+
+func (m *MemoryStorage) Inspect(ctx context.Context, key string, limit Limit) (State, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	now := m.clock.Now()
+	state := State{
+		BlockedFor: s.blockedFor(key, now),
+		Tokens:     float64(limit.Requests),
+		Violations: s.violations(key, limit.Escalation, now),
+	}
+
+	if e, exists := s.data[key]; exists && e.expiration.After(now) {
+		state.Count = e.value
+		state.CountResetAfter = e.expiration.Sub(now)
+	}
+
+	if e, exists := s.data[key+":log"]; exists {
+		cutoff := now.Add(-limit.Window)
+		for _, t := range e.log {
+			if t.After(cutoff) {
+				state.Log++
+			}
+		}
+	}
+
+	if e, exists := s.data[key+":sw"]; exists && limit.Window > 0 {
+		start := now.Truncate(limit.Window)
+		switch {
+		case e.windowStart.Equal(start):
+			state.Current, state.Previous = e.value, e.previous
+		case e.windowStart.Add(limit.Window).Equal(start):
+			state.Previous = e.value
+		}
 	}
 
+	if e, exists := s.data[key+":tb"]; exists && limit.Window > 0 {
+		rate := float64(limit.Requests) / float64(limit.Window)
+		state.Tokens = math.Min(float64(limit.Requests), e.tokens+float64(now.Sub(e.updated))*rate)
+	}
+
+	if e, exists := s.data[key+":gcra"]; exists && e.tat.After(now) {
+		state.TAT = e.tat
+	}
+
+	if e, exists := s.data[key+":inflight"]; exists {
+		for _, expiration := range e.leases {
+			if expiration.After(now) {
+				state.InFlight++
+			}
+		}
+	}
+
+	return state, nil
+}
+
+func (m *MemoryStorage) Unblock(ctx context.Context, key string) error {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	s.delete(key + ":blocked")
 	return nil
 }
 
-func (m *MemoryStorage) IsBlocked(ctx context.Context, key string) (bool, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *MemoryStorage) Reset(ctx context.Context, key string) error {
+	s := m.lock(key)
+	defer s.mu.Unlock()
 
-	blockKey := key + ":blocked"
-	e, exists := m.data[blockKey]
-	if !exists || e.expiration.Before(time.Now()) {
+	for _, suffix := range []string{"", ":log", ":sw", ":tb", ":gcra", ":blocked", ":inflight", ":violations"} {
+		s.delete(key + suffix)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	now := m.clock.Now()
+	e := s.entry(key + ":inflight")
+	if e.leases == nil {
+		e.leases = make(map[string]time.Time)
+	}
+
+	for leaseID, expiration := range e.leases {
+		if !expiration.After(now) {
+			delete(e.leases, leaseID)
+		}
+	}
+	if int64(len(e.leases)) >= limit {
 		return false, nil
 	}
 
-	return e.value == 1, nil
+	e.leases[id] = now.Add(lease)
+	if e.expiration.Before(now.Add(lease)) {
+		e.expiration = now.Add(lease)
+	}
+	return true, nil
+}
+
+func (m *MemoryStorage) Renew(ctx context.Context, key, id string, lease time.Duration) error {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	e := s.get(key + ":inflight")
+	if e == nil {
+		return nil
+	}
+	if _, found := e.leases[id]; found {
+		now := m.clock.Now()
+		e.leases[id] = now.Add(lease)
+		if e.expiration.Before(now.Add(lease)) {
+			e.expiration = now.Add(lease)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) Release(ctx context.Context, key, id string) error {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	if e := s.get(key + ":inflight"); e != nil {
+		delete(e.leases, id)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) ConsumeQuota(ctx context.Context, quotas []Quota, cost int64) (QuotaResult, error) {
+	keys := make([]string, len(quotas))
+	for i, quota := range quotas {
+		keys[i] = quota.Key
+	}
+	unlock := m.lockAll(keys)
+	defer unlock()
+
+	now := m.clock.Now()
+	result := QuotaResult{Allowed: true, Exceeded: -1, Used: make([]int64, len(quotas))}
+	for i, quota := range quotas {
+		if e := m.shard(quota.Key).get(quota.Key); e != nil && e.expiration.After(now) {
+			result.Used[i] = e.value
+		}
+		if result.Allowed && !quota.Force && result.Used[i]+cost > quota.Limit {
+			result.Allowed = false
+			result.Exceeded = i
+		}
+	}
+	if !result.Allowed {
+		return result, nil
+	}
+
+	for i, quota := range quotas {
+		result.Used[i] += cost
+		m.shard(quota.Key).put(quota.Key, &entry{value: result.Used[i], expiration: quota.ExpiresAt})
+	}
+	return result, nil
+}
+
+func (m *MemoryStorage) QuotaUsage(ctx context.Context, keys []string) ([]int64, error) {
+	now := m.clock.Now()
+	used := make([]int64, len(keys))
+	for i, key := range keys {
+		s := m.lock(key)
+		if e := s.get(key); e != nil && e.expiration.After(now) {
+			used[i] = e.value
+		}
+		s.mu.Unlock()
+	}
+	return used, nil
+}
+
+// RestoreQuota só aumenta o consumo, para não desfazer o que foi consumido
+// depois do snapshot.
+func (m *MemoryStorage) RestoreQuota(ctx context.Context, quota Quota, used int64) error {
+	s := m.lock(quota.Key)
+	defer s.mu.Unlock()
+
+	now := m.clock.Now()
+	if !quota.ExpiresAt.After(now) {
+		return nil
+	}
+	if e := s.get(quota.Key); e != nil && e.expiration.After(now) && e.value >= used {
+		return nil
+	}
+	s.put(quota.Key, &entry{value: used, expiration: quota.ExpiresAt})
+	return nil
+}
+
+func (m *MemoryStorage) SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	s.put(key+":override", &entry{data: value, expiration: m.clock.Now().Add(ttl)})
+	return nil
+}
+
+func (m *MemoryStorage) GetOverride(ctx context.Context, key string) ([]byte, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	e := s.get(key + ":override")
+	if e == nil || !e.expiration.After(m.clock.Now()) {
+		return nil, nil
+	}
+	return e.data, nil
+}
+
+func (m *MemoryStorage) DeleteOverride(ctx context.Context, key string) (bool, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	e := s.get(key + ":override")
+	s.delete(key + ":override")
+	return e != nil && e.expiration.After(m.clock.Now()), nil
+}
+
+// limit executa o algoritmo e a verificação de bloqueio sob o mesmo lock,
+// espelhando a atomicidade dos scripts do RedisStorage.
+func (m *MemoryStorage) limit(key string, limit Limit, algorithm func(s *shard, now time.Time) Result) (Result, error) {
+	s := m.lock(key)
+	defer s.mu.Unlock()
+
+	now := m.clock.Now()
+	if blockedFor := s.blockedFor(key, now); blockedFor > 0 {
+		return Result{
+			Allowed:    false,
+			Blocked:    true,
+			RetryAfter: blockedFor,
+			ResetAfter: blockedFor,
+			Violations: s.violations(key, limit.Escalation, now),
+		}, nil
+	}
+
+	result := algorithm(s, now)
+	if !result.Allowed && limit.BlockDuration > 0 {
+		violations := s.violate(key, limit.Escalation, now)
+		block := limit.Escalation.block(limit.BlockDuration, violations)
+		s.setBlock(key, block, now)
+		return Result{
+			Allowed:     false,
+			Blocked:     true,
+			BlockIssued: true,
+			RetryAfter:  block,
+			ResetAfter:  block,
+			Violations:  violations,
+		}, nil
+	}
+
+	return result, nil
+}
+
+func (m *MemoryStorage) shard(key string) *shard {
+	return m.shards[maphash.String(m.seed, key)%uint64(len(m.shards))]
+}
+
+// lock trava e retorna o shard da chave.
+func (m *MemoryStorage) lock(key string) *shard {
+	s := m.shard(key)
+	s.mu.Lock()
+	return s
+}
+
+// lockAll trava os shards de todas as chaves, sempre na mesma ordem para que
+// duas chamadas concorrentes não fiquem esperando uma pela outra.
+func (m *MemoryStorage) lockAll(keys []string) (unlock func()) {
+	indexes := make([]int, 0, len(keys))
+	seen := make(map[uint64]bool, len(keys))
+	for _, key := range keys {
+		i := maphash.String(m.seed, key) % uint64(len(m.shards))
+		if !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, int(i))
+		}
+	}
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		m.shards[i].mu.Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			m.shards[i].mu.Unlock()
+		}
+	}
+}
+
+// len retorna o número de entradas em todos os shards.
+func (m *MemoryStorage) len() int {
+	n := 0
+	for _, s := range m.shards {
+		s.mu.Lock()
+		n += len(s.data)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (s *shard) increment(key string, n int64, expiration time.Duration, now time.Time) int64 {
+	e := s.get(key)
+
+	if e == nil || e.expiration.Before(now) {
+		s.put(key, &entry{
+			value:      n,
+			expiration: now.Add(expiration),
+		})
+		return n
+	}
+
+	e.value += n
+	return e.value
+}
+
+func (s *shard) setBlock(key string, duration time.Duration, now time.Time) {
+	s.put(key+":blocked", &entry{
+		value:      1,
+		expiration: now.Add(duration),
+	})
+}
+
+// violations retorna as violações recentes da chave.
+func (s *shard) violations(key string, escalation Escalation, now time.Time) int64 {
+	e := s.get(key + ":violations")
+	if e == nil {
+		return 0
+	}
+	return escalation.violations(e.value, e.updated, now)
+}
+
+// violate registra uma violação da chave e retorna as violações recentes,
+// incluindo ela. O histórico expira quando todas forem esquecidas.
+func (s *shard) violate(key string, escalation Escalation, now time.Time) int64 {
+	if !escalation.enabled() {
+		return 0
+	}
+	count := s.violations(key, escalation, now) + 1
+	s.put(key+":violations", &entry{
+		value:      count,
+		updated:    now,
+		expiration: now.Add(time.Duration(count) * escalation.Decay),
+	})
+	return count
+}
+
+func (s *shard) blockedFor(key string, now time.Time) time.Duration {
+	e := s.get(key + ":blocked")
+	if e == nil || e.value != 1 || e.expiration.Before(now) {
+		return 0
+	}
+
+	return e.expiration.Sub(now)
 }
 
 func (m *MemoryStorage) Close() error {
@@ -90,21 +633,19 @@ func (m *MemoryStorage) Close() error {
 	return nil
 }
 
-func (m *MemoryStorage) cleanupExpired() {
-	ticker := time.NewTicker(1 * time.Minute)
+// cleanupExpired avança a timing wheel de cada shard a cada tick, apagando só
+// as chaves agendadas para aquele tick em vez de percorrer todas.
+func (m *MemoryStorage) cleanupExpired(tick time.Duration) {
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			m.mu.Lock()
-			now := time.Now()
-			for key, e := range m.data {
-				if e.expiration.Before(now) {
-					delete(m.data, key)
-				}
+			now := m.clock.Now()
+			for _, s := range m.shards {
+				s.advance(now)
 			}
-			m.mu.Unlock()
 		case <-m.stopClean:
 			return
 		}
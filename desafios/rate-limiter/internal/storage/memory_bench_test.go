@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Os benchmarks comparam o MemoryStorage com a implementação anterior aos
+// shards, reproduzida em globalLockStorage:
+//
+//	go test ./internal/storage -run '^$' -bench MemoryStorage -cpu 1,8
+func BenchmarkMemoryStorage_FixedWindow(b *testing.B) {
+	limit := Limit{Requests: 100, Window: time.Minute}
+	keys := map[string]func(int64) string{
+		"same_key":  func(int64) string { return "ip:192.168.1.1" },
+		"key_spray": sprayKey,
+	}
+
+	for name, key := range keys {
+		b.Run("global_lock/"+name, func(b *testing.B) {
+			store := &globalLockStorage{data: make(map[string]*entry)}
+			benchmarkParallel(b, key, func(key string) { store.fixedWindow(key, limit) })
+		})
+		b.Run("sharded/"+name, func(b *testing.B) {
+			store := NewMemoryStorage()
+			defer store.Close()
+			benchmarkParallel(b, key, func(key string) { store.FixedWindow(context.Background(), key, limit) })
+		})
+		b.Run("sharded_bounded/"+name, func(b *testing.B) {
+			store := NewBoundedMemoryStorage(10000)
+			defer store.Close()
+			benchmarkParallel(b, key, func(key string) { store.FixedWindow(context.Background(), key, limit) })
+		})
+	}
+}
+
+// BenchmarkMemoryStorage_Expiration simula segundos em que chegam chaves novas
+// que expiram em 5 minutos, mantendo cerca de 100 mil chaves vivas. A
+// implementação anterior varre todas as chaves a cada minuto; a timing wheel
+// visita a cada segundo só as chaves agendadas.
+func BenchmarkMemoryStorage_Expiration(b *testing.B) {
+	const (
+		keys     = 100000
+		lifetime = 300
+		perTick  = keys / lifetime
+	)
+	start := time.Now()
+
+	b.Run("full_scan", func(b *testing.B) {
+		store := &globalLockStorage{data: make(map[string]*entry)}
+		var n int64
+		second := func(i int) {
+			now := start.Add(time.Duration(i) * time.Second)
+			for j := 0; j < perTick; j++ {
+				n++
+				store.data[sprayKey(n)] = &entry{expiration: now.Add(lifetime * time.Second)}
+			}
+			if i%60 == 0 {
+				store.cleanup(now)
+			}
+		}
+
+		for i := 0; i < lifetime; i++ {
+			second(i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			second(lifetime + i)
+		}
+	})
+
+	b.Run("timing_wheel", func(b *testing.B) {
+		// com o tick de uma hora a wheel fica parada em background e só avança
+		// nos segundos simulados
+		store := newMemoryStorage(defaultShards, 0, time.Hour)
+		defer store.Close()
+		for _, s := range store.shards {
+			s.tick = wheelTick
+		}
+
+		var n int64
+		second := func(i int) {
+			now := start.Add(time.Duration(i) * time.Second)
+			for j := 0; j < perTick; j++ {
+				n++
+				key := sprayKey(n)
+				store.shard(key).put(key, &entry{expiration: now.Add(lifetime * time.Second)})
+			}
+			for _, s := range store.shards {
+				s.advance(now)
+			}
+		}
+
+		for i := 0; i < lifetime; i++ {
+			second(i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			second(lifetime + i)
+		}
+	})
+}
+
+func benchmarkParallel(b *testing.B, key func(int64) string, op func(key string)) {
+	var n atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			op(key(n.Add(1)))
+		}
+	})
+}
+
+func sprayKey(i int64) string {
+	return fmt.Sprintf("ip:10.%d.%d.%d", i>>16&255, i>>8&255, i&255)
+}
+
+// globalLockStorage reproduz o MemoryStorage anterior: um único mutex sobre um
+// map, com a limpeza varrendo todas as chaves.
+type globalLockStorage struct {
+	mu   sync.RWMutex
+	data map[string]*entry
+}
+
+func (g *globalLockStorage) fixedWindow(key string, limit Limit) Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if e, exists := g.data[key+":blocked"]; exists && e.expiration.After(now) {
+		return Result{Allowed: false, Blocked: true}
+	}
+
+	e, exists := g.data[key]
+	if !exists || e.expiration.Before(now) {
+		e = &entry{expiration: now.Add(limit.Window)}
+		g.data[key] = e
+	}
+	e.value += limit.cost()
+	return Result{Allowed: e.value <= limit.Requests, Remaining: max(limit.Requests-e.value, 0)}
+}
+
+func (g *globalLockStorage) cleanup(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for key, e := range g.data {
+		if e.expiration.Before(now) {
+			delete(g.data, key)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,3 +45,594 @@ func TestMemoryStorage_BlockAndIsBlocked(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, blocked)
 }
+
+func TestMemoryStorage_FixedWindowBlocks(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	testFixedWindowBlocks(t, store)
+}
+
+func TestMemoryStorage_Escalation(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testEscalation(t, store, clk)
+}
+
+func TestMemoryStorage_SlidingLog(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testSlidingLog(t, store, clk)
+}
+
+func TestMemoryStorage_SlidingWindow(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testSlidingWindow(t, store, clk)
+}
+
+func TestMemoryStorage_TokenBucket(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testTokenBucket(t, store, clk)
+}
+
+func TestMemoryStorage_GCRA(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testGCRA(t, store, clk)
+}
+
+func TestMemoryStorage_InspectUnblockAndReset(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	testInspectUnblockAndReset(t, store)
+}
+
+const algorithmWindow = 300 * time.Millisecond
+
+// testClock é o relógio dos testes compartilhados entre os storages, avançado
+// no lugar de esperas.
+type testClock interface {
+	Now() time.Time
+	Advance(d time.Duration)
+}
+
+var algorithmLimit = Limit{Requests: 3, Window: algorithmWindow}
+
+func TestMemoryStorage_WeightedCost(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	testWeightedCost(t, store)
+}
+
+func TestMemoryStorage_Concurrency(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testConcurrency(t, store, clk)
+}
+
+func TestMemoryStorage_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := newMemoryStorage(1, 3, time.Hour)
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := store.Increment(ctx, key, time.Minute)
+		require.NoError(t, err)
+	}
+
+	// "a" passa a ser a mais usada, então "b" é descartada
+	_, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	_, err = store.Increment(ctx, "d", time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, store.len())
+	for key, want := range map[string]int64{"a": 1, "b": 0, "c": 1, "d": 1} {
+		count, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, want, count, key)
+	}
+}
+
+func TestMemoryStorage_BoundedUnderKeySpray(t *testing.T) {
+	store := NewBoundedMemoryStorage(1000)
+	defer store.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 20000; i++ {
+		_, err := store.FixedWindow(ctx, fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256), algorithmLimit)
+		require.NoError(t, err)
+	}
+
+	// o limite é dividido entre os shards e arredondado para cima
+	assert.LessOrEqual(t, store.len(), 1000+defaultShards)
+}
+
+func TestMemoryStorage_ExpiresWithTimingWheel(t *testing.T) {
+	store := newMemoryStorage(4, 0, 10*time.Millisecond)
+	defer store.Close()
+	ctx := context.Background()
+
+	_, err := store.Increment(ctx, "short", 30*time.Millisecond)
+	require.NoError(t, err)
+	_, err = store.Increment(ctx, "long", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.SetBlock(ctx, "blocked", 50*time.Millisecond))
+
+	// chaves com expiração estendida são reagendadas em vez de apagadas
+	acquired, err := store.Acquire(ctx, "lease", "a", 1, 20*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, store.Renew(ctx, "lease", "a", time.Hour))
+
+	assert.Eventually(t, func() bool { return store.len() == 2 }, time.Second, 10*time.Millisecond)
+
+	count, err := store.Get(ctx, "long")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	state, err := store.Inspect(ctx, "lease", algorithmLimit)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), state.InFlight)
+}
+
+func TestMemoryStorage_Quota(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	testQuota(t, store)
+}
+
+func testFixedWindowBlocks(t *testing.T, store Storage) {
+	ctx := context.Background()
+	limit := Limit{Requests: 2, Window: time.Second, BlockDuration: time.Minute}
+
+	for i := 1; i <= 2; i++ {
+		result, err := store.FixedWindow(ctx, "fw-key", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+		assert.Equal(t, int64(2-i), result.Remaining)
+	}
+
+	result, err := store.FixedWindow(ctx, "fw-key", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.True(t, result.Blocked)
+	assert.True(t, result.BlockIssued)
+	assert.Equal(t, time.Minute, result.RetryAfter)
+
+	blocked, err := store.IsBlocked(ctx, "fw-key")
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	count, err := store.Get(ctx, "fw-key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// enquanto bloqueada a chave não consome mais o contador
+	result, err = store.FixedWindow(ctx, "fw-key", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Blocked)
+	assert.False(t, result.BlockIssued)
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= time.Minute)
+
+	count, err = store.Get(ctx, "fw-key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func testEscalation(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+	limit := Limit{
+		Requests:      1,
+		Window:        time.Minute,
+		BlockDuration: time.Minute,
+		Escalation:    Escalation{Factor: 5, Max: 10 * time.Minute, Decay: time.Hour},
+	}
+
+	result, err := store.FixedWindow(ctx, "esc-key", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// cada violação seguida multiplica o bloqueio, até o máximo
+	for i, block := range []time.Duration{time.Minute, 5 * time.Minute, 10 * time.Minute} {
+		result, err = store.FixedWindow(ctx, "esc-key", limit)
+		require.NoError(t, err)
+		assert.True(t, result.BlockIssued)
+		assert.Equal(t, int64(i+1), result.Violations)
+		assert.Equal(t, block, result.RetryAfter)
+
+		result, err = store.FixedWindow(ctx, "esc-key", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Blocked)
+		assert.False(t, result.BlockIssued)
+		assert.Equal(t, int64(i+1), result.Violations)
+
+		require.NoError(t, store.Unblock(ctx, "esc-key"))
+	}
+
+	state, err := store.Inspect(ctx, "esc-key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), state.Violations, "Unblock should keep the violations")
+
+	require.NoError(t, store.Reset(ctx, "esc-key"))
+	state, err = store.Inspect(ctx, "esc-key", limit)
+	require.NoError(t, err)
+	assert.Zero(t, state.Violations)
+
+	// sem violar por um Decay, uma violação é esquecida
+	limit.Escalation.Decay = 300 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		result, err = store.FixedWindow(ctx, "decay-key", limit)
+		require.NoError(t, err)
+		require.NoError(t, store.Unblock(ctx, "decay-key"))
+	}
+	assert.Equal(t, int64(2), result.Violations)
+	assert.Equal(t, 5*time.Minute, result.RetryAfter)
+
+	clk.Advance(350 * time.Millisecond)
+	result, err = store.FixedWindow(ctx, "decay-key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Violations)
+	assert.Equal(t, 5*time.Minute, result.RetryAfter)
+
+	// sem Factor o bloqueio é sempre o mesmo
+	limit.Escalation = Escalation{}
+	for i := 0; i < 2; i++ {
+		result, err = store.FixedWindow(ctx, "fixed-key", limit)
+		require.NoError(t, err)
+		require.NoError(t, store.Unblock(ctx, "fixed-key"))
+	}
+	assert.Zero(t, result.Violations)
+	assert.Equal(t, time.Minute, result.RetryAfter)
+}
+
+func testSlidingLog(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		result, err := store.SlidingLog(ctx, "log-key", algorithmLimit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+		assert.Equal(t, int64(3-i), result.Remaining)
+	}
+
+	result, err := store.SlidingLog(ctx, "log-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Request 4 should be denied")
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= algorithmWindow)
+
+	clk.Advance(result.RetryAfter)
+
+	result, err = store.SlidingLog(ctx, "log-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Request after the oldest entry expired should be allowed")
+}
+
+func testSlidingWindow(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	// começa logo após o início de uma janela para que a próxima fronteira seja previsível
+	clk.Advance(clk.Now().Truncate(algorithmWindow).Add(algorithmWindow).Sub(clk.Now()) + 10*time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		result, err := store.SlidingWindow(ctx, "sw-key", algorithmLimit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+	}
+
+	result, err := store.SlidingWindow(ctx, "sw-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Request 4 should be denied")
+
+	// logo após a fronteira a janela anterior ainda pesa quase por completo
+	clk.Advance(clk.Now().Truncate(algorithmWindow).Add(algorithmWindow).Sub(clk.Now()) + 10*time.Millisecond)
+
+	result, err = store.SlidingWindow(ctx, "sw-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Request right after the boundary should be denied")
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= algorithmWindow)
+}
+
+func testTokenBucket(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		result, err := store.TokenBucket(ctx, "tb-key", algorithmLimit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+	}
+
+	result, err := store.TokenBucket(ctx, "tb-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Request 4 should be denied")
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= algorithmWindow/3)
+
+	clk.Advance(result.RetryAfter)
+
+	result, err = store.TokenBucket(ctx, "tb-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Request after refill should be allowed")
+
+	result, err = store.TokenBucket(ctx, "tb-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Only one token should have been refilled")
+}
+
+func testGCRA(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		result, err := store.GCRA(ctx, "gcra-key", algorithmLimit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Request %d should be allowed", i)
+		assert.Equal(t, int64(3-i), result.Remaining)
+	}
+
+	result, err := store.GCRA(ctx, "gcra-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Request 4 should be denied")
+	assert.True(t, result.RetryAfter > 0 && result.RetryAfter <= algorithmWindow/3)
+
+	clk.Advance(result.RetryAfter)
+
+	result, err = store.GCRA(ctx, "gcra-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Request after one emission interval should be allowed")
+
+	result, err = store.GCRA(ctx, "gcra-key", algorithmLimit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Only one request should fit after one emission interval")
+}
+
+func testInspectUnblockAndReset(t *testing.T, store Storage) {
+	ctx := context.Background()
+	limit := Limit{Requests: 2, Window: time.Minute, BlockDuration: time.Minute}
+
+	state, err := store.Inspect(ctx, "admin-key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, State{Tokens: 2}, state)
+
+	for i := 0; i < 3; i++ {
+		store.FixedWindow(ctx, "admin-key", Limit{Requests: 2, Window: time.Minute})
+	}
+	store.SlidingLog(ctx, "admin-key", limit)
+	store.SlidingWindow(ctx, "admin-key", limit)
+	store.TokenBucket(ctx, "admin-key", limit)
+	store.GCRA(ctx, "admin-key", limit)
+	require.NoError(t, store.SetBlock(ctx, "admin-key", time.Minute))
+	_, err = store.Acquire(ctx, "admin-key", "lease-1", 2, time.Minute)
+	require.NoError(t, err)
+
+	state, err = store.Inspect(ctx, "admin-key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), state.Count)
+	assert.True(t, state.CountResetAfter > 0 && state.CountResetAfter <= time.Minute)
+	assert.Equal(t, int64(1), state.Log)
+	assert.Equal(t, int64(1), state.Current+state.Previous)
+	assert.InDelta(t, 1, state.Tokens, 0.01)
+	assert.True(t, state.TAT.After(time.Now()))
+	assert.True(t, state.BlockedFor > 0 && state.BlockedFor <= time.Minute)
+	assert.Equal(t, int64(1), state.InFlight)
+
+	require.NoError(t, store.Unblock(ctx, "admin-key"))
+	blocked, err := store.IsBlocked(ctx, "admin-key")
+	require.NoError(t, err)
+	assert.False(t, blocked)
+
+	count, err := store.Get(ctx, "admin-key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count, "Unblock should keep the counters")
+
+	require.NoError(t, store.SetBlock(ctx, "admin-key", time.Minute))
+	require.NoError(t, store.Reset(ctx, "admin-key"))
+
+	state, err = store.Inspect(ctx, "admin-key", limit)
+	require.NoError(t, err)
+	assert.Equal(t, State{Tokens: 2}, state)
+
+	result, err := store.FixedWindow(ctx, "admin-key", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func testConcurrency(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		acquired, err := store.Acquire(ctx, "cc-key", id, 2, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, acquired)
+	}
+
+	acquired, err := store.Acquire(ctx, "cc-key", "c", 2, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "third concurrent request should be rejected")
+
+	require.NoError(t, store.Release(ctx, "cc-key", "a"))
+	acquired, err = store.Acquire(ctx, "cc-key", "c", 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "released slot should be reused")
+
+	// leases não renovados expiram e liberam a vaga, como se a instância caísse
+	_, err = store.Acquire(ctx, "lease-key", "crashed", 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	_, err = store.Acquire(ctx, "lease-key", "renewed", 2, 50*time.Millisecond)
+	require.NoError(t, err)
+
+	clk.Advance(30 * time.Millisecond)
+	require.NoError(t, store.Renew(ctx, "lease-key", "renewed", time.Minute))
+	clk.Advance(30 * time.Millisecond)
+
+	state, err := store.Inspect(ctx, "lease-key", Limit{Requests: 1, Window: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), state.InFlight)
+
+	acquired, err = store.Acquire(ctx, "lease-key", "next", 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "expired lease should free its slot")
+
+	acquired, err = store.Acquire(ctx, "lease-key", "over", 2, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "renewed lease should keep its slot")
+}
+
+func testWeightedCost(t *testing.T, store Storage) {
+	ctx := context.Background()
+
+	count, err := store.IncrementBy(ctx, "cost-counter", 5, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	count, err = store.Increment(ctx, "cost-counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+
+	algorithms := map[string]func(context.Context, string, Limit) (Result, error){
+		"fixed_window":   store.FixedWindow,
+		"sliding_log":    store.SlidingLog,
+		"sliding_window": store.SlidingWindow,
+		"token_bucket":   store.TokenBucket,
+		"gcra":           store.GCRA,
+	}
+
+	for name, allow := range algorithms {
+		key := "cost-" + name
+		limit := Limit{Requests: 10, Window: time.Minute, Cost: 4}
+
+		for _, remaining := range []int64{6, 2} {
+			result, err := allow(ctx, key, limit)
+			require.NoError(t, err, name)
+			assert.True(t, result.Allowed, name)
+			assert.Equal(t, remaining, result.Remaining, name)
+		}
+
+		result, err := allow(ctx, key, limit)
+		require.NoError(t, err, name)
+		assert.False(t, result.Allowed, "%s: cost above the remaining budget should be denied", name)
+		assert.True(t, result.RetryAfter > 0, name)
+
+		result, err = allow(ctx, key+"-expensive", Limit{Requests: 10, Window: time.Minute, Cost: 11})
+		require.NoError(t, err, name)
+		assert.False(t, result.Allowed, "%s: cost above the limit is never allowed", name)
+
+		// custos cobrados depois da resposta consomem o limite sem negar
+		forced := Limit{Requests: 10, Window: time.Minute, Cost: 20, Force: true}
+		result, err = allow(ctx, key+"-forced", forced)
+		require.NoError(t, err, name)
+		assert.True(t, result.Allowed, name)
+		assert.Equal(t, int64(0), result.Remaining, name)
+
+		result, err = allow(ctx, key+"-forced", Limit{Requests: 10, Window: time.Minute})
+		require.NoError(t, err, name)
+		assert.False(t, result.Allowed, name)
+		assert.False(t, result.Blocked, "%s: forced costs do not block", name)
+	}
+}
+
+func TestMemoryStorage_Override(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := NewMemoryStorage(WithClock(clk))
+	defer store.Close()
+
+	testOverride(t, store, clk)
+}
+
+func testOverride(t *testing.T, store Storage, clk testClock) {
+	ctx := context.Background()
+
+	value, err := store.GetOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, store.SetOverride(ctx, "override-key", []byte(`{"requests":10}`), time.Minute))
+	value, err = store.GetOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.Equal(t, `{"requests":10}`, string(value))
+
+	// o override não é um contador da chave e sobrevive ao Reset
+	require.NoError(t, store.Reset(ctx, "override-key"))
+	value, err = store.GetOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.NotNil(t, value)
+
+	deleted, err := store.DeleteOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = store.DeleteOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	require.NoError(t, store.SetOverride(ctx, "override-key", []byte(`{}`), time.Minute))
+	clk.Advance(time.Minute + time.Second)
+	value, err = store.GetOverride(ctx, "override-key")
+	require.NoError(t, err)
+	assert.Nil(t, value, "override should expire after its ttl")
+}
+
+func testQuota(t *testing.T, store Storage) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	daily := Quota{Key: "quota-daily", Limit: 5, ExpiresAt: expiresAt}
+	monthly := Quota{Key: "quota-monthly", Limit: 8, ExpiresAt: expiresAt}
+
+	result, err := store.ConsumeQuota(ctx, []Quota{daily, monthly}, 3)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, -1, result.Exceeded)
+	assert.Equal(t, []int64{3, 3}, result.Used)
+
+	// a cota diária não tem saldo, então nenhuma das duas é consumida
+	result, err = store.ConsumeQuota(ctx, []Quota{daily, monthly}, 3)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Exceeded)
+
+	used, err := store.QuotaUsage(ctx, []string{daily.Key, monthly.Key, "quota-unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 3, 0}, used)
+
+	// restaurar só aumenta o consumo
+	require.NoError(t, store.RestoreQuota(ctx, monthly, 7))
+	require.NoError(t, store.RestoreQuota(ctx, daily, 1))
+	used, err = store.QuotaUsage(ctx, []string{daily.Key, monthly.Key})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 7}, used)
+
+	result, err = store.ConsumeQuota(ctx, []Quota{daily, monthly}, 2)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 1, result.Exceeded)
+	assert.Equal(t, int64(7), result.Used[1])
+
+	// com Force, o custo é consumido mesmo sem saldo
+	forced := []Quota{daily, monthly}
+	for i := range forced {
+		forced[i].Force = true
+	}
+	result, err = store.ConsumeQuota(ctx, forced, 2)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, []int64{5, 9}, result.Used)
+
+	// cotas de períodos encerrados não são restauradas
+	expired := Quota{Key: "quota-expired", Limit: 5, ExpiresAt: time.Now().Add(-time.Second)}
+	require.NoError(t, store.RestoreQuota(ctx, expired, 4))
+	used, err = store.QuotaUsage(ctx, []string{expired.Key})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0}, used)
+}
@@ -3,16 +3,25 @@ package storage
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 	"github.com/go-redis/redis/v8"
 )
 
+// RedisStorage executa cada algoritmo em um script Lua. A hora atual é enviada
+// pela aplicação, não lida do Redis, para que o relógio de WithClock valha
+// também aqui; as instâncias devem manter os relógios sincronizados.
 type RedisStorage struct {
 	client *redis.Client
+	clock  clock.Clock
 }
 
-func NewRedisStorage(host, port, password string, db int) (*RedisStorage, error) {
+func NewRedisStorage(host, port, password string, db int, opts ...Option) (*RedisStorage, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", host, port),
 		Password: password,
@@ -26,19 +35,30 @@ func NewRedisStorage(host, port, password string, db int) (*RedisStorage, error)
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisStorage{client: client}, nil
+	return &RedisStorage{client: client, clock: newOptions(opts).clock}, nil
 }
 
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCRBY", KEYS[1], ARGV[2])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Increment só define a expiração quando a janela começa, para que tráfego
+// contínuo não estenda o TTL da chave.
 func (r *RedisStorage) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
-	pipe := r.client.Pipeline()
-	incr := pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, expiration)
+	return r.IncrementBy(ctx, key, 1, expiration)
+}
 
-	if _, err := pipe.Exec(ctx); err != nil {
+func (r *RedisStorage) IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error) {
+	count, err := incrementScript.Run(ctx, r.client, []string{key}, milliseconds(expiration), n).Int64()
+	if err != nil {
 		return 0, fmt.Errorf("failed to increment key %s: %w", key, err)
 	}
 
-	return incr.Val(), nil
+	return count, nil
 }
 
 func (r *RedisStorage) Get(ctx context.Context, key string) (int64, error) {
@@ -72,6 +92,585 @@ func (r *RedisStorage) IsBlocked(ctx context.Context, key string) (bool, error)
 	return val == "1", nil
 }
 
+// Cada algoritmo roda em um único script junto com a verificação e a criação do
+// bloqueio, de modo que a decisão é atômica entre réplicas e custa um round-trip.
+// KEYS[1] é a chave de bloqueio, KEYS[2] o histórico de violações, ARGV[1] o
+// tempo de bloqueio em milissegundos, ARGV[2] o custo da requisição, ARGV[3] é
+// 1 para consumir o custo sem negar (Limit.Force) e ARGV[4..7] são o instante
+// atual, o fator, o bloqueio máximo em milissegundos e o decaimento de
+// Limit.Escalation; o algoritmo usa KEYS[3..] e ARGV[8..] e retorna allowed,
+// remaining, retry e reset. O script acrescenta 1 quando a chave já estava
+// bloqueada e 2 quando o bloqueio foi criado agora, e o número de violações.
+// Instantes são passados em microssegundos e inteiros grandes são gravados com
+// string.format("%.0f") para não perder precisão no Lua.
+const limitScriptHeader = `
+local blocked_key = KEYS[1]
+local block = tonumber(ARGV[1])
+local cost = tonumber(ARGV[2])
+local force = ARGV[3] == "1"
+local violations_key = KEYS[2]
+local factor = tonumber(ARGV[5])
+local max_block = tonumber(ARGV[6])
+local decay = tonumber(ARGV[7])
+
+-- violações registradas, descontando uma por decay sem violar
+local function violations()
+	if factor <= 1 or decay <= 0 then
+		return 0
+	end
+	local state = redis.call("HMGET", violations_key, "count", "last")
+	local count = tonumber(state[1]) or 0
+	local last = tonumber(state[2]) or 0
+	return math.max(count - math.floor((tonumber(ARGV[4]) - last) / decay), 0)
+end
+
+local blocked_ttl = redis.call("PTTL", blocked_key)
+if blocked_ttl ~= -2 then
+	local ttl = math.max(blocked_ttl, 0) * 1000
+	return {0, 0, ttl, ttl, 1, violations()}
+end
+
+local allowed, remaining, retry, reset = (function()
+`
+
+const limitScriptFooter = `
+end)()
+
+if allowed == 0 and block > 0 then
+	local count = 0
+	if factor > 1 and decay > 0 then
+		count = violations() + 1
+		block = block * factor ^ (count - 1)
+		if max_block > 0 then
+			block = math.min(block, math.max(max_block, tonumber(ARGV[1])))
+		end
+		block = math.floor(block)
+		redis.call("HSET", violations_key, "count", count, "last", ARGV[4])
+		redis.call("PEXPIRE", violations_key, math.ceil(count * decay / 1000))
+	end
+	redis.call("SET", blocked_key, "1", "PX", block)
+	return {0, 0, block * 1000, block * 1000, 2, count}
+end
+return {allowed, remaining, retry, reset, 0, 0}
+`
+
+func newLimitScript(algorithm string) *redis.Script {
+	return redis.NewScript(limitScriptHeader + algorithm + limitScriptFooter)
+}
+
+var fixedWindowScript = newLimitScript(`
+local window = tonumber(ARGV[8])
+local limit = tonumber(ARGV[9])
+
+local count = redis.call("INCRBY", KEYS[3], cost)
+local ttl = redis.call("PTTL", KEYS[3])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[3], window)
+	ttl = window
+end
+
+if count > limit and not force then
+	return 0, 0, ttl * 1000, ttl * 1000
+end
+return 1, math.max(limit - count, 0), 0, ttl * 1000
+`)
+
+var slidingLogScript = newLimitScript(`
+local key = KEYS[3]
+local now = tonumber(ARGV[8])
+local cutoff = ARGV[9]
+local window = tonumber(ARGV[10])
+local limit = tonumber(ARGV[11])
+local member = ARGV[12]
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", cutoff)
+local count = redis.call("ZCARD", key)
+if count + cost > limit and not force then
+	local retry = window
+	local reset = window
+	local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+	local newest = redis.call("ZRANGE", key, -1, -1, "WITHSCORES")
+	if oldest[2] then
+		if cost <= limit then
+			local expiring = redis.call("ZRANGE", key, count + cost - limit - 1, count + cost - limit - 1, "WITHSCORES")
+			retry = tonumber(expiring[2]) + window - now
+		end
+		reset = tonumber(newest[2]) + window - now
+	end
+	return 0, 0, retry, reset
+end
+
+-- as entradas têm o mesmo instante, então mais de limit delas não mudam
+-- quando o limite volta a ter espaço
+for i = 1, math.min(cost, math.max(limit, 1)) do
+	redis.call("ZADD", key, ARGV[8], member .. "-" .. i)
+end
+count = redis.call("ZCARD", key)
+redis.call("PEXPIRE", key, math.ceil(window / 1000))
+return 1, math.max(limit - count, 0), 0, window
+`)
+
+var slidingWindowScript = newLimitScript(`
+local elapsed = tonumber(ARGV[8])
+local window = tonumber(ARGV[9])
+local limit = tonumber(ARGV[10])
+
+local current = tonumber(redis.call("GET", KEYS[3]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[4]) or "0")
+local estimated = previous * (window - elapsed) / window + current
+
+if estimated + cost > limit and not force then
+	local retry = window - elapsed
+	local reset = window - elapsed
+	if current + cost <= limit and previous > 0 then
+		retry = window * (1 - (limit - current - cost) / previous) - elapsed
+	end
+	if current > 0 then
+		reset = reset + window
+	end
+	return 0, 0, math.ceil(retry), reset
+end
+
+redis.call("INCRBY", KEYS[3], cost)
+redis.call("PEXPIRE", KEYS[3], math.ceil(2 * window / 1000))
+return 1, math.max(math.floor(limit - estimated - cost), 0), 0, 2 * window - elapsed
+`)
+
+var tokenBucketScript = newLimitScript(`
+local key = KEYS[3]
+local now = tonumber(ARGV[8])
+local window = tonumber(ARGV[9])
+local capacity = tonumber(ARGV[10])
+if capacity <= 0 then
+	return 0, 0, window, window
+end
+local rate = capacity / window
+
+local state = redis.call("HMGET", key, "tokens", "updated")
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * rate)
+	updated = now
+end
+
+-- com force o saldo pode ficar negativo, e a dívida é paga com o tempo
+local allowed = 0
+local retry = 0
+if tokens >= cost or force then
+	tokens = tokens - cost
+	allowed = 1
+else
+	retry = math.ceil((cost - tokens) / rate)
+end
+
+redis.call("HSET", key, "tokens", tostring(tokens), "updated", string.format("%.0f", updated))
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call("PEXPIRE", key, math.ceil(reset / 1000) + 1)
+return allowed, math.max(math.floor(tokens), 0), retry, reset
+`)
+
+var gcraScript = newLimitScript(`
+local key = KEYS[3]
+local now = tonumber(ARGV[8])
+local window = tonumber(ARGV[9])
+local limit = tonumber(ARGV[10])
+if limit <= 0 then
+	return 0, 0, window, window
+end
+local interval = window / limit
+
+local tat = tonumber(redis.call("GET", key) or ARGV[8])
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval * cost
+local diff = new_tat - now
+if diff > window and not force then
+	return 0, 0, math.ceil(diff - window), tat - now
+end
+
+redis.call("SET", key, string.format("%.0f", new_tat), "PX", math.ceil(diff / 1000))
+return 1, math.floor(math.max(window - diff, 0) / interval), 0, diff
+`)
+
+func (r *RedisStorage) FixedWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.limit(ctx, fixedWindowScript, key, limit, []string{key},
+		milliseconds(limit.Window), limit.Requests)
+}
+
+func (r *RedisStorage) SlidingLog(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := r.clock.Now().UnixMicro()
+	member := strconv.FormatInt(now, 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
+
+	return r.limit(ctx, slidingLogScript, key, limit, []string{key + ":log"},
+		now, now-limit.Window.Microseconds(), limit.Window.Microseconds(), limit.Requests, member)
+}
+
+func (r *RedisStorage) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := r.clock.Now()
+	start := now.Truncate(limit.Window)
+	index := start.UnixNano() / int64(limit.Window)
+
+	keys := []string{
+		fmt.Sprintf("%s:sw:%d", key, index),
+		fmt.Sprintf("%s:sw:%d", key, index-1),
+	}
+
+	return r.limit(ctx, slidingWindowScript, key, limit, keys,
+		now.Sub(start).Microseconds(), limit.Window.Microseconds(), limit.Requests)
+}
+
+func (r *RedisStorage) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.limit(ctx, tokenBucketScript, key, limit, []string{key + ":tb"},
+		r.clock.Now().UnixMicro(), limit.Window.Microseconds(), limit.Requests)
+}
+
+func (r *RedisStorage) GCRA(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.limit(ctx, gcraScript, key, limit, []string{key + ":gcra"},
+		r.clock.Now().UnixMicro(), limit.Window.Microseconds(), limit.Requests)
+}
+
+func (r *RedisStorage) limit(ctx context.Context, script *redis.Script, key string, limit Limit, keys []string, args ...interface{}) (Result, error) {
+	force := 0
+	if limit.Force {
+		force = 1
+	}
+	escalation := limit.Escalation
+	keys = append([]string{key + ":blocked", key + ":violations"}, keys...)
+	args = append([]interface{}{
+		milliseconds(limit.BlockDuration), limit.cost(), force,
+		r.clock.Now().UnixMicro(), escalation.Factor, milliseconds(escalation.Max), escalation.Decay.Microseconds(),
+	}, args...)
+
+	values, err := script.Run(ctx, r.client, keys, args...).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to check limit for key %s: %w", key, err)
+	}
+	if len(values) != 6 {
+		return Result{}, fmt.Errorf("unexpected script result for key %s: %v", key, values)
+	}
+
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retryAfter, _ := values[2].(int64)
+	resetAfter, _ := values[3].(int64)
+	blocked, _ := values[4].(int64)
+	violations, _ := values[5].(int64)
+
+	return Result{
+		Allowed:     allowed == 1,
+		Blocked:     blocked > 0,
+		BlockIssued: blocked == 2,
+		Remaining:   remaining,
+		RetryAfter:  time.Duration(retryAfter) * time.Microsecond,
+		ResetAfter:  time.Duration(resetAfter) * time.Microsecond,
+		Violations:  violations,
+	}, nil
+}
+
+func (r *RedisStorage) Inspect(ctx context.Context, key string, limit Limit) (State, error) {
+	now := r.clock.Now()
+
+	pipe := r.client.Pipeline()
+	blockedTTL := pipe.PTTL(ctx, key+":blocked")
+	count := pipe.Get(ctx, key)
+	countTTL := pipe.PTTL(ctx, key)
+	logCount := pipe.ZCount(ctx, key+":log", "("+strconv.FormatInt(now.Add(-limit.Window).UnixMicro(), 10), "+inf")
+	var current, previous *redis.StringCmd
+	if limit.Window > 0 {
+		index := now.Truncate(limit.Window).UnixNano() / int64(limit.Window)
+		current = pipe.Get(ctx, fmt.Sprintf("%s:sw:%d", key, index))
+		previous = pipe.Get(ctx, fmt.Sprintf("%s:sw:%d", key, index-1))
+	}
+	bucket := pipe.HMGet(ctx, key+":tb", "tokens", "updated")
+	inFlight := pipe.ZCount(ctx, key+":inflight", "("+strconv.FormatInt(now.UnixMicro(), 10), "+inf")
+	tat := pipe.Get(ctx, key+":gcra")
+	violations := pipe.HMGet(ctx, key+":violations", "count", "last")
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return State{}, fmt.Errorf("failed to inspect key %s: %w", key, err)
+	}
+
+	state := State{Tokens: float64(limit.Requests)}
+	if ttl := blockedTTL.Val(); ttl > 0 {
+		state.BlockedFor = ttl
+	}
+	if ttl := countTTL.Val(); ttl > 0 {
+		state.Count, _ = count.Int64()
+		state.CountResetAfter = ttl
+	}
+	state.Log = logCount.Val()
+	state.InFlight = inFlight.Val()
+	if current != nil {
+		state.Current, _ = current.Int64()
+		state.Previous, _ = previous.Int64()
+	}
+
+	if values := bucket.Val(); len(values) == 2 && values[0] != nil && values[1] != nil && limit.Window > 0 {
+		tokens, _ := strconv.ParseFloat(fmt.Sprint(values[0]), 64)
+		updated, _ := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+		rate := float64(limit.Requests) / float64(limit.Window)
+		elapsed := now.Sub(time.UnixMicro(updated))
+		state.Tokens = math.Min(float64(limit.Requests), tokens+float64(elapsed)*rate)
+	}
+
+	if values := violations.Val(); len(values) == 2 && values[0] != nil && values[1] != nil {
+		count, _ := strconv.ParseInt(fmt.Sprint(values[0]), 10, 64)
+		last, _ := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+		state.Violations = limit.Escalation.violations(count, time.UnixMicro(last), now)
+	}
+
+	if value, err := tat.Int64(); err == nil {
+		if t := time.UnixMicro(value); t.After(now) {
+			state.TAT = t
+		}
+	}
+
+	return state, nil
+}
+
+// O semáforo é um sorted set com o id de cada lease e o instante em que ele
+// expira (em microssegundos). Leases vencidos são removidos antes de contar as
+// vagas ocupadas.
+var acquireScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[3])
+local lease = tonumber(ARGV[5])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", ARGV[1])
+if redis.call("ZCARD", key) >= limit then
+	return 0
+end
+
+redis.call("ZADD", key, ARGV[2], ARGV[4])
+if redis.call("PTTL", key) < lease then
+	redis.call("PEXPIRE", key, lease)
+end
+return 1
+`)
+
+var renewScript = redis.NewScript(`
+local key = KEYS[1]
+local lease = tonumber(ARGV[3])
+
+if not redis.call("ZSCORE", key, ARGV[2]) then
+	return 0
+end
+
+redis.call("ZADD", key, ARGV[1], ARGV[2])
+if redis.call("PTTL", key) < lease then
+	redis.call("PEXPIRE", key, lease)
+end
+return 1
+`)
+
+func (r *RedisStorage) Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error) {
+	now := r.clock.Now()
+	acquired, err := acquireScript.Run(ctx, r.client, []string{key + ":inflight"},
+		now.UnixMicro(), now.Add(lease).UnixMicro(), limit, id, milliseconds(lease)).Int64()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire slot for key %s: %w", key, err)
+	}
+	return acquired == 1, nil
+}
+
+func (r *RedisStorage) Renew(ctx context.Context, key, id string, lease time.Duration) error {
+	err := renewScript.Run(ctx, r.client, []string{key + ":inflight"},
+		r.clock.Now().Add(lease).UnixMicro(), id, milliseconds(lease)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to renew slot for key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) Release(ctx context.Context, key, id string) error {
+	if err := r.client.ZRem(ctx, key+":inflight", id).Err(); err != nil {
+		return fmt.Errorf("failed to release slot for key %s: %w", key, err)
+	}
+	return nil
+}
+
+// As cotas são verificadas e consumidas juntas: o custo só é somado quando cabe
+// em todas. ARGV[1] é o custo e, para cada chave i, ARGV[2i] é o limite (-1
+// com Force) e ARGV[2i+1] o instante de expiração em milissegundos. Retorna o índice
+// (base 1) da cota excedida, ou 0, seguido do consumo de cada cota.
+var consumeQuotaScript = redis.NewScript(`
+local cost = tonumber(ARGV[1])
+local used = {}
+local exceeded = 0
+
+for i = 1, #KEYS do
+	used[i] = tonumber(redis.call("GET", KEYS[i]) or "0")
+	local limit = tonumber(ARGV[i * 2])
+	if exceeded == 0 and limit >= 0 and used[i] + cost > limit then
+		exceeded = i
+	end
+end
+
+if exceeded == 0 then
+	for i = 1, #KEYS do
+		used[i] = redis.call("INCRBY", KEYS[i], cost)
+		redis.call("PEXPIREAT", KEYS[i], ARGV[i * 2 + 1])
+	end
+end
+
+table.insert(used, 1, exceeded)
+return used
+`)
+
+var restoreQuotaScript = redis.NewScript(`
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+if used < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+	redis.call("PEXPIREAT", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (r *RedisStorage) ConsumeQuota(ctx context.Context, quotas []Quota, cost int64) (QuotaResult, error) {
+	if len(quotas) == 0 {
+		return QuotaResult{Allowed: true, Exceeded: -1}, nil
+	}
+
+	keys := make([]string, len(quotas))
+	args := []interface{}{cost}
+	for i, quota := range quotas {
+		keys[i] = quota.Key
+		limit := quota.Limit
+		if quota.Force {
+			limit = -1
+		}
+		args = append(args, limit, quota.ExpiresAt.UnixMilli())
+	}
+
+	values, err := consumeQuotaScript.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return QuotaResult{}, fmt.Errorf("failed to consume quota %s: %w", keys[0], err)
+	}
+	if len(values) != len(quotas)+1 {
+		return QuotaResult{}, fmt.Errorf("unexpected quota script result: %v", values)
+	}
+
+	return QuotaResult{
+		Allowed:  values[0] == 0,
+		Exceeded: int(values[0]) - 1,
+		Used:     values[1:],
+	}, nil
+}
+
+func (r *RedisStorage) QuotaUsage(ctx context.Context, keys []string) ([]int64, error) {
+	used := make([]int64, len(keys))
+	if len(keys) == 0 {
+		return used, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			used[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return used, nil
+}
+
+// RestoreQuota só aumenta o consumo, para não desfazer o que foi consumido
+// depois do snapshot.
+func (r *RedisStorage) RestoreQuota(ctx context.Context, quota Quota, used int64) error {
+	if !quota.ExpiresAt.After(r.clock.Now()) {
+		return nil
+	}
+
+	err := restoreQuotaScript.Run(ctx, r.client, []string{quota.Key}, used, quota.ExpiresAt.UnixMilli()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to restore quota %s: %w", quota.Key, err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.client.Set(ctx, key+":override", value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set override for key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetOverride(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key+":override").Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get override for key %s: %w", key, err)
+	}
+	return value, nil
+}
+
+func (r *RedisStorage) DeleteOverride(ctx context.Context, key string) (bool, error) {
+	deleted, err := r.client.Del(ctx, key+":override").Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete override for key %s: %w", key, err)
+	}
+	return deleted > 0, nil
+}
+
+func (r *RedisStorage) Unblock(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, key+":blocked").Err(); err != nil {
+		return fmt.Errorf("failed to unblock key %s: %w", key, err)
+	}
+	return nil
+}
+
+// Reset remove o contador, o bloqueio, o histórico de violações e o estado de
+// todos os algoritmos da chave. As janelas do sliding window são indexadas pelo
+// tempo e por isso são encontradas com SCAN.
+func (r *RedisStorage) Reset(ctx context.Context, key string) error {
+	keys := []string{key, key + ":log", key + ":tb", key + ":gcra", key + ":blocked", key + ":inflight", key + ":violations"}
+
+	iter := r.client.Scan(ctx, 0, escapePattern(key)+":sw:*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to reset key %s: %w", key, err)
+	}
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to reset key %s: %w", key, err)
+	}
+	return nil
+}
+
+// escapePattern escapa os caracteres especiais do MATCH do SCAN.
+func escapePattern(key string) string {
+	var b strings.Builder
+	for _, c := range key {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func milliseconds(d time.Duration) int64 {
+	ms := d.Milliseconds()
+	if d > 0 && time.Duration(ms)*time.Millisecond < d {
+		ms++
+	}
+	return ms
+}
+
+// Ping verifica se o Redis está respondendo, usado como health probe.
+func (r *RedisStorage) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *RedisStorage) Close() error {
 	return r.client.Close()
 }
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisStorage(t *testing.T, opts ...Option) (*RedisStorage, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	host, port, err := net.SplitHostPort(mr.Addr())
+	require.NoError(t, err)
+
+	store, err := NewRedisStorage(host, port, "", 0, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store, mr
+}
+
+// redisClock avança o relógio da aplicação e as expirações do miniredis juntos.
+type redisClock struct {
+	*clock.Fake
+	mr *miniredis.Miniredis
+}
+
+func (c redisClock) Advance(d time.Duration) {
+	c.Fake.Advance(d)
+	c.mr.FastForward(d)
+}
+
+func newTestRedisStorageWithClock(t *testing.T) (*RedisStorage, testClock) {
+	clk := clock.NewFake(time.Now())
+	store, mr := newTestRedisStorage(t, WithClock(clk))
+	return store, redisClock{Fake: clk, mr: mr}
+}
+
+func TestRedisStorage_IncrementAndBlock(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	count, err := store.Increment(ctx, "test-key", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = store.Increment(ctx, "test-key", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	err = store.SetBlock(ctx, "test-key", time.Second)
+	require.NoError(t, err)
+
+	blocked, err := store.IsBlocked(ctx, "test-key")
+	require.NoError(t, err)
+	assert.True(t, blocked)
+}
+
+func TestRedisStorage_IncrementDoesNotSlideTTL(t *testing.T) {
+	store, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+
+	_, err := store.Increment(ctx, "test-key", time.Second)
+	require.NoError(t, err)
+
+	mr.FastForward(600 * time.Millisecond)
+
+	_, err = store.Increment(ctx, "test-key", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 400*time.Millisecond, mr.TTL("test-key"))
+}
+
+func TestRedisStorage_FixedWindowBlocks(t *testing.T) {
+	store, mr := newTestRedisStorage(t)
+	testFixedWindowBlocks(t, store)
+
+	assert.Equal(t, time.Minute, mr.TTL("fw-key:blocked"))
+	assert.Equal(t, time.Second, mr.TTL("fw-key"))
+}
+
+func TestRedisStorage_FixedWindowSingleRoundTrip(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 5, Window: time.Second, BlockDuration: time.Minute}
+
+	// a primeira chamada carrega o script no servidor (EVALSHA + EVAL)
+	_, err := store.FixedWindow(ctx, "rt-key", limit)
+	require.NoError(t, err)
+
+	hook := &commandCounter{}
+	store.client.AddHook(hook)
+
+	for i := 0; i < 10; i++ {
+		_, err = store.FixedWindow(ctx, "rt-key", limit)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(10), hook.count)
+}
+
+func TestRedisStorage_FixedWindowConcurrent(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 10, Window: time.Minute, BlockDuration: time.Minute}
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.FixedWindow(ctx, "concurrent-key", limit)
+			if assert.NoError(t, err) && result.Allowed {
+				atomic.AddInt64(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), allowed)
+}
+
+func TestRedisStorage_Escalation(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testEscalation(t, store, clk)
+}
+
+func TestRedisStorage_SlidingLog(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testSlidingLog(t, store, clk)
+}
+
+func TestRedisStorage_SlidingWindow(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testSlidingWindow(t, store, clk)
+}
+
+func TestRedisStorage_TokenBucket(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testTokenBucket(t, store, clk)
+}
+
+func TestRedisStorage_GCRA(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testGCRA(t, store, clk)
+}
+
+func TestRedisStorage_InspectUnblockAndReset(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	testInspectUnblockAndReset(t, store)
+}
+
+func TestRedisStorage_WeightedCost(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	testWeightedCost(t, store)
+}
+
+func TestRedisStorage_Concurrency(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testConcurrency(t, store, clk)
+}
+
+func TestRedisStorage_Quota(t *testing.T) {
+	store, _ := newTestRedisStorage(t)
+	testQuota(t, store)
+}
+
+func TestRedisStorage_Override(t *testing.T) {
+	store, clk := newTestRedisStorageWithClock(t)
+	testOverride(t, store, clk)
+}
+
+func TestRedisStorage_ResetEscapesKey(t *testing.T) {
+	store, mr := newTestRedisStorage(t)
+	ctx := context.Background()
+	limit := Limit{Requests: 5, Window: time.Minute}
+
+	_, err := store.SlidingWindow(ctx, "token:a*", limit)
+	require.NoError(t, err)
+	_, err = store.SlidingWindow(ctx, "token:ab", limit)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Reset(ctx, "token:a*"))
+	assert.Len(t, mr.Keys(), 1)
+	assert.Contains(t, mr.Keys()[0], "token:ab:sw:")
+}
+
+type commandCounter struct {
+	count int64
+}
+
+func (c *commandCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.count, 1)
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (c *commandCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.count, int64(len(cmds)))
+	return ctx, nil
+}
+
+func (c *commandCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
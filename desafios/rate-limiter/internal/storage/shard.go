@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
+)
+
+// shard é uma partição do MemoryStorage com lock próprio. Guarda as entradas
+// em ordem de uso, para descartar a menos usada quando passa de maxKeys, e uma
+// timing wheel que agenda a verificação de expiração de cada entrada.
+type shard struct {
+	mu    sync.Mutex
+	data  map[string]*entry
+	clock clock.Clock
+
+	// chaves da usada mais recentemente para a menos usada
+	recent  *list.List
+	maxKeys int
+
+	// cada posição da wheel tem as chaves a verificar quando o cursor passar
+	// por ela, a cada tick
+	wheel [][]string
+	ticks uint64
+	tick  time.Duration
+}
+
+func newShard(maxKeys, slots int, tick time.Duration, clk clock.Clock) *shard {
+	return &shard{
+		data:    make(map[string]*entry),
+		clock:   clk,
+		recent:  list.New(),
+		maxKeys: maxKeys,
+		wheel:   make([][]string, slots),
+		tick:    tick,
+	}
+}
+
+// get retorna a entrada da chave, ou nil, e a marca como usada.
+func (s *shard) get(key string) *entry {
+	e, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+	s.recent.MoveToFront(e.element)
+	return e
+}
+
+// entry retorna a entrada da chave, criando uma vazia se não existir.
+func (s *shard) entry(key string) *entry {
+	if e := s.get(key); e != nil {
+		return e
+	}
+	e := &entry{}
+	s.put(key, e)
+	return e
+}
+
+// put substitui a entrada da chave. Novas chaves são agendadas para a
+// expiração que já tiverem ou, sem ela, para o próximo tick, quando são
+// reagendadas.
+func (s *shard) put(key string, e *entry) {
+	if old, exists := s.data[key]; exists {
+		e.element, e.due = old.element, old.due
+		s.data[key] = e
+		s.recent.MoveToFront(e.element)
+		return
+	}
+
+	e.element = s.recent.PushFront(key)
+	s.data[key] = e
+	s.schedule(key, e, e.expiration.Sub(s.clock.Now()))
+	s.evict()
+}
+
+func (s *shard) delete(key string) {
+	if e, exists := s.data[key]; exists {
+		s.recent.Remove(e.element)
+		delete(s.data, key)
+	}
+}
+
+// evict descarta as entradas menos usadas além de maxKeys, como o Redis com a
+// política allkeys-lru.
+func (s *shard) evict() {
+	for s.maxKeys > 0 && len(s.data) > s.maxKeys {
+		s.delete(s.recent.Back().Value.(string))
+	}
+}
+
+// schedule agenda a verificação da chave daqui a ticks. Só o agendamento mais
+// recente vale: os anteriores, de uma chave apagada e recriada, são ignorados.
+func (s *shard) schedule(key string, e *entry, after time.Duration) {
+	ticks := min(max((after+s.tick-1)/s.tick, 1), time.Duration(len(s.wheel)-1))
+	e.due = s.ticks + uint64(ticks)
+	slot := e.due % uint64(len(s.wheel))
+	s.wheel[slot] = append(s.wheel[slot], key)
+}
+
+// advance move o cursor da wheel e verifica as chaves agendadas: as expiradas
+// são apagadas e as que tiveram a expiração estendida, reagendadas. Chaves
+// com expiração além do alcance da wheel dão mais de uma volta.
+func (s *shard) advance(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ticks++
+	slot := s.ticks % uint64(len(s.wheel))
+	keys := s.wheel[slot]
+	s.wheel[slot] = nil
+
+	for _, key := range keys {
+		e, exists := s.data[key]
+		if !exists || e.due != s.ticks {
+			continue
+		}
+		if !e.expiration.After(now) {
+			s.delete(key)
+			continue
+		}
+		s.schedule(key, e, e.expiration.Sub(now))
+	}
+}
@@ -2,13 +2,178 @@ package storage
 
 import (
 	"context"
+	"math"
 	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/clock"
 )
 
 type Storage interface {
 	Increment(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	IncrementBy(ctx context.Context, key string, n int64, expiration time.Duration) (int64, error)
 	Get(ctx context.Context, key string) (int64, error)
 	SetBlock(ctx context.Context, key string, duration time.Duration) error
 	IsBlocked(ctx context.Context, key string) (bool, error)
+	FixedWindow(ctx context.Context, key string, limit Limit) (Result, error)
+	SlidingLog(ctx context.Context, key string, limit Limit) (Result, error)
+	SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error)
+	TokenBucket(ctx context.Context, key string, limit Limit) (Result, error)
+	GCRA(ctx context.Context, key string, limit Limit) (Result, error)
+	Inspect(ctx context.Context, key string, limit Limit) (State, error)
+	Unblock(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	Acquire(ctx context.Context, key, id string, limit int64, lease time.Duration) (bool, error)
+	Renew(ctx context.Context, key, id string, lease time.Duration) error
+	Release(ctx context.Context, key, id string) error
+	ConsumeQuota(ctx context.Context, quotas []Quota, cost int64) (QuotaResult, error)
+	QuotaUsage(ctx context.Context, keys []string) ([]int64, error)
+	RestoreQuota(ctx context.Context, quota Quota, used int64) error
+	SetOverride(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	GetOverride(ctx context.Context, key string) ([]byte, error)
+	DeleteOverride(ctx context.Context, key string) (bool, error)
 	Close() error
 }
+
+// Option configura os storages criados por NewMemoryStorage e NewRedisStorage.
+type Option func(*options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock troca o relógio usado nas janelas, bloqueios, leases e cotas. Nos
+// testes, um clock.Fake avança o tempo sem esperas.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SetOverride, GetOverride e DeleteOverride guardam o override de limite de
+// uma chave, já serializado, por ttl. Ficam no storage para valerem em todas as
+// instâncias; GetOverride retorna nil quando não há override ativo.
+
+// Acquire, Renew e Release formam um semáforo por chave para limitar
+// requisições simultâneas. Cada vaga é um lease identificado por id que expira
+// após lease se não for renovado, de modo que vagas de instâncias que caíram
+// são liberadas sozinhas.
+
+// Limit descreve a política aplicada a uma chave. Quando BlockDuration é
+// positivo, a chave fica bloqueada por esse tempo após exceder o limite. Cost é
+// quanto a requisição consome do limite (1 quando não positivo). Com Force, Cost
+// é consumido mesmo acima do limite, sem negar nem bloquear, para cobrar custos
+// conhecidos só depois da resposta. Escalation aumenta o bloqueio de quem
+// volta a exceder o limite.
+type Limit struct {
+	Requests      int64
+	Window        time.Duration
+	BlockDuration time.Duration
+	Cost          int64
+	Force         bool
+	Escalation    Escalation
+}
+
+// Escalation multiplica o bloqueio por Factor a cada violação recente da
+// chave, até Max (sem teto quando zero): com BlockDuration de 5m e Factor 6, os
+// bloqueios seguidos duram 5m, 30m, 3h... Cada Decay sem violar esquece uma
+// violação. Factor até 1 desativa o histórico.
+type Escalation struct {
+	Factor float64
+	Max    time.Duration
+	Decay  time.Duration
+}
+
+func (e Escalation) enabled() bool {
+	return e.Factor > 1 && e.Decay > 0
+}
+
+// violations retorna as violações que restam de count, registradas até last,
+// depois de descontar uma por Decay passado.
+func (e Escalation) violations(count int64, last, now time.Time) int64 {
+	if !e.enabled() || count <= 0 {
+		return 0
+	}
+	return max(count-int64(now.Sub(last)/e.Decay), 0)
+}
+
+// block retorna a duração do bloqueio da violação de número count.
+func (e Escalation) block(base time.Duration, count int64) time.Duration {
+	if !e.enabled() || count <= 1 {
+		return base
+	}
+	block := float64(base) * math.Pow(e.Factor, float64(count-1))
+	if e.Max > 0 {
+		block = math.Min(block, float64(max(e.Max, base)))
+	}
+	return time.Duration(math.Min(block, math.MaxInt64))
+}
+
+func (l Limit) cost() int64 {
+	if l.Cost < 1 {
+		return 1
+	}
+	return l.Cost
+}
+
+// Result é o resultado de uma tentativa de consumir uma requisição do limite.
+// RetryAfter é o tempo até a próxima requisição ser aceita e ResetAfter o tempo
+// até o limite estar completo novamente. Blocked indica que a chave está
+// bloqueada, e nesse caso ambos correspondem ao tempo restante do bloqueio.
+// BlockIssued indica que o bloqueio foi criado por esta requisição e
+// Violations é o número de violações recentes da chave quando bloqueada com
+// Limit.Escalation.
+type Result struct {
+	Allowed     bool
+	Blocked     bool
+	BlockIssued bool
+	Remaining   int64
+	RetryAfter  time.Duration
+	ResetAfter  time.Duration
+	Violations  int64
+}
+
+// State é o estado armazenado de uma chave, usado pela API administrativa. Cada
+// algoritmo preenche apenas os seus campos: Count (fixed_window), Log
+// (sliding_log), Current e Previous (sliding_window), Tokens (token_bucket,
+// já reabastecido até agora) e TAT (gcra). BlockedFor é o tempo restante do
+// bloqueio da chave, InFlight o número de leases de concorrência ativos e
+// Violations o número de violações recentes, com Limit.Escalation.
+type State struct {
+	Count           int64
+	CountResetAfter time.Duration
+	Log             int64
+	Current         int64
+	Previous        int64
+	Tokens          float64
+	TAT             time.Time
+	BlockedFor      time.Duration
+	InFlight        int64
+	Violations      int64
+}
+
+// Quota é um contador de consumo acumulado, como a cota diária de um token, que
+// expira em ExpiresAt, o fim do período. Com Force, o custo é consumido mesmo
+// que exceda Limit, como o custo final cobrado depois da requisição.
+type Quota struct {
+	Key       string
+	Limit     int64
+	ExpiresAt time.Time
+	Force     bool
+}
+
+// QuotaResult é o resultado de ConsumeQuota. O custo só é consumido quando cabe
+// em todas as cotas; caso contrário, Exceeded é o índice da primeira cota sem
+// saldo. Used é o consumo de cada cota depois da operação.
+type QuotaResult struct {
+	Allowed  bool
+	Exceeded int
+	Used     []int64
+}
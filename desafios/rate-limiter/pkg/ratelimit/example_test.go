@@ -0,0 +1,74 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/pkg/ratelimit"
+	"github.com/go-chi/chi/v5"
+)
+
+// Protege as rotas de produtos de uma API chi, como a de 7-APIS: clientes com
+// chave de API têm o próprio limite e os demais são limitados pelo IP.
+func Example() {
+	store := ratelimit.NewMemoryStorage()
+	defer store.Close()
+
+	limiter, err := ratelimit.New(store,
+		ratelimit.WithPrefix("products"),
+		ratelimit.WithLimit(ratelimit.Limit{Requests: 2, Window: time.Minute, Algorithm: ratelimit.SlidingWindow}),
+		ratelimit.WithKeyFunc(ratelimit.FirstOf(ratelimit.ByHeader("API_KEY"), ratelimit.ByIP())),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router := chi.NewRouter()
+	router.Route("/products", func(r chi.Router) {
+		r.Use(limiter.Handler)
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[]`))
+		})
+	})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/products/", nil))
+		fmt.Println(w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+	// Output:
+	// 200 1
+	// 200 0
+	// 429 0
+}
+
+// Chaves próprias são funções com a assinatura de KeyFunc, como o usuário
+// autenticado guardado no contexto.
+func ExampleKeyFunc() {
+	type userKey struct{}
+
+	byUser := ratelimit.KeyFunc(func(r *http.Request) (string, error) {
+		user, ok := r.Context().Value(userKey{}).(string)
+		if !ok {
+			return "", ratelimit.ErrNoKey
+		}
+		return "user:" + user, nil
+	})
+
+	store := ratelimit.NewMemoryStorage()
+	defer store.Close()
+
+	limiter, err := ratelimit.New(store, ratelimit.WithKeyFunc(byUser))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	limiter.Handler(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	fmt.Println(w.Code)
+	// Output:
+	// 401
+}
@@ -0,0 +1,29 @@
+// Package ginratelimit adapta o ratelimit.Limiter para o gin, sem trazer o gin
+// como dependência de quem usa só o net/http ou o chi.
+package ginratelimit
+
+import (
+	"net/http"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware aplica o limiter às requisições do gin, com as mesmas respostas
+// de ratelimit.Limiter.Handler. Requisições negadas interrompem a cadeia de
+// handlers.
+//
+//	router.Use(ginratelimit.Middleware(limiter))
+func Middleware(l *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		passed := false
+		l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+
+		if !passed {
+			c.Abort()
+		}
+	}
+}
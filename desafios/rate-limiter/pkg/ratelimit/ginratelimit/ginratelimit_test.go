@@ -0,0 +1,52 @@
+package ginratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := ratelimit.NewMemoryStorage()
+	defer store.Close()
+
+	l, err := ratelimit.New(store,
+		ratelimit.WithPrefix("auction"),
+		ratelimit.WithLimit(ratelimit.Limit{Requests: 2, Window: time.Minute}),
+	)
+	require.NoError(t, err)
+
+	handled := 0
+	router := gin.New()
+	router.Use(Middleware(l))
+	router.POST("/bid", func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/bid", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	serve()
+
+	w = serve()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, 2, handled, "Denied requests don't reach the handler")
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+)
+
+// Handler limita as requisições para next, com os mesmos headers e respostas
+// do servidor do rate limiter: 429 acima do limite e 503 quando o storage
+// falha com WithFailClosed. Serve como middleware do net/http e do chi:
+//
+//	http.Handle("/", limiter.Handler(mux))
+//	router.Use(limiter.Handler)
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := l.key(r)
+		if err != nil {
+			l.onKeyError(w, r, err)
+			return
+		}
+
+		// a falha do storage já está na decisão, que segue WithFailClosed
+		decision, _ := l.Allow(r.Context(), key)
+		if decision.Degraded {
+			if !decision.Allowed {
+				writeMessage(w, http.StatusServiceUnavailable, middleware.MessageUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d := limiter.Decision{
+			Allowed:      decision.Allowed,
+			Policy:       l.policy(),
+			Limit:        decision.Limit,
+			Remaining:    decision.Remaining,
+			Window:       l.limit.Window,
+			ResetAt:      decision.ResetAt,
+			RetryAfter:   decision.RetryAfter,
+			BlockedUntil: decision.BlockedUntil,
+		}
+		middleware.SetRateLimitHeaders(w.Header(), d, time.Now())
+
+		if !decision.Allowed {
+			middleware.WriteTooManyRequests(w, middleware.MessageRateLimitExceeded, d)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// policy é o nome da política nos headers RateLimit e RateLimit-Policy.
+func (l *Limiter) policy() string {
+	if l.prefix != "" {
+		return l.prefix
+	}
+	return "default"
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	writeMessage(w, http.StatusUnauthorized, "missing rate limit key")
+}
+
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(`{"message":"` + message + `"}`))
+}
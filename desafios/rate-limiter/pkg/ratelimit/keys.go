@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/apikey"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/middleware"
+)
+
+// ErrNoKey indica que a requisição não tem o valor usado como chave.
+var ErrNoKey = errors.New("rate limit key not found")
+
+// KeyFunc extrai da requisição a chave cujo limite ela consome. Qualquer
+// função com essa assinatura pode ser usada para chaves próprias, como o ID do
+// usuário guardado no contexto por um middleware de autenticação.
+type KeyFunc func(r *http.Request) (string, error)
+
+// ByIP usa o IP do cliente. Os headers Forwarded, X-Forwarded-For e X-Real-IP
+// só são considerados quando a conexão vem de um dos trustedProxies.
+func ByIP(trustedProxies ...netip.Prefix) KeyFunc {
+	cfg := &config.Config{TrustedProxies: trustedProxies}
+	return func(r *http.Request) (string, error) {
+		return "ip:" + middleware.ClientIP(r.RemoteAddr, r.Header, cfg), nil
+	}
+}
+
+// ByHeader usa o valor do header name, como uma chave de API. O valor é
+// guardado pelo hash, para que as chaves não fiquem no storage.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return "", fmt.Errorf("%w: header %s is empty", ErrNoKey, name)
+		}
+		return "header:" + strings.ToLower(name) + ":" + apikey.HashID(apikey.Hash(value)), nil
+	}
+}
+
+// ByJWTClaim usa um claim do JWT enviado em "Authorization: Bearer". A
+// assinatura do token NÃO é verificada: use depois do middleware que a
+// verifica, senão o cliente pode trocar o claim para escapar do limite.
+func ByJWTClaim(claim string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			return "", fmt.Errorf("%w: bearer token is missing", ErrNoKey)
+		}
+
+		parts := strings.Split(strings.TrimSpace(token), ".")
+		if len(parts) != 3 {
+			return "", fmt.Errorf("%w: malformed bearer token", ErrNoKey)
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return "", fmt.Errorf("%w: malformed bearer token", ErrNoKey)
+		}
+
+		var claims map[string]any
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return "", fmt.Errorf("%w: malformed bearer token", ErrNoKey)
+		}
+
+		var value string
+		switch v := claims[claim].(type) {
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if value == "" {
+			return "", fmt.Errorf("%w: claim %s is missing", ErrNoKey, claim)
+		}
+		return "jwt:" + claim + ":" + value, nil
+	}
+}
+
+// FirstOf usa a primeira chave que puder ser extraída, como a chave de API e,
+// sem ela, o IP:
+//
+//	ratelimit.FirstOf(ratelimit.ByHeader("API_KEY"), ratelimit.ByIP())
+func FirstOf(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		err := ErrNoKey
+		for _, key := range keys {
+			var value string
+			if value, err = key(r); err == nil {
+				return value, nil
+			}
+		}
+		return "", err
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	key, err := ByIP()(req)
+	require.NoError(t, err)
+	assert.Equal(t, "ip:10.0.0.1", key, "Headers from untrusted peers are ignored")
+
+	key, err = ByIP(netip.MustParsePrefix("10.0.0.0/8"))(req)
+	require.NoError(t, err)
+	assert.Equal(t, "ip:203.0.113.7", key)
+}
+
+func TestByHeader(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := ByHeader("API_KEY")(req)
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	req.Header.Set("API_KEY", "abc123")
+	key, err := ByHeader("API_KEY")(req)
+	require.NoError(t, err)
+	assert.Equal(t, "header:api_key:6ca13d52ca70c883", key, "The key is stored by its hash")
+}
+
+func TestByJWTClaim(t *testing.T) {
+	token := func(payload string) string {
+		return "Bearer eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		key           string
+	}{
+		{"string claim", token(`{"sub":"user-1"}`), "jwt:sub:user-1"},
+		{"numeric claim", token(`{"sub":42}`), "jwt:sub:42"},
+		{"missing claim", token(`{"name":"user"}`), ""},
+		{"not a jwt", "Bearer abc", ""},
+		{"invalid payload", "Bearer a.%%%.c", ""},
+		{"no bearer", "Basic dXNlcjpwYXNz", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", tt.authorization)
+
+		key, err := ByJWTClaim("sub")(req)
+		if tt.key == "" {
+			assert.ErrorIs(t, err, ErrNoKey, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.key, key, tt.name)
+	}
+}
+
+func TestFirstOf(t *testing.T) {
+	key := FirstOf(ByHeader("API_KEY"), ByIP())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	value, err := key(req)
+	require.NoError(t, err)
+	assert.Equal(t, "ip:10.0.0.1", value)
+
+	req.Header.Set("API_KEY", "abc123")
+	value, err = key(req)
+	require.NoError(t, err)
+	assert.Equal(t, "header:api_key:6ca13d52ca70c883", value)
+
+	_, err = FirstOf(ByHeader("API_KEY"))(httptest.NewRequest("GET", "/", nil))
+	assert.ErrorIs(t, err, ErrNoKey)
+	_, err = FirstOf()(httptest.NewRequest("GET", "/", nil))
+	assert.ErrorIs(t, err, ErrNoKey)
+}
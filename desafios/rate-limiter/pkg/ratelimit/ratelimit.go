@@ -0,0 +1,276 @@
+// Package ratelimit expõe o rate limiter para outros serviços: os mesmos
+// algoritmos e storages do servidor, configurados com opções e aplicados por
+// uma chave extraída da requisição.
+//
+//	store := ratelimit.NewMemoryStorage()
+//	limiter, err := ratelimit.New(store,
+//		ratelimit.WithLimit(ratelimit.Limit{Requests: 100, Window: time.Minute}),
+//		ratelimit.WithKeyFunc(ratelimit.ByHeader("API_KEY")),
+//	)
+//	router.Use(limiter.Handler)
+//
+// Handler segue a assinatura de middleware do net/http e do chi. Para o gin,
+// use o pacote ginratelimit.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/config"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/limiter"
+	"github.com/diogokimisima/goexpert/desafios/rate-limiter/internal/storage"
+)
+
+// Storage guarda os contadores e aplica o limite de cada chave em uma única
+// operação atômica. Use NewMemoryStorage para uma única instância ou
+// NewRedisStorage para compartilhar os limites entre instâncias; outros
+// backends implementam a interface diretamente.
+type Storage interface {
+	// Allow consome uma requisição do limite da chave com o algoritmo de
+	// limit, já validado por New.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	// Reset apaga os contadores e o bloqueio da chave.
+	Reset(ctx context.Context, key string) error
+	Close() error
+}
+
+// Result é a resposta do Storage a Allow. RetryAfter é o tempo até a próxima
+// requisição ser aceita e ResetAfter até o limite estar completo de novo;
+// com Blocked, ambos são o tempo restante do bloqueio.
+type Result struct {
+	Allowed    bool
+	Blocked    bool
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// NewMemoryStorage cria um storage em memória, que deve ser fechado com Close.
+func NewMemoryStorage() Storage {
+	return newStorage(storage.NewMemoryStorage())
+}
+
+// NewRedisStorage conecta ao Redis em host:port.
+func NewRedisStorage(host, port, password string, db int) (Storage, error) {
+	store, err := storage.NewRedisStorage(host, port, password, db)
+	if err != nil {
+		return nil, err
+	}
+	return newStorage(store), nil
+}
+
+// backend adapta os storages do servidor, com os seus algoritmos, a Storage.
+type backend struct {
+	store      storage.Storage
+	algorithms map[Algorithm]limiter.Algorithm
+}
+
+func newStorage(store storage.Storage) *backend {
+	b := &backend{store: store, algorithms: make(map[Algorithm]limiter.Algorithm)}
+	for _, name := range algorithms {
+		b.algorithms[name], _ = limiter.NewAlgorithm(store, config.Algorithm(name))
+	}
+	return b
+}
+
+func (b *backend) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	alg, found := b.algorithms[limit.algorithm()]
+	if !found {
+		return Result{}, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+
+	result, err := alg.Allow(ctx, key, storage.Limit{
+		Requests:      int64(limit.Requests),
+		Window:        limit.Window,
+		BlockDuration: limit.BlockDuration,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    result.Allowed,
+		Blocked:    result.Blocked,
+		Remaining:  result.Remaining,
+		RetryAfter: result.RetryAfter,
+		ResetAfter: result.ResetAfter,
+	}, nil
+}
+
+func (b *backend) Reset(ctx context.Context, key string) error {
+	return b.store.Reset(ctx, key)
+}
+
+func (b *backend) Close() error {
+	return b.store.Close()
+}
+
+// Algorithm é o algoritmo usado para contar as requisições de cada chave.
+type Algorithm string
+
+const (
+	FixedWindow   Algorithm = "fixed_window"
+	SlidingLog    Algorithm = "sliding_log"
+	SlidingWindow Algorithm = "sliding_window"
+	TokenBucket   Algorithm = "token_bucket"
+	GCRA          Algorithm = "gcra"
+)
+
+var algorithms = []Algorithm{FixedWindow, SlidingLog, SlidingWindow, TokenBucket, GCRA}
+
+// Limit permite Requests requisições por Window para cada chave. Com
+// BlockDuration positivo, a chave que excede o limite fica bloqueada por esse
+// tempo. Sem Algorithm, é usado FixedWindow.
+type Limit struct {
+	Requests      int
+	Window        time.Duration
+	BlockDuration time.Duration
+	Algorithm     Algorithm
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return FixedWindow
+	}
+	return l.Algorithm
+}
+
+// Decision é o resultado de Allow para uma chave. Degraded indica que o
+// storage falhou: a requisição é negada com WithFailClosed e permitida caso
+// contrário. BlockedUntil só é preenchido quando a chave está bloqueada.
+type Decision struct {
+	Allowed      bool
+	Degraded     bool
+	Key          string
+	Limit        int
+	Remaining    int64
+	ResetAt      time.Time
+	RetryAfter   time.Duration
+	BlockedUntil time.Time
+}
+
+// Limiter aplica um Limit às chaves extraídas das requisições.
+type Limiter struct {
+	storage    Storage
+	limit      Limit
+	key        KeyFunc
+	prefix     string
+	failClosed bool
+	onKeyError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Option configura o Limiter criado por New.
+type Option func(*Limiter)
+
+// WithLimit define o limite de cada chave. O padrão é 10 requisições por
+// segundo.
+func WithLimit(limit Limit) Option {
+	return func(l *Limiter) {
+		l.limit = limit
+	}
+}
+
+// WithKeyFunc define como a chave é extraída da requisição. O padrão é
+// ByIP(), sem proxies confiáveis.
+func WithKeyFunc(key KeyFunc) Option {
+	return func(l *Limiter) {
+		l.key = key
+	}
+}
+
+// WithPrefix separa os contadores do serviço quando mais de um usa o mesmo
+// storage, como o mesmo Redis.
+func WithPrefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+// WithFailClosed nega as requisições, com 503, quando o storage falha. Por
+// padrão elas são permitidas.
+func WithFailClosed() Option {
+	return func(l *Limiter) {
+		l.failClosed = true
+	}
+}
+
+// WithKeyErrorHandler responde as requisições cuja chave não pôde ser extraída,
+// como as sem o header de ByHeader. Por padrão elas recebem 401.
+func WithKeyErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(l *Limiter) {
+		l.onKeyError = handler
+	}
+}
+
+// New cria um Limiter sobre o storage. O storage continua sendo do chamador,
+// que deve fechá-lo.
+func New(store Storage, opts ...Option) (*Limiter, error) {
+	if store == nil {
+		return nil, errors.New("rate limit storage is nil")
+	}
+
+	l := &Limiter{
+		storage:    store,
+		limit:      Limit{Requests: 10, Window: time.Second},
+		key:        ByIP(),
+		onKeyError: unauthorized,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.limit.Requests <= 0 || l.limit.Window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d per %s: requests and window must be positive", l.limit.Requests, l.limit.Window)
+	}
+	if l.limit.BlockDuration < 0 {
+		return nil, fmt.Errorf("invalid block duration %s: must not be negative", l.limit.BlockDuration)
+	}
+	if l.key == nil {
+		return nil, errors.New("rate limit key func is nil")
+	}
+
+	if !slices.Contains(algorithms, l.limit.algorithm()) {
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", l.limit.Algorithm)
+	}
+	return l, nil
+}
+
+// Allow consome uma requisição do limite da chave. Quando o storage falha, o
+// erro é retornado junto com uma decisão Degraded, que segue WithFailClosed, e
+// o chamador só precisa registrá-lo.
+func (l *Limiter) Allow(ctx context.Context, key string) (Decision, error) {
+	if l.prefix != "" {
+		key = l.prefix + ":" + key
+	}
+
+	result, err := l.storage.Allow(ctx, key, l.limit)
+	if err != nil {
+		return Decision{Allowed: !l.failClosed, Degraded: true, Key: key}, fmt.Errorf("error checking limit: %w", err)
+	}
+
+	now := time.Now()
+	decision := Decision{
+		Allowed:    result.Allowed,
+		Key:        key,
+		Limit:      l.limit.Requests,
+		Remaining:  result.Remaining,
+		ResetAt:    now.Add(result.ResetAfter),
+		RetryAfter: result.RetryAfter,
+	}
+	if result.Blocked {
+		decision.BlockedUntil = now.Add(result.RetryAfter)
+	}
+	return decision, nil
+}
+
+// Reset apaga os contadores e o bloqueio da chave.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	if l.prefix != "" {
+		key = l.prefix + ":" + key
+	}
+	return l.storage.Reset(ctx, key)
+}
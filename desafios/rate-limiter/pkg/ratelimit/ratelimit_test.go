@@ -0,0 +1,221 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Validates(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	_, err := New(nil)
+	assert.Error(t, err)
+	_, err = New(store, WithLimit(Limit{Requests: 0, Window: time.Second}))
+	assert.Error(t, err)
+	_, err = New(store, WithLimit(Limit{Requests: 1, Window: time.Second, BlockDuration: -time.Second}))
+	assert.Error(t, err)
+	_, err = New(store, WithLimit(Limit{Requests: 1, Window: time.Second, Algorithm: "leaky"}))
+	assert.Error(t, err)
+	_, err = New(store, WithKeyFunc(nil))
+	assert.Error(t, err)
+
+	l, err := New(store)
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 10, Window: time.Second}, l.limit)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	for _, alg := range []Algorithm{FixedWindow, SlidingLog, SlidingWindow, TokenBucket, GCRA} {
+		l, err := New(store, WithPrefix(string(alg)), WithLimit(Limit{Requests: 2, Window: time.Minute, BlockDuration: time.Minute, Algorithm: alg}))
+		require.NoError(t, err)
+
+		for i := 1; i <= 2; i++ {
+			decision, err := l.Allow(context.Background(), "user:1")
+			require.NoError(t, err)
+			assert.True(t, decision.Allowed, "%s: request %d", alg, i)
+			assert.Equal(t, string(alg)+":user:1", decision.Key)
+		}
+
+		decision, err := l.Allow(context.Background(), "user:1")
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed, alg)
+		assert.False(t, decision.BlockedUntil.IsZero(), alg)
+
+		// outra chave tem o próprio limite
+		decision, err = l.Allow(context.Background(), "user:2")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, alg)
+
+		require.NoError(t, l.Reset(context.Background(), "user:1"))
+		decision, err = l.Allow(context.Background(), "user:1")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed, "%s: reset clears the block", alg)
+	}
+}
+
+func TestLimiter_PrefixesIsolateServices(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	limit := WithLimit(Limit{Requests: 1, Window: time.Minute})
+	products, err := New(store, limit, WithPrefix("products"))
+	require.NoError(t, err)
+	auction, err := New(store, limit, WithPrefix("auction"))
+	require.NoError(t, err)
+
+	decision, _ := products.Allow(context.Background(), "ip:10.0.0.1")
+	assert.True(t, decision.Allowed)
+	decision, _ = auction.Allow(context.Background(), "ip:10.0.0.1")
+	assert.True(t, decision.Allowed, "Services sharing the storage have separate counters")
+	decision, _ = products.Allow(context.Background(), "ip:10.0.0.1")
+	assert.False(t, decision.Allowed)
+}
+
+func TestLimiter_Handler(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	l, err := New(store, WithPrefix("products"), WithLimit(Limit{Requests: 2, Window: time.Minute, BlockDuration: time.Minute}))
+	require.NoError(t, err)
+
+	handler := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/products", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, `"products";q=2;w=60`, w.Header().Get("RateLimit-Policy"))
+
+	serve()
+	w = serve()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "blocked_until")
+}
+
+func TestLimiter_HandlerKeyErrors(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	l, err := New(store, WithKeyFunc(ByHeader("API_KEY")))
+	require.NoError(t, err)
+	handler := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	l, err = New(store, WithKeyFunc(ByHeader("API_KEY")), WithKeyErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		assert.ErrorIs(t, err, ErrNoKey)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	require.NoError(t, err)
+	handler = l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+var errUnavailable = errors.New("storage unavailable")
+
+// unavailableStorage é um Storage próprio, fora dos storages do pacote, que
+// sempre falha.
+type unavailableStorage struct{}
+
+func (unavailableStorage) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errUnavailable
+}
+
+func (unavailableStorage) Reset(ctx context.Context, key string) error {
+	return errUnavailable
+}
+
+func (unavailableStorage) Close() error {
+	return nil
+}
+
+func TestLimiter_StorageFailures(t *testing.T) {
+	store := unavailableStorage{}
+
+	tests := []struct {
+		opts []Option
+		code int
+	}{
+		{nil, http.StatusOK},
+		{[]Option{WithFailClosed()}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		l, err := New(store, tt.opts...)
+		require.NoError(t, err)
+
+		decision, err := l.Allow(context.Background(), "ip:192.168.1.1")
+		assert.True(t, errors.Is(err, errUnavailable))
+		assert.True(t, decision.Degraded)
+
+		handler := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, tt.code, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"), "Degraded responses have no counters")
+	}
+}
+
+func TestLimiter_Chi(t *testing.T) {
+	store := NewMemoryStorage()
+	defer store.Close()
+
+	l, err := New(store, WithLimit(Limit{Requests: 1, Window: time.Minute}))
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(l.Handler)
+		r.Get("/products", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/products"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("/products"))
+	assert.Equal(t, http.StatusOK, serve("/health"), "Routes outside the group are not limited")
+}
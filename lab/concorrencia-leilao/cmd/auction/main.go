@@ -2,7 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/diogokimisima/fullcycle-auction/configuration/database/mongodb"
 	"github.com/diogokimisima/fullcycle-auction/internal/infra/api/web/controller/auction_controller"
@@ -11,6 +17,7 @@ import (
 	"github.com/diogokimisima/fullcycle-auction/internal/infra/database/auction"
 	"github.com/diogokimisima/fullcycle-auction/internal/infra/database/bid"
 	"github.com/diogokimisima/fullcycle-auction/internal/infra/database/user"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
 	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_usecase"
 	"github.com/diogokimisima/fullcycle-auction/internal/usecase/bid_usecase"
 	"github.com/diogokimisima/fullcycle-auction/internal/usecase/user_usecase"
@@ -20,7 +27,8 @@ import (
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if err := godotenv.Load("cmd/auction/.env"); err != nil {
 		log.Fatal("Error loading .env file")
@@ -35,31 +43,93 @@ func main() {
 
 	router := gin.Default()
 
-	userController, bidController, auctionController := initDependencies(databaseConnection)
+	userController, bidController, auctionController, bidUseCase, auctionScheduler, auctionEvents :=
+		initDependencies(ctx, databaseConnection)
 
 	router.GET("/auctions", auctionController.FindAuctions)
 	router.GET("/auctions/:auctionId", auctionController.FindAuctionById)
+	router.GET("/auctions/:auctionId/events", auctionController.StreamAuctionEvents)
+	router.GET("/auctions/:auctionId/ws", auctionController.AuctionEventsWebSocket)
 	router.POST("/auctions", auctionController.CreateAuction)
 	router.GET("/auction/winner/:auctionId", auctionController.FindWinningBidByAuctionId)
 	router.POST("/bid", bidController.CreateBid)
 	router.GET("/bid/:auctionId", bidController.FindBidByAuctionId)
 	router.GET("/users/:userId", userController.FindUserById)
 
-	router.Run(":8080")
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), getShutdownTimeout())
+	defer cancel()
+
+	// os lances novos são recusados enquanto os da fila são salvos, e as
+	// requisições que esperam o resultado do batch terminam antes do servidor
+	if err := bidUseCase.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to flush pending bids: %v", err)
+	}
+	// as conexões de eventos ficariam abertas até o fim do leilão
+	auctionEvents.Shutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	if err := auctionScheduler.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to stop auction scheduler: %v", err)
+	}
+
+	// o prazo do shutdown pode ter acabado no último batch
+	disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), disconnectTimeout)
+	defer cancelDisconnect()
+	if err := databaseConnection.Client().Disconnect(disconnectCtx); err != nil {
+		log.Printf("Failed to disconnect from MongoDB: %v", err)
+	}
 }
 
-func initDependencies(database *mongo.Database) (
+func initDependencies(ctx context.Context, database *mongo.Database) (
 	userController *user_controller.UserController,
 	bidController *bid_controller.BidController,
 	auctionController *auction_controller.AuctionController,
+	bidUseCase bid_usecase.BidUseCaseInterface,
+	auctionScheduler *auction_usecase.AuctionScheduler,
+	auctionEvents *auction_event_usecase.Hub,
 ) {
 	auctionRepository := auction.NewAuctionRepository(database)
 	bidRepository := bid.NewBidRepository(database, auctionRepository)
 	userRepository := user.NewUserRepository(database)
 
+	auctionEvents = auction_event_usecase.NewHub()
+	auctionEvents.Start(ctx)
+
+	bidUseCase = bid_usecase.NewBidUseCase(bidRepository, auctionRepository, auctionEvents)
+
+	auctionScheduler = auction_usecase.NewAuctionScheduler(auctionRepository, bidRepository, auctionEvents, bidUseCase)
+	auctionScheduler.Start(ctx)
+
 	userController = user_controller.NewUserController(user_usecase.NewUserUseCase(userRepository))
-	auctionController = auction_controller.NewAuctionController(auction_usecase.NewAuctionUseCase(auctionRepository, bidRepository))
-	bidController = bid_controller.NewBidController(bid_usecase.NewBidUseCase(bidRepository))
+	auctionController = auction_controller.NewAuctionController(
+		auction_usecase.NewAuctionUseCase(auctionRepository, bidRepository, auctionEvents))
+	bidController = bid_controller.NewBidController(bidUseCase)
 
 	return
 }
+
+// disconnectTimeout é o prazo para fechar a conexão com o MongoDB, contado
+// depois do shutdown, que pode ter usado todo o SHUTDOWN_TIMEOUT.
+const disconnectTimeout = 5 * time.Second
+
+func getShutdownTimeout() time.Duration {
+	shutdownTimeout := os.Getenv("SHUTDOWN_TIMEOUT")
+	duration, err := time.ParseDuration(shutdownTimeout)
+	if err != nil || duration <= 0 {
+		return 20 * time.Second
+	}
+
+	return duration
+}
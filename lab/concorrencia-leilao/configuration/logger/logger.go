@@ -26,7 +26,7 @@ func init() {
 	log, _ = logConfiguration.Build()
 }
 
-func info(msg string, tags ...zap.Field) {
+func Info(msg string, tags ...zap.Field) {
 	log.Info(msg, tags...)
 	log.Sync()
 }
@@ -24,6 +24,8 @@ func ConverterError(internalError *internal_error.InternalError) *RestErr {
 		return NewBadRequestError(internalError.Error())
 	case "not_found":
 		return NewNotFoundError(internalError.Error())
+	case "service_unavailable":
+		return NewServiceUnavailableError(internalError.Error())
 	default:
 		return NewInternalServerError(internalError.Error())
 	}
@@ -47,6 +49,15 @@ func NewInternalServerError(message string) *RestErr {
 	}
 }
 
+func NewServiceUnavailableError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "service_unavailable",
+		Code:    503,
+		Causes:  nil,
+	}
+}
+
 func NewNotFoundError(message string) *RestErr {
 	return &RestErr{
 		Message: message,
@@ -2,6 +2,8 @@ package auction_entity
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
@@ -10,15 +12,19 @@ import (
 
 func CreateAuction(
 	productName, category, description string,
-	condition ProductCondition) (*Auction, *internal_error.InternalError) {
+	condition ProductCondition,
+	startingPrice, reservePrice, minIncrement float64) (*Auction, *internal_error.InternalError) {
 	auction := &Auction{
-		Id:          uuid.New().String(),
-		ProductName: productName,
-		Category:    category,
-		Description: description,
-		Condition:   condition,
-		Status:      Active,
-		TimeStamp:   time.Now(),
+		Id:            uuid.New().String(),
+		ProductName:   productName,
+		Category:      category,
+		Description:   description,
+		Condition:     condition,
+		Status:        Active,
+		TimeStamp:     time.Now(),
+		StartingPrice: startingPrice,
+		ReservePrice:  reservePrice,
+		MinIncrement:  minIncrement,
 	}
 
 	if err := auction.Validate(); err != nil {
@@ -37,9 +43,58 @@ func (au *Auction) Validate() *internal_error.InternalError {
 		return internal_error.NewBadRequestError("invalid auction object")
 	}
 
+	if au.StartingPrice < 0 || au.ReservePrice < 0 || au.MinIncrement < 0 {
+		return internal_error.NewBadRequestError("prices and minimum increment must not be negative")
+	}
+
+	if au.ReservePrice > 0 && au.ReservePrice < au.StartingPrice {
+		return internal_error.NewBadRequestError("reserve price must not be lower than the starting price")
+	}
+
+	return nil
+}
+
+// ValidateBid verifica se amount pode ser aceito dado o maior lance atual do
+// leilão (0 quando ainda não há lances): o primeiro lance deve alcançar o
+// preço inicial e os seguintes devem superar o maior lance em pelo menos
+// MinIncrement. Os valores são comparados em centavos.
+func (au *Auction) ValidateBid(amount, highestBid float64) *internal_error.InternalError {
+	if highestBid <= 0 {
+		if Cents(amount) < Cents(au.StartingPrice) {
+			return internal_error.NewBadRequestError(fmt.Sprintf(
+				"bid must be at least the starting price of %.2f", au.StartingPrice))
+		}
+		return nil
+	}
+
+	minimum := highestBid + au.MinIncrement
+	if Cents(amount) <= Cents(highestBid) || Cents(amount) < Cents(minimum) {
+		return internal_error.NewBadRequestError(fmt.Sprintf(
+			"bid must be at least %.2f to beat the current highest bid of %.2f", minimum, highestBid))
+	}
+
 	return nil
 }
 
+// ReserveMet indica se o lance vencedor alcança o preço de reserva. Leilões
+// sem reserva só precisam de um lance.
+func (au *Auction) ReserveMet(highestBid float64) bool {
+	return highestBid > 0 && Cents(highestBid) >= Cents(au.ReservePrice)
+}
+
+// OutbidCents retorna, em centavos, o maior lance atual que amount supera pelas
+// regras de ValidateBid. É o limite usado para validar o lance contra o maior
+// lance salvo no banco.
+func (au *Auction) OutbidCents(amount float64) int64 {
+	return Cents(amount) - max(Cents(au.MinIncrement), 1)
+}
+
+// Cents converte um valor para centavos, a unidade em que os lances são
+// comparados.
+func Cents(value float64) int64 {
+	return int64(math.Round(value * 100))
+}
+
 type Auction struct {
 	Id          string
 	ProductName string
@@ -48,6 +103,17 @@ type Auction struct {
 	Condition   ProductCondition
 	Status      AuctionStatus
 	TimeStamp   time.Time
+	EndTime     time.Time
+
+	StartingPrice float64
+	ReservePrice  float64
+	MinIncrement  float64
+}
+
+// IsExpired indica se o leilão já terminou em now, mesmo que o scheduler ainda
+// não tenha marcado o leilão como Completed.
+func (au *Auction) IsExpired(now time.Time) bool {
+	return !now.Before(au.EndTime)
 }
 
 type ProductCondition int
@@ -68,4 +134,5 @@ type AuctionRepositoryInterface interface {
 	CreateAuction(ctx context.Context, auctionInputDTO *Auction) *internal_error.InternalError
 	FindAuctionById(ctx context.Context, id string) (*Auction, *internal_error.InternalError)
 	FindAuctions(ctx context.Context, status AuctionStatus, category, productName string) ([]Auction, *internal_error.InternalError)
+	CloseExpiredAuctions(ctx context.Context, now time.Time) (int64, *internal_error.InternalError)
 }
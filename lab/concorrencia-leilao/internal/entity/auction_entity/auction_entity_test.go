@@ -0,0 +1,71 @@
+package auction_entity
+
+import "testing"
+
+func TestCreateAuction_Prices(t *testing.T) {
+	if _, err := CreateAuction("iPhone", "Smartphones", "iPhone 15 Pro Max 256GB", New, 1000, 1500, 50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string][3]float64{
+		"negative starting price": {-1, 0, 0},
+		"negative reserve price":  {0, -1, 0},
+		"negative min increment":  {0, 0, -1},
+		"reserve below the start": {1000, 500, 0},
+	}
+	for name, prices := range tests {
+		if _, err := CreateAuction("iPhone", "Smartphones", "iPhone 15 Pro Max 256GB", New, prices[0], prices[1], prices[2]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAuction_ValidateBid(t *testing.T) {
+	auction := &Auction{StartingPrice: 1000, MinIncrement: 50}
+
+	tests := []struct {
+		amount, highestBid float64
+		accepted           bool
+	}{
+		{999.99, 0, false},
+		{1000, 0, true},
+		{1049.99, 1000, false},
+		{1050, 1000, true},
+		{1.3, 1.2, false},
+	}
+	for _, tt := range tests {
+		err := auction.ValidateBid(tt.amount, tt.highestBid)
+		if accepted := err == nil; accepted != tt.accepted {
+			t.Errorf("bid of %.2f over %.2f: expected accepted=%v, got error %v", tt.amount, tt.highestBid, tt.accepted, err)
+		}
+	}
+
+	// sem incremento mínimo o lance ainda precisa superar o maior lance, e os
+	// centavos não sofrem com o arredondamento de float64
+	auction = &Auction{MinIncrement: 0.1}
+	if err := auction.ValidateBid(1.2, 1.1); err != nil {
+		t.Errorf("bid of 1.20 over 1.10 with increment 0.10 should be accepted: %v", err)
+	}
+	auction.MinIncrement = 0
+	if err := auction.ValidateBid(1.1, 1.1); err == nil {
+		t.Error("bid equal to the highest bid should be rejected")
+	}
+}
+
+func TestAuction_ReserveMet(t *testing.T) {
+	auction := &Auction{ReservePrice: 1500}
+	if auction.ReserveMet(1499.99) {
+		t.Error("reserve should not be met below the reserve price")
+	}
+	if !auction.ReserveMet(1500) {
+		t.Error("reserve should be met at the reserve price")
+	}
+
+	auction = &Auction{}
+	if auction.ReserveMet(0) {
+		t.Error("reserve should not be met without bids")
+	}
+	if !auction.ReserveMet(1) {
+		t.Error("auctions without reserve only need a bid")
+	}
+}
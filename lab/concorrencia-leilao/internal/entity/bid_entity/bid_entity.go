@@ -44,14 +44,27 @@ func (b *Bid) Validate() *internal_error.InternalError {
 	return nil
 }
 
+// BidOutbidMessage é a mensagem do erro bad_request de CreateBid para o lance
+// que não supera o maior lance salvo do leilão.
+const BidOutbidMessage = "bid does not beat the current highest bid"
+
 type BidEntityRepository interface {
+	// CreateBid retorna o resultado de cada lance, na mesma ordem de
+	// bidEntities; nil indica que o lance foi salvo. Um lance que não supera o
+	// maior lance já salvo, inclusive por outra instância, é recusado com
+	// BidOutbidMessage.
 	CreateBid(
 		ctx context.Context,
-		bidEntities []Bid) *internal_error.InternalError
+		bidEntities []Bid) []*internal_error.InternalError
 
 	FindBidByAuctionId(
 		ctx context.Context, auctionId string) ([]Bid, *internal_error.InternalError)
 
 	FindWinnigBidByAuctionId(
 		ctx context.Context, auctionId string) (*Bid, *internal_error.InternalError)
+
+	// FindHighestBidAmount retorna o maior lance salvo do leilão, o valor que
+	// CreateBid exige que o próximo lance supere, ou 0 sem lances.
+	FindHighestBidAmount(
+		ctx context.Context, auctionId string) (float64, *internal_error.InternalError)
 }
@@ -0,0 +1,176 @@
+package auction_controller
+
+import (
+	"io"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/configuration/rest_err"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	messageSlowConsumer   = "slow consumer"
+	messageShuttingDown   = "server is shutting down"
+	webSocketWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// StreamAuctionEvents envia os eventos do leilão por Server-Sent Events até o
+// leilão fechar ou o cliente desconectar.
+func (u *AuctionController) StreamAuctionEvents(c *gin.Context) {
+	events, subscription, ok := u.subscribeAuctionEvents(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	for _, event := range events {
+		c.SSEvent(event.Type, event)
+	}
+	c.Writer.Flush()
+
+	if subscription == nil {
+		return
+	}
+	defer subscription.Close()
+
+	closed := false
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if message := endMessage(subscription, closed); message != "" {
+					c.SSEvent("error", gin.H{"message": message})
+				}
+				return false
+			}
+			closed = event.Type == auction_event_usecase.EventClosed
+			c.SSEvent(event.Type, event)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// AuctionEventsWebSocket envia os eventos do leilão, em JSON, por WebSocket
+// até o leilão fechar ou o cliente desconectar.
+func (u *AuctionController) AuctionEventsWebSocket(c *gin.Context) {
+	events, subscription, ok := u.subscribeAuctionEvents(c)
+	if !ok {
+		return
+	}
+	if subscription != nil {
+		defer subscription.Close()
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade já respondeu com o erro
+		return
+	}
+	defer conn.Close()
+
+	// o cliente só recebe eventos; a leitura serve para detectar a desconexão
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range events {
+		if err := writeWebSocketEvent(conn, event); err != nil {
+			return
+		}
+	}
+
+	if subscription == nil {
+		closeWebSocket(conn, websocket.CloseNormalClosure, "")
+		return
+	}
+
+	closed := false
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				switch message := endMessage(subscription, closed); message {
+				case "":
+					closeWebSocket(conn, websocket.CloseNormalClosure, "")
+				case messageSlowConsumer:
+					closeWebSocket(conn, websocket.ClosePolicyViolation, message)
+				default:
+					closeWebSocket(conn, websocket.CloseGoingAway, message)
+				}
+				return
+			}
+
+			closed = event.Type == auction_event_usecase.EventClosed
+			if err := writeWebSocketEvent(conn, event); err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+func (u *AuctionController) subscribeAuctionEvents(c *gin.Context) (
+	[]auction_event_usecase.AuctionEvent, *auction_event_usecase.Subscription, bool) {
+	auctionId := c.Param("auctionId")
+
+	if err := uuid.Validate(auctionId); err != nil {
+		errRest := rest_err.NewBadRequestError("Invalid fields", rest_err.Causes{
+			Field:   "auctionId",
+			Message: "Invalid UUID value",
+		})
+
+		c.JSON(errRest.Code, errRest)
+		return nil, nil, false
+	}
+
+	events, subscription, err := u.auctionUseCase.SubscribeAuctionEvents(c.Request.Context(), auctionId)
+	if err != nil {
+		errRest := rest_err.ConverterError(err)
+		c.JSON(errRest.Code, errRest)
+		return nil, nil, false
+	}
+
+	return events, subscription, true
+}
+
+// endMessage explica o fim da inscrição que não terminou com o evento closed:
+// o cliente foi desconectado por ser lento ou o servidor está encerrando.
+func endMessage(subscription *auction_event_usecase.Subscription, closed bool) string {
+	switch {
+	case closed:
+		return ""
+	case subscription.Dropped():
+		return messageSlowConsumer
+	default:
+		return messageShuttingDown
+	}
+}
+
+func writeWebSocketEvent(conn *websocket.Conn, event auction_event_usecase.AuctionEvent) error {
+	conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return conn.WriteJSON(event)
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, message string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, message),
+		time.Now().Add(webSocketWriteTimeout))
+}
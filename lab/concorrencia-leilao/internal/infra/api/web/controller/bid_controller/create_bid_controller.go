@@ -1,7 +1,6 @@
 package bid_controller
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/diogokimisima/fullcycle-auction/configuration/rest_err"
@@ -30,7 +29,7 @@ func (u *BidController) CreateBid(c *gin.Context) {
 		return
 	}
 
-	err := u.bidUseCase.CreateBid(context.Background(), bidInputDTO)
+	result, err := u.bidUseCase.CreateBid(c.Request.Context(), bidInputDTO)
 	if err != nil {
 		restErr := rest_err.ConverterError(err)
 
@@ -38,5 +37,11 @@ func (u *BidController) CreateBid(c *gin.Context) {
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	// o batch ainda não terminou: o lance foi recebido, mas pode ser recusado
+	if result.Status == bid_usecase.BidPending {
+		c.JSON(http.StatusAccepted, result)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
 }
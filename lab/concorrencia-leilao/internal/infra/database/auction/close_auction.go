@@ -0,0 +1,38 @@
+package auction
+
+import (
+	"context"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CloseExpiredAuctions marca como Completed, em uma única operação, todos os
+// leilões ativos que terminaram até now e retorna quantos foram fechados.
+func (ar *AuctionRepository) CloseExpiredAuctions(
+	ctx context.Context,
+	now time.Time) (int64, *internal_error.InternalError) {
+	filter := bson.M{
+		"status": auction_entity.Active,
+		"$or": bson.A{
+			bson.M{"end_time": bson.M{"$lte": now.Unix()}},
+			bson.M{
+				"end_time":  bson.M{"$exists": false},
+				"timestamp": bson.M{"$lte": now.Add(-ar.auctionInterval).Unix()},
+			},
+		},
+	}
+	update := bson.M{"$set": bson.M{"status": auction_entity.Completed}}
+
+	result, err := ar.Collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		logger.Error("Error trying to close expired auctions", err)
+		return 0, internal_error.NewInternalServerError(
+			"error trying to close expired auctions: " + err.Error())
+	}
+
+	return result.ModifiedCount, nil
+}
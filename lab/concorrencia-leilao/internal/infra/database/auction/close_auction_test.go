@@ -0,0 +1,60 @@
+package auction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCloseExpiredAuctions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	now := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+
+	mt.Run("closes active auctions past their end time", func(mt *mtest.T) {
+		repository := &AuctionRepository{Collection: mt.Coll, auctionInterval: 10 * time.Minute}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		closed, err := repository.CloseExpiredAuctions(context.Background(), now)
+		if err != nil {
+			mt.Fatalf("unexpected error: %v", err)
+		}
+		if closed != 2 {
+			mt.Fatalf("expected 2 closed auctions, got %d", closed)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		filter := update.Lookup("q").Document()
+		if status := filter.Lookup("status").Int32(); status != 0 {
+			mt.Fatalf("expected only active auctions, got status %d", status)
+		}
+
+		conditions, _ := filter.Lookup("$or").Array().Values()
+		if len(conditions) != 2 {
+			mt.Fatalf("expected 2 conditions, got %d", len(conditions))
+		}
+		if endTime := conditions[0].Document().Lookup("end_time", "$lte").Int64(); endTime != now.Unix() {
+			mt.Fatalf("expected end_time <= %d, got %d", now.Unix(), endTime)
+		}
+		// leilões sem end_time vencem AUCTION_INTERVAL depois de criados
+		if timestamp := conditions[1].Document().Lookup("timestamp", "$lte").Int64(); timestamp != now.Add(-10*time.Minute).Unix() {
+			mt.Fatalf("expected timestamp <= %d, got %d", now.Add(-10*time.Minute).Unix(), timestamp)
+		}
+
+		if !update.Lookup("multi").Boolean() {
+			mt.Fatal("expected a single update for all expired auctions")
+		}
+	})
+
+	mt.Run("returns an error when the update fails", func(mt *mtest.T) {
+		repository := &AuctionRepository{Collection: mt.Coll, auctionInterval: 10 * time.Minute}
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "failed"}))
+
+		if _, err := repository.CloseExpiredAuctions(context.Background(), now); err == nil {
+			mt.Fatal("expected an error")
+		}
+	})
+}
@@ -5,10 +5,8 @@ import (
 	"os"
 	"time"
 
-	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -20,21 +18,35 @@ type AuctionEntityMongo struct {
 	Condition   auction_entity.ProductCondition `bson:"condition"`
 	Status      auction_entity.AuctionStatus    `bson:"status"`
 	TimeStamp   int64                           `bson:"timestamp"`
+	EndTime     int64                           `bson:"end_time,omitempty"`
+
+	StartingPrice float64 `bson:"starting_price"`
+	ReservePrice  float64 `bson:"reserve_price"`
+	MinIncrement  float64 `bson:"min_increment"`
 }
 
 type AuctionRepository struct {
 	Collection *mongo.Collection
+
+	auctionInterval time.Duration
 }
 
 func NewAuctionRepository(database *mongo.Database) *AuctionRepository {
 	return &AuctionRepository{
-		Collection: database.Collection("auctions"),
+		Collection:      database.Collection("auctions"),
+		auctionInterval: getAuctionInterval(),
 	}
 }
 
+// CreateAuction salva o leilão com o seu horário de término, a partir do qual
+// o AuctionScheduler o marca como Completed. Sem EndTime, o leilão termina
+// AUCTION_INTERVAL depois de criado.
 func (ar *AuctionRepository) CreateAuction(
 	ctx context.Context,
 	auction *auction_entity.Auction) *internal_error.InternalError {
+	if auction.EndTime.IsZero() {
+		auction.EndTime = auction.TimeStamp.Add(ar.auctionInterval)
+	}
 
 	auctionMongo := &AuctionEntityMongo{
 		Id:          auction.Id,
@@ -44,6 +56,11 @@ func (ar *AuctionRepository) CreateAuction(
 		Condition:   auction.Condition,
 		Status:      auction.Status,
 		TimeStamp:   auction.TimeStamp.Unix(),
+		EndTime:     auction.EndTime.Unix(),
+
+		StartingPrice: auction.StartingPrice,
+		ReservePrice:  auction.ReservePrice,
+		MinIncrement:  auction.MinIncrement,
 	}
 
 	_, err := ar.Collection.InsertOne(ctx, auctionMongo)
@@ -52,21 +69,18 @@ func (ar *AuctionRepository) CreateAuction(
 			"error trying to create auction: " + err.Error())
 	}
 
-	go func() {
-		time.Sleep(getAuctionInterval())
-		update := bson.M{"$set": bson.M{"status": auction_entity.Completed}}
-		filter := bson.M{"_id": auction.Id}
-
-		_, err := ar.Collection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			logger.Error("error trying to update auction status: ", err)
-			return
-		}
-	}()
-
 	return nil
 }
 
+// endTime considera que os leilões salvos antes de end_time existir terminam
+// AUCTION_INTERVAL depois de criados.
+func (ar *AuctionRepository) endTime(auctionMongo AuctionEntityMongo) time.Time {
+	if auctionMongo.EndTime == 0 {
+		return time.Unix(auctionMongo.TimeStamp, 0).Add(ar.auctionInterval)
+	}
+	return time.Unix(auctionMongo.EndTime, 0)
+}
+
 func getAuctionInterval() time.Duration {
 	auctionInverval := os.Getenv("AUCTION_INTERVAL")
 	duration, err := time.ParseDuration(auctionInverval)
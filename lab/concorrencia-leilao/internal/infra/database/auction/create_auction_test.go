@@ -0,0 +1,56 @@
+package auction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCreateAuction_PersistsEndTime(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("end time defaults to the auction interval", func(mt *mtest.T) {
+		repository := &AuctionRepository{Collection: mt.Coll, auctionInterval: 10 * time.Minute}
+		createdAt := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		auction := &auction_entity.Auction{Id: "auction", Status: auction_entity.Active, TimeStamp: createdAt}
+		if err := repository.CreateAuction(context.Background(), auction); err != nil {
+			mt.Fatalf("unexpected error: %v", err)
+		}
+		if !auction.EndTime.Equal(createdAt.Add(10 * time.Minute)) {
+			mt.Fatalf("expected end time %s, got %s", createdAt.Add(10*time.Minute), auction.EndTime)
+		}
+
+		document := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if endTime := document.Lookup("end_time").Int64(); endTime != createdAt.Add(10*time.Minute).Unix() {
+			mt.Fatalf("expected end_time %d, got %d", createdAt.Add(10*time.Minute).Unix(), endTime)
+		}
+	})
+}
+
+func TestFindAuctionById_LegacyEndTime(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("auctions saved without end_time end after the interval", func(mt *mtest.T) {
+		repository := &AuctionRepository{Collection: mt.Coll, auctionInterval: 10 * time.Minute}
+		createdAt := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.auctions", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "auction"},
+			{Key: "status", Value: auction_entity.Active},
+			{Key: "timestamp", Value: createdAt.Unix()},
+		}))
+
+		auction, err := repository.FindAuctionById(context.Background(), "auction")
+		if err != nil {
+			mt.Fatalf("unexpected error: %v", err)
+		}
+		if !auction.EndTime.Equal(createdAt.Add(10 * time.Minute)) {
+			mt.Fatalf("expected end time %s, got %s", createdAt.Add(10*time.Minute), auction.EndTime)
+		}
+	})
+}
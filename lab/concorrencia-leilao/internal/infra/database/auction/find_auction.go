@@ -24,15 +24,40 @@ func (ar *AuctionRepository) FindAuctionById(
 			"error trying to find auction by id: " + err.Error())
 	}
 
-	return &auction_entity.Auction{
-		Id:          auctionEntityMongo.Id,
-		ProductName: auctionEntityMongo.ProductName,
-		Category:    auctionEntityMongo.Category,
-		Description: auctionEntityMongo.Description,
-		Condition:   auctionEntityMongo.Condition,
-		Status:      auctionEntityMongo.Status,
-		TimeStamp:   time.Unix(auctionEntityMongo.TimeStamp, 0),
-	}, nil
+	auctionEntity := ar.toEntity(auctionEntityMongo)
+	return &auctionEntity, nil
+}
+
+// FindAuctionsByIds busca os leilões de ids em uma única consulta. Os ids sem
+// leilão ficam fora do map retornado.
+func (ar *AuctionRepository) FindAuctionsByIds(
+	ctx context.Context,
+	ids []string) (map[string]*auction_entity.Auction, *internal_error.InternalError) {
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+
+	cursor, err := ar.Collection.Find(ctx, filter)
+	if err != nil {
+		logger.Error("Error trying to find auctions by ids", err)
+		return nil, internal_error.NewInternalServerError(
+			"error trying to find auctions by ids: " + err.Error())
+	}
+
+	defer cursor.Close(ctx)
+
+	var auctionEntityMongo []AuctionEntityMongo
+	if err := cursor.All(ctx, &auctionEntityMongo); err != nil {
+		logger.Error("Error trying to decode auctions", err)
+		return nil, internal_error.NewInternalServerError(
+			"error trying to decode auctions: " + err.Error())
+	}
+
+	auctions := make(map[string]*auction_entity.Auction, len(auctionEntityMongo))
+	for _, auctionMongo := range auctionEntityMongo {
+		auctionEntity := ar.toEntity(auctionMongo)
+		auctions[auctionEntity.Id] = &auctionEntity
+	}
+
+	return auctions, nil
 }
 
 func (ar *AuctionRepository) FindAuctions(
@@ -74,16 +99,25 @@ func (ar *AuctionRepository) FindAuctions(
 
 	var auctionEntity []auction_entity.Auction
 	for _, auctionMongo := range auctionEntityMongo {
-		auctionEntity = append(auctionEntity, auction_entity.Auction{
-			Id:          auctionMongo.Id,
-			ProductName: auctionMongo.ProductName,
-			Category:    auctionMongo.Category,
-			Description: auctionMongo.Description,
-			Condition:   auctionMongo.Condition,
-			Status:      auctionMongo.Status,
-			TimeStamp:   time.Unix(auctionMongo.TimeStamp, 0),
-		})
+		auctionEntity = append(auctionEntity, ar.toEntity(auctionMongo))
 	}
 
 	return auctionEntity, nil
 }
+
+func (ar *AuctionRepository) toEntity(auctionMongo AuctionEntityMongo) auction_entity.Auction {
+	return auction_entity.Auction{
+		Id:          auctionMongo.Id,
+		ProductName: auctionMongo.ProductName,
+		Category:    auctionMongo.Category,
+		Description: auctionMongo.Description,
+		Condition:   auctionMongo.Condition,
+		Status:      auctionMongo.Status,
+		TimeStamp:   time.Unix(auctionMongo.TimeStamp, 0),
+		EndTime:     ar.endTime(auctionMongo),
+
+		StartingPrice: auctionMongo.StartingPrice,
+		ReservePrice:  auctionMongo.ReservePrice,
+		MinIncrement:  auctionMongo.MinIncrement,
+	}
+}
@@ -2,7 +2,7 @@ package bid
 
 import (
 	"context"
-	"sync"
+	"errors"
 
 	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
@@ -10,6 +10,7 @@ import (
 	"github.com/diogokimisima/fullcycle-auction/internal/infra/database/auction"
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type BidEntityMongo struct {
@@ -22,53 +23,113 @@ type BidEntityMongo struct {
 
 type BidRepository struct {
 	Collection        *mongo.Collection
+	HighestBids       *mongo.Collection
 	AuctionRepository *auction.AuctionRepository
 }
 
 func NewBidRepository(database *mongo.Database, auctionRepository *auction.AuctionRepository) *BidRepository {
 	return &BidRepository{
 		Collection:        database.Collection("bids"),
+		HighestBids:       database.Collection("highest_bids"),
 		AuctionRepository: auctionRepository,
 	}
 }
 
+// CreateBid salva o batch com uma única busca dos leilões dos lances e um
+// InsertMany não ordenado, então um lance recusado ou com erro de escrita não
+// impede a inserção dos outros. Antes da inserção, cada lance precisa superar
+// o maior lance salvo do leilão (ver claimHighestBid).
 func (bd *BidRepository) CreateBid(
 	ctx context.Context,
-	bidEntities []bid_entity.Bid) *internal_error.InternalError {
-	var wg sync.WaitGroup
+	bidEntities []bid_entity.Bid) []*internal_error.InternalError {
+	errs := make([]*internal_error.InternalError, len(bidEntities))
+	if len(bidEntities) == 0 {
+		return errs
+	}
 
-	for _, bid := range bidEntities {
-		wg.Add(1)
+	auctions, err := bd.AuctionRepository.FindAuctionsByIds(ctx, auctionIds(bidEntities))
+	if err != nil {
+		logger.Error("Error trying to find auctions for bid creation", err)
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
 
-		go func(bidValue bid_entity.Bid) {
-			defer wg.Done()
+	var documents []interface{}
+	// posição em bidEntities de cada documento inserido
+	var positions []int
+	for i, bidValue := range bidEntities {
+		auctionEntity, ok := auctions[bidValue.AuctionId]
+		if !ok {
+			errs[i] = internal_error.NewNotFoundError("auction not found")
+			continue
+		}
 
-			auctionEntity, err := bd.AuctionRepository.FindAuctionById(ctx, bidValue.AuctionId)
+		if auctionEntity.Status != auction_entity.Active || auctionEntity.IsExpired(bidValue.Timestamp) {
+			errs[i] = internal_error.NewBadRequestError("auction is closed")
+			continue
+		}
 
-			if err != nil {
-				logger.Error("Error trying to find auction for bid creation", err)
-				return
-			}
+		claimed, err := bd.claimHighestBid(ctx, auctionEntity, bidValue)
+		if err != nil {
+			logger.Error("Error trying to update the highest bid of auction "+bidValue.AuctionId, err)
+			errs[i] = internal_error.NewInternalServerError(
+				"error trying to update the highest bid: " + err.Error())
+			continue
+		}
+		if !claimed {
+			errs[i] = internal_error.NewBadRequestError(bid_entity.BidOutbidMessage)
+			continue
+		}
 
-			if auctionEntity.Status != auction_entity.Active {
-				return
-			}
+		documents = append(documents, &BidEntityMongo{
+			Id:        bidValue.Id,
+			UserId:    bidValue.UserId,
+			AuctionId: bidValue.AuctionId,
+			Amount:    bidValue.Amount,
+			Timestamp: bidValue.Timestamp.Unix(),
+		})
+		positions = append(positions, i)
+	}
 
-			bidEntityMongo := &BidEntityMongo{
-				Id:        bidValue.Id,
-				UserId:    bidValue.UserId,
-				AuctionId: bidValue.AuctionId,
-				Amount:    bidValue.Amount,
-				Timestamp: bidValue.Timestamp.Unix(),
-			}
+	if len(documents) == 0 {
+		return errs
+	}
 
-			if _, err := bd.Collection.InsertOne(ctx, bidEntityMongo); err != nil {
-				logger.Error("Error trying to insert bid", err)
-				return
+	_, insertErr := bd.Collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if insertErr == nil {
+		return errs
+	}
+
+	logger.Error("Error trying to insert bids", insertErr)
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(insertErr, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if writeErr.Index >= 0 && writeErr.Index < len(positions) {
+				errs[positions[writeErr.Index]] = internal_error.NewInternalServerError(
+					"error trying to insert bid: " + writeErr.Message)
 			}
-		}(bid)
+		}
+		return errs
 	}
 
-	wg.Wait()
-	return nil
+	for _, position := range positions {
+		errs[position] = internal_error.NewInternalServerError(
+			"error trying to insert bid: " + insertErr.Error())
+	}
+	return errs
+}
+
+func auctionIds(bidEntities []bid_entity.Bid) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, bidValue := range bidEntities {
+		if !seen[bidValue.AuctionId] {
+			seen[bidValue.AuctionId] = true
+			ids = append(ids, bidValue.AuctionId)
+		}
+	}
+	return ids
 }
@@ -0,0 +1,319 @@
+package bid
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/infra/database/auction"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func auctionDocument(id string, status auction_entity.AuctionStatus, endTime time.Time) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: status},
+		{Key: "timestamp", Value: endTime.Add(-time.Hour).Unix()},
+		{Key: "end_time", Value: endTime.Unix()},
+	}
+}
+
+// updateResponse é a resposta do servidor a uma escrita em highest_bids.
+func updateResponse(matched int32) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: matched}, bson.E{Key: "nModified", Value: matched})
+}
+
+func TestCreateBid_Batch(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	now := time.Now()
+
+	mt.Run("loads the auctions once and inserts the open bids together", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.auctions", mtest.FirstBatch,
+				auctionDocument("open", auction_entity.Active, now.Add(time.Hour)),
+				auctionDocument("closed", auction_entity.Completed, now.Add(time.Hour)),
+				auctionDocument("expired", auction_entity.Active, now.Add(-time.Minute))),
+			updateResponse(1),
+			updateResponse(1),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		bids := []bid_entity.Bid{
+			{Id: "1", AuctionId: "open", Amount: 100, Timestamp: now},
+			{Id: "2", AuctionId: "closed", Amount: 100, Timestamp: now},
+			{Id: "3", AuctionId: "open", Amount: 200, Timestamp: now},
+			{Id: "4", AuctionId: "expired", Amount: 100, Timestamp: now},
+			{Id: "5", AuctionId: "unknown", Amount: 100, Timestamp: now},
+		}
+		errs := repository.CreateBid(context.Background(), bids)
+
+		expected := []string{"", "bad_request", "", "bad_request", "not_found"}
+		for i, want := range expected {
+			if got := errString(errs[i]); got != want {
+				mt.Errorf("bid %s: expected %q, got %q", bids[i].Id, want, got)
+			}
+		}
+
+		find := mt.GetStartedEvent()
+		ids, _ := find.Command.Lookup("filter", "_id", "$in").Array().Values()
+		if find.CommandName != "find" || len(ids) != 4 {
+			mt.Fatalf("expected one find for the 4 distinct auctions, got %s with %d ids", find.CommandName, len(ids))
+		}
+
+		for _, id := range []string{"1", "3"} {
+			update := mt.GetStartedEvent()
+			if update.CommandName != "update" {
+				mt.Fatalf("expected the highest bid update of bid %s, got %s", id, update.CommandName)
+			}
+			statement := update.Command.Lookup("updates", "0").Document()
+			if got := statement.Lookup("u", "$set", "bid_id").StringValue(); got != id {
+				mt.Errorf("expected the highest bid update of bid %s, got %s", id, got)
+			}
+			if !statement.Lookup("upsert").Boolean() {
+				mt.Errorf("expected an upsert for bid %s", id)
+			}
+		}
+
+		insert := mt.GetStartedEvent()
+		documents, _ := insert.Command.Lookup("documents").Array().Values()
+		if insert.CommandName != "insert" || len(documents) != 2 {
+			mt.Fatalf("expected one insert with the 2 open bids, got %s with %d documents", insert.CommandName, len(documents))
+		}
+		if insert.Command.Lookup("ordered").Boolean() {
+			mt.Fatal("expected an unordered insert")
+		}
+		if mt.GetStartedEvent() != nil {
+			mt.Fatal("expected no other command for the batch")
+		}
+	})
+
+	mt.Run("maps write errors back to their bids", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.auctions", mtest.FirstBatch,
+				auctionDocument("open", auction_entity.Active, now.Add(time.Hour)),
+				auctionDocument("closed", auction_entity.Completed, now.Add(time.Hour))),
+			updateResponse(1),
+			updateResponse(1),
+			// o índice 1 é o segundo documento inserido, o terceiro lance
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}))
+
+		errs := repository.CreateBid(context.Background(), []bid_entity.Bid{
+			{Id: "1", AuctionId: "open", Amount: 100, Timestamp: now},
+			{Id: "2", AuctionId: "closed", Amount: 100, Timestamp: now},
+			{Id: "3", AuctionId: "open", Amount: 200, Timestamp: now},
+		})
+
+		expected := []string{"", "bad_request", "internal_server_error"}
+		for i, want := range expected {
+			if got := errString(errs[i]); got != want {
+				mt.Errorf("bid %d: expected %q, got %q", i, want, got)
+			}
+		}
+	})
+
+	mt.Run("rejects the bids that do not beat the highest bid saved", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		duplicate := mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.auctions", mtest.FirstBatch,
+				auctionDocument("open", auction_entity.Active, now.Add(time.Hour))),
+			// o primeiro lance perde para o maior lance salvo por outra instância
+			duplicate,
+			updateResponse(0),
+			// o segundo supera o maior lance salvo
+			duplicate,
+			updateResponse(1),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		errs := repository.CreateBid(context.Background(), []bid_entity.Bid{
+			{Id: "1", AuctionId: "open", Amount: 100, Timestamp: now},
+			{Id: "2", AuctionId: "open", Amount: 300, Timestamp: now},
+		})
+
+		if errs[0] == nil || errs[0].Err != "bad_request" || errs[0].Message != bid_entity.BidOutbidMessage {
+			mt.Errorf("expected the first bid to be outbid, got %v", errs[0])
+		}
+		if errs[1] != nil {
+			mt.Errorf("expected the second bid to be saved, got %v", errs[1])
+		}
+
+		mt.GetStartedEvent()
+		for i := 0; i < 4; i++ {
+			if update := mt.GetStartedEvent(); update.CommandName != "update" {
+				mt.Fatalf("expected 4 highest bid updates, got %s", update.CommandName)
+			}
+		}
+		insert := mt.GetStartedEvent()
+		documents, _ := insert.Command.Lookup("documents").Array().Values()
+		if insert.CommandName != "insert" || len(documents) != 1 {
+			mt.Fatalf("expected one insert with the winning bid, got %s with %d documents", insert.CommandName, len(documents))
+		}
+	})
+
+	mt.Run("finds the highest bid in its document", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.highest_bids", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "open"}, {Key: "bid_id", Value: "1"}, {Key: "amount", Value: 250.5}, {Key: "amount_cents", Value: int64(25050)}}))
+
+		amount, err := repository.FindHighestBidAmount(context.Background(), "open")
+		if err != nil || amount != 250.5 {
+			mt.Fatalf("expected 250.5, got %v (%v)", amount, err)
+		}
+	})
+
+	mt.Run("finds the highest bid in the bids without its document", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.highest_bids", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.bids", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "1"}, {Key: "auction_id", Value: "open"}, {Key: "amount", Value: 120.0}, {Key: "timestamp", Value: now.Unix()}}))
+
+		amount, err := repository.FindHighestBidAmount(context.Background(), "open")
+		if err != nil || amount != 120 {
+			mt.Fatalf("expected 120, got %v (%v)", amount, err)
+		}
+	})
+
+	mt.Run("fails every bid when the auctions cannot be loaded", func(mt *mtest.T) {
+		repository := NewBidRepository(mt.DB, auction.NewAuctionRepository(mt.DB))
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "failed"}))
+
+		errs := repository.CreateBid(context.Background(), []bid_entity.Bid{
+			{Id: "1", AuctionId: "open", Amount: 100, Timestamp: now},
+			{Id: "2", AuctionId: "open", Amount: 200, Timestamp: now},
+		})
+		for i, err := range errs {
+			if errString(err) != "internal_server_error" {
+				mt.Errorf("bid %d: expected internal_server_error, got %v", i, err)
+			}
+		}
+	})
+}
+
+func errString(err *internal_error.InternalError) string {
+	if err == nil {
+		return ""
+	}
+	return err.Err
+}
+
+// createBidOneByOne é a inserção anterior ao InsertMany, mantida para os
+// benchmarks: uma goroutine por lance, cada uma com uma busca e uma inserção.
+func createBidOneByOne(
+	ctx context.Context, bd *BidRepository, bidEntities []bid_entity.Bid) []*internal_error.InternalError {
+	var wg sync.WaitGroup
+	errs := make([]*internal_error.InternalError, len(bidEntities))
+
+	for i, bid := range bidEntities {
+		wg.Add(1)
+
+		go func(i int, bidValue bid_entity.Bid) {
+			defer wg.Done()
+
+			auctionEntity, err := bd.AuctionRepository.FindAuctionById(ctx, bidValue.AuctionId)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			if auctionEntity.Status != auction_entity.Active || auctionEntity.IsExpired(bidValue.Timestamp) {
+				errs[i] = internal_error.NewBadRequestError("auction is closed")
+				return
+			}
+
+			if _, err := bd.Collection.InsertOne(ctx, &BidEntityMongo{
+				Id:        bidValue.Id,
+				UserId:    bidValue.UserId,
+				AuctionId: bidValue.AuctionId,
+				Amount:    bidValue.Amount,
+				Timestamp: bidValue.Timestamp.Unix(),
+			}); err != nil {
+				errs[i] = internal_error.NewInternalServerError("error trying to insert bid")
+			}
+		}(i, bid)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+// BenchmarkCreateBid compara o batch com a inserção lance a lance em um
+// MongoDB real, com todos os lances no mesmo leilão. commands/op é o número
+// de comandos enviados ao banco por batch:
+//
+//	MONGODB_URL=mongodb://localhost:27017 go test -run '^$' -bench CreateBid ./internal/infra/database/bid/
+func BenchmarkCreateBid(b *testing.B) {
+	mongoURL := os.Getenv("MONGODB_URL")
+	if mongoURL == "" {
+		b.Skip("MONGODB_URL not set")
+	}
+
+	ctx := context.Background()
+	var commands atomic.Int64
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURL).SetMonitor(&event.CommandMonitor{
+		Started: func(context.Context, *event.CommandStartedEvent) { commands.Add(1) },
+	}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	database := client.Database("auctions_benchmark")
+	b.Cleanup(func() {
+		database.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+
+	auctionRepository := auction.NewAuctionRepository(database)
+	auctionEntity := &auction_entity.Auction{
+		Id: uuid.New().String(), Status: auction_entity.Active, TimeStamp: time.Now(), EndTime: time.Now().Add(time.Hour)}
+	if err := auctionRepository.CreateAuction(ctx, auctionEntity); err != nil {
+		b.Fatal(err)
+	}
+	repository := NewBidRepository(database, auctionRepository)
+
+	strategies := []struct {
+		name   string
+		create func(context.Context, []bid_entity.Bid) []*internal_error.InternalError
+	}{
+		{"InsertMany", repository.CreateBid},
+		{"OneByOne", func(ctx context.Context, bids []bid_entity.Bid) []*internal_error.InternalError {
+			return createBidOneByOne(ctx, repository, bids)
+		}},
+	}
+
+	for _, size := range []int{10, 100, 500} {
+		for _, strategy := range strategies {
+			b.Run(fmt.Sprintf("%s/%d", strategy.name, size), func(b *testing.B) {
+				commands.Store(0)
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					bids := make([]bid_entity.Bid, size)
+					for j := range bids {
+						bids[j] = bid_entity.Bid{
+							Id: uuid.New().String(), AuctionId: auctionEntity.Id, Amount: float64(i*size + j + 1), Timestamp: time.Now()}
+					}
+					b.StartTimer()
+
+					for _, err := range strategy.create(ctx, bids) {
+						if err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+				b.ReportMetric(float64(commands.Load())/float64(b.N), "commands/op")
+			})
+		}
+	}
+}
@@ -2,12 +2,14 @@ package bid
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -52,6 +54,11 @@ func (bd *BidRepository) FindWinnigBidByAuctionId(
 	var bidEntityMongo BidEntityMongo
 	opts := options.FindOne().SetSort(bson.D{{Key: "amount", Value: -1}})
 	if err := bd.Collection.FindOne(ctx, filter, opts).Decode(&bidEntityMongo); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, internal_error.NewNotFoundError(
+				"no bids found for auction id: " + auctionId)
+		}
+
 		logger.Error("Error trying to find winning bid by auction id: "+auctionId, err)
 		return nil, internal_error.NewInternalServerError(
 			"error trying to find winning bid by auction id: " + err.Error())
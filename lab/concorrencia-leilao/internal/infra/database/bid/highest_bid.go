@@ -0,0 +1,85 @@
+package bid
+
+import (
+	"context"
+	"errors"
+
+	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HighestBidEntityMongo é o maior lance salvo de um leilão, um documento por
+// leilão na coleção highest_bids. As instâncias da API guardam o maior lance
+// em memória, então é este documento que impede uma instância de salvar um
+// lance menor que o já salvo por outra. O documento é criado no primeiro lance
+// salvo depois dele existir.
+type HighestBidEntityMongo struct {
+	AuctionId   string  `bson:"_id"`
+	BidId       string  `bson:"bid_id"`
+	Amount      float64 `bson:"amount"`
+	AmountCents int64   `bson:"amount_cents"`
+}
+
+// claimHighestBid torna bid o maior lance do leilão, com uma escrita
+// condicional, se ele supera o maior lance salvo. Retorna false quando o
+// maior lance salvo não é superado.
+func (bd *BidRepository) claimHighestBid(
+	ctx context.Context, auctionEntity *auction_entity.Auction, bidValue bid_entity.Bid) (bool, error) {
+	filter := bson.M{
+		"_id":          bidValue.AuctionId,
+		"amount_cents": bson.M{"$lte": auctionEntity.OutbidCents(bidValue.Amount)},
+	}
+	update := bson.M{"$set": bson.M{
+		"bid_id":       bidValue.Id,
+		"amount":       bidValue.Amount,
+		"amount_cents": auction_entity.Cents(bidValue.Amount),
+	}}
+
+	// sem documento, o upsert o cria; com um documento que o filtro não
+	// encontra, o upsert falha com a chave duplicada
+	_, err := bd.HighestBids.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	// o documento existe, criado pelo primeiro lance ou por outra instância
+	// ao mesmo tempo: o lance vence só se a atualização o encontrar
+	result, err := bd.HighestBids.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// FindHighestBidAmount lê o maior lance do documento do leilão. Leilões cujos
+// lances são anteriores ao documento usam o maior lance da coleção bids.
+func (bd *BidRepository) FindHighestBidAmount(
+	ctx context.Context, auctionId string) (float64, *internal_error.InternalError) {
+	var highestBid HighestBidEntityMongo
+	err := bd.HighestBids.FindOne(ctx, bson.M{"_id": auctionId}).Decode(&highestBid)
+	if err == nil {
+		return highestBid.Amount, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Error("Error trying to find the highest bid by auction id: "+auctionId, err)
+		return 0, internal_error.NewInternalServerError(
+			"error trying to find the highest bid by auction id: " + err.Error())
+	}
+
+	winningBid, findErr := bd.FindWinnigBidByAuctionId(ctx, auctionId)
+	if findErr != nil {
+		if findErr.Err == "not_found" {
+			return 0, nil
+		}
+		return 0, findErr
+	}
+	return winningBid.Amount, nil
+}
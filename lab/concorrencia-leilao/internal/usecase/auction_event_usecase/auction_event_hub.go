@@ -0,0 +1,252 @@
+package auction_event_usecase
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventHighestBid = "highest_bid"
+	EventCountdown  = "countdown"
+	EventClosed     = "closed"
+)
+
+// AuctionEvent é a atualização enviada aos inscritos de um leilão. Data é o
+// novo maior lance em highest_bid, o CountdownOutputDTO em countdown e o
+// vencedor do leilão em closed.
+type AuctionEvent struct {
+	Type      string    `json:"type"`
+	AuctionId string    `json:"auction_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data,omitempty"`
+}
+
+type CountdownOutputDTO struct {
+	EndTime          time.Time `json:"end_time"`
+	RemainingSeconds int64     `json:"remaining_seconds"`
+}
+
+type Publisher interface {
+	Publish(event AuctionEvent)
+}
+
+// Hub distribui os eventos de cada leilão aos seus inscritos. O envio nunca
+// bloqueia quem publica: o inscrito que não consome os eventos a tempo e
+// enche o seu buffer é desconectado.
+type Hub struct {
+	mu       sync.Mutex
+	auctions map[string]*auctionSubscribers
+	closed   bool
+
+	bufferSize        int
+	countdownInterval time.Duration
+	now               func() time.Time
+}
+
+type auctionSubscribers struct {
+	endTime     time.Time
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription recebe os eventos de um leilão em Events até o leilão fechar,
+// o inscrito ser desconectado ou Close ser chamado, quando Events é fechado.
+type Subscription struct {
+	AuctionId string
+
+	hub     *Hub
+	events  chan AuctionEvent
+	dropped atomic.Bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		auctions:          make(map[string]*auctionSubscribers),
+		bufferSize:        getAuctionEventsBuffer(),
+		countdownInterval: getAuctionCountdownInterval(),
+		now:               time.Now,
+	}
+}
+
+// Subscribe inscreve um novo consumidor nos eventos do leilão, que termina
+// em endTime. Depois do Shutdown a inscrição já vem fechada.
+func (h *Hub) Subscribe(auctionId string, endTime time.Time) *Subscription {
+	subscription := &Subscription{
+		AuctionId: auctionId,
+		hub:       h,
+		events:    make(chan AuctionEvent, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(subscription.events)
+		return subscription
+	}
+
+	auction, ok := h.auctions[auctionId]
+	if !ok {
+		auction = &auctionSubscribers{subscribers: make(map[*Subscription]struct{})}
+		h.auctions[auctionId] = auction
+	}
+	auction.endTime = endTime
+	auction.subscribers[subscription] = struct{}{}
+
+	return subscription
+}
+
+// Publish envia o evento aos inscritos do leilão. Depois do evento closed as
+// inscrições do leilão são encerradas.
+func (h *Hub) Publish(event AuctionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	auction, ok := h.auctions[event.AuctionId]
+	if !ok {
+		return
+	}
+
+	for subscription := range auction.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.dropped.Store(true)
+			h.remove(subscription)
+		}
+	}
+
+	if event.Type == EventClosed {
+		for subscription := range auction.subscribers {
+			h.remove(subscription)
+		}
+	}
+}
+
+// ExpiredAuctions lista os leilões com inscritos cujo término já passou, que
+// devem receber o evento closed.
+func (h *Hub) ExpiredAuctions(now time.Time) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var auctionIds []string
+	for auctionId, auction := range h.auctions {
+		if !now.Before(auction.endTime) {
+			auctionIds = append(auctionIds, auctionId)
+		}
+	}
+	return auctionIds
+}
+
+// Start envia, a cada AUCTION_COUNTDOWN_INTERVAL e até ctx terminar, o tempo
+// restante dos leilões com inscritos que ainda não terminaram.
+func (h *Hub) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.countdownInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.publishCountdown()
+			}
+		}
+	}()
+}
+
+func (h *Hub) publishCountdown() {
+	now := h.now()
+
+	h.mu.Lock()
+	var events []AuctionEvent
+	for auctionId, auction := range h.auctions {
+		if now.Before(auction.endTime) {
+			events = append(events, AuctionEvent{
+				Type:      EventCountdown,
+				AuctionId: auctionId,
+				Timestamp: now,
+				Data: CountdownOutputDTO{
+					EndTime:          auction.endTime,
+					RemainingSeconds: int64(auction.endTime.Sub(now).Seconds()),
+				},
+			})
+		}
+	}
+	h.mu.Unlock()
+
+	for _, event := range events {
+		h.Publish(event)
+	}
+}
+
+// Shutdown encerra todas as inscrições, para que as conexões abertas não
+// impeçam o servidor de fechar, e recusa as novas.
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, auction := range h.auctions {
+		for subscription := range auction.subscribers {
+			h.remove(subscription)
+		}
+	}
+}
+
+// remove fecha a inscrição; deve ser chamado com h.mu.
+func (h *Hub) remove(subscription *Subscription) {
+	auction, ok := h.auctions[subscription.AuctionId]
+	if !ok {
+		return
+	}
+	if _, ok := auction.subscribers[subscription]; !ok {
+		return
+	}
+
+	delete(auction.subscribers, subscription)
+	close(subscription.events)
+	if len(auction.subscribers) == 0 {
+		delete(h.auctions, subscription.AuctionId)
+	}
+}
+
+func (s *Subscription) Events() <-chan AuctionEvent {
+	return s.events
+}
+
+// Dropped indica que a inscrição foi encerrada por não consumir os eventos a
+// tempo.
+func (s *Subscription) Dropped() bool {
+	return s.dropped.Load()
+}
+
+// Close cancela a inscrição; pode ser chamado mais de uma vez.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func getAuctionEventsBuffer() int {
+	value, err := strconv.Atoi(os.Getenv("AUCTION_EVENTS_BUFFER"))
+	if err != nil || value <= 0 {
+		return 16
+	}
+
+	return value
+}
+
+func getAuctionCountdownInterval() time.Duration {
+	countdownInterval := os.Getenv("AUCTION_COUNTDOWN_INTERVAL")
+	duration, err := time.ParseDuration(countdownInterval)
+	if err != nil || duration <= 0 {
+		return time.Second
+	}
+
+	return duration
+}
@@ -0,0 +1,150 @@
+package auction_event_usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestHub(bufferSize int) *Hub {
+	hub := NewHub()
+	hub.bufferSize = bufferSize
+	return hub
+}
+
+func TestHub_FansOutToTheAuctionSubscribers(t *testing.T) {
+	hub := newTestHub(4)
+	endTime := time.Now().Add(time.Hour)
+	first := hub.Subscribe("auction", endTime)
+	second := hub.Subscribe("auction", endTime)
+	other := hub.Subscribe("other", endTime)
+
+	hub.Publish(AuctionEvent{Type: EventHighestBid, AuctionId: "auction", Data: 100})
+
+	for _, subscription := range []*Subscription{first, second} {
+		if event := <-subscription.Events(); event.Type != EventHighestBid || event.Data != 100 {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+	if len(other.Events()) != 0 {
+		t.Fatal("subscriber of another auction received the event")
+	}
+
+	first.Close()
+	first.Close()
+	if _, ok := <-first.Events(); ok {
+		t.Fatal("expected closed subscription")
+	}
+	hub.Publish(AuctionEvent{Type: EventHighestBid, AuctionId: "auction", Data: 200})
+	if event := <-second.Events(); event.Data != 200 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+func TestHub_DropsSlowConsumers(t *testing.T) {
+	hub := newTestHub(2)
+	endTime := time.Now().Add(time.Hour)
+	slow := hub.Subscribe("auction", endTime)
+	fast := hub.Subscribe("auction", endTime)
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(AuctionEvent{Type: EventHighestBid, AuctionId: "auction", Data: i})
+		<-fast.Events()
+	}
+
+	// o inscrito lento recebe o que já estava no buffer e depois é desconectado
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != 2 || !slow.Dropped() {
+		t.Fatalf("expected slow consumer to be dropped after 2 events, got %d events, dropped=%v", received, slow.Dropped())
+	}
+	if fast.Dropped() {
+		t.Fatal("fast consumer should not be dropped")
+	}
+}
+
+func TestHub_ClosedEventEndsTheSubscriptions(t *testing.T) {
+	hub := newTestHub(4)
+	subscription := hub.Subscribe("auction", time.Now())
+
+	hub.Publish(AuctionEvent{Type: EventClosed, AuctionId: "auction"})
+
+	if event := <-subscription.Events(); event.Type != EventClosed {
+		t.Fatalf("expected closed event, got %+v", event)
+	}
+	if _, ok := <-subscription.Events(); ok {
+		t.Fatal("expected subscription to end after the closed event")
+	}
+	if subscription.Dropped() {
+		t.Fatal("subscription ended by the closed event is not dropped")
+	}
+	if expired := hub.ExpiredAuctions(time.Now()); len(expired) != 0 {
+		t.Fatalf("closed auction should not be watched anymore, got %v", expired)
+	}
+}
+
+func TestHub_ExpiredAuctions(t *testing.T) {
+	hub := newTestHub(4)
+	now := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+	hub.Subscribe("ended", now)
+	hub.Subscribe("running", now.Add(time.Second))
+
+	expired := hub.ExpiredAuctions(now)
+	if len(expired) != 1 || expired[0] != "ended" {
+		t.Fatalf("expected only the ended auction, got %v", expired)
+	}
+}
+
+func TestHub_Countdown(t *testing.T) {
+	hub := newTestHub(4)
+	now := time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)
+	hub.now = func() time.Time { return now }
+	running := hub.Subscribe("running", now.Add(90*time.Second))
+	ended := hub.Subscribe("ended", now)
+
+	hub.publishCountdown()
+
+	event := <-running.Events()
+	countdown := event.Data.(CountdownOutputDTO)
+	if event.Type != EventCountdown || countdown.RemainingSeconds != 90 {
+		t.Fatalf("expected 90 seconds remaining, got %+v", event)
+	}
+	if len(ended.Events()) != 0 {
+		t.Fatal("ended auction should not receive countdown ticks")
+	}
+}
+
+func TestHub_StartSendsCountdownTicks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := newTestHub(4)
+	hub.countdownInterval = time.Millisecond
+	subscription := hub.Subscribe("auction", time.Now().Add(time.Hour))
+	hub.Start(ctx)
+
+	select {
+	case event := <-subscription.Events():
+		if event.Type != EventCountdown {
+			t.Fatalf("expected countdown, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no countdown tick received")
+	}
+}
+
+func TestHub_Shutdown(t *testing.T) {
+	hub := newTestHub(4)
+	subscription := hub.Subscribe("auction", time.Now().Add(time.Hour))
+
+	hub.Shutdown()
+
+	if _, ok := <-subscription.Events(); ok {
+		t.Fatal("expected subscription to end on shutdown")
+	}
+	if _, ok := <-hub.Subscribe("auction", time.Now().Add(time.Hour)).Events(); ok {
+		t.Fatal("expected new subscriptions to be closed after shutdown")
+	}
+}
@@ -0,0 +1,79 @@
+package auction_usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+)
+
+// SubscribeAuctionEvents inscreve o cliente nos eventos do leilão. Os eventos
+// retornados trazem o estado atual: o maior lance e o tempo restante do
+// leilão aberto, ou só o evento closed do leilão já fechado, que não tem
+// inscrição.
+func (au *AuctionUseCase) SubscribeAuctionEvents(
+	ctx context.Context,
+	auctionId string) ([]auction_event_usecase.AuctionEvent, *auction_event_usecase.Subscription, *internal_error.InternalError) {
+	auction, err := au.auctionRepositoryInterface.FindAuctionById(ctx, auctionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a inscrição vem antes da busca do maior lance, para não perder os
+	// lances salvos entre as duas
+	var subscription *auction_event_usecase.Subscription
+	if auction.Status == auction_entity.Active {
+		subscription = au.auctionEvents.Subscribe(auction.Id, auction.EndTime)
+	}
+
+	winningInfo, err := au.FindWinningBidByAuctionId(ctx, auction.Id)
+	if err != nil {
+		if subscription != nil {
+			subscription.Close()
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if subscription == nil {
+		return []auction_event_usecase.AuctionEvent{closedEvent(winningInfo, now)}, nil, nil
+	}
+
+	var events []auction_event_usecase.AuctionEvent
+	highestBid := winningInfo.Bid
+	if highestBid == nil {
+		highestBid = winningInfo.HighestBid
+	}
+	if highestBid != nil {
+		events = append(events, auction_event_usecase.AuctionEvent{
+			Type:      auction_event_usecase.EventHighestBid,
+			AuctionId: auction.Id,
+			Timestamp: highestBid.Timestamp,
+			Data:      *highestBid,
+		})
+	}
+	if now.Before(auction.EndTime) {
+		events = append(events, auction_event_usecase.AuctionEvent{
+			Type:      auction_event_usecase.EventCountdown,
+			AuctionId: auction.Id,
+			Timestamp: now,
+			Data: auction_event_usecase.CountdownOutputDTO{
+				EndTime:          auction.EndTime,
+				RemainingSeconds: int64(auction.EndTime.Sub(now).Seconds()),
+			},
+		})
+	}
+
+	return events, subscription, nil
+}
+
+func closedEvent(winningInfo *WinningInfoOutputDTO, now time.Time) auction_event_usecase.AuctionEvent {
+	return auction_event_usecase.AuctionEvent{
+		Type:      auction_event_usecase.EventClosed,
+		AuctionId: winningInfo.Auction.Id,
+		Timestamp: now,
+		Data:      winningInfo,
+	}
+}
@@ -0,0 +1,139 @@
+package auction_usecase
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/bid_usecase"
+)
+
+// AuctionScheduler fecha os leilões vencidos. Um único scheduler atende todos
+// os leilões, usando o término salvo no banco, então os leilões que venceram
+// com o serviço parado são fechados assim que ele volta. Os inscritos dos
+// leilões fechados recebem o evento closed com o vencedor, e o estado dos
+// seus lances é descartado.
+type AuctionScheduler struct {
+	auctionRepositoryInterface auction_entity.AuctionRepositoryInterface
+	auctionUseCase             *AuctionUseCase
+	auctionEvents              *auction_event_usecase.Hub
+	bidUseCase                 bid_usecase.BidUseCaseInterface
+
+	checkInterval time.Duration
+	now           func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewAuctionScheduler(
+	auctionRepository auction_entity.AuctionRepositoryInterface,
+	bidRepository bid_entity.BidEntityRepository,
+	auctionEvents *auction_event_usecase.Hub,
+	bidUseCase bid_usecase.BidUseCaseInterface) *AuctionScheduler {
+	return &AuctionScheduler{
+		auctionRepositoryInterface: auctionRepository,
+		auctionUseCase: &AuctionUseCase{
+			auctionRepositoryInterface: auctionRepository,
+			bidRepositoryInterface:     bidRepository,
+		},
+		auctionEvents: auctionEvents,
+		bidUseCase:    bidUseCase,
+		checkInterval: getAuctionCheckInterval(),
+		now:           time.Now,
+	}
+}
+
+// Start fecha os leilões que venceram enquanto o serviço estava parado e, até
+// ctx terminar ou o Shutdown, verifica os leilões vencidos a cada
+// AUCTION_CHECK_INTERVAL.
+func (as *AuctionScheduler) Start(ctx context.Context) {
+	as.CloseExpiredAuctions(ctx)
+
+	ctx, as.cancel = context.WithCancel(ctx)
+	as.done = make(chan struct{})
+
+	go func() {
+		defer close(as.done)
+
+		ticker := time.NewTicker(as.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// a verificação em andamento termina mesmo com o Shutdown
+				as.CloseExpiredAuctions(context.WithoutCancel(ctx))
+			}
+		}
+	}()
+}
+
+// Shutdown para as verificações e espera a que estiver em andamento terminar,
+// ou ctx terminar.
+func (as *AuctionScheduler) Shutdown(ctx context.Context) error {
+	if as.cancel == nil {
+		return nil
+	}
+	as.cancel()
+
+	select {
+	case <-as.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (as *AuctionScheduler) CloseExpiredAuctions(ctx context.Context) *internal_error.InternalError {
+	now := as.now()
+	closed, err := as.auctionRepositoryInterface.CloseExpiredAuctions(ctx, now)
+	if err != nil {
+		logger.Error("error trying to close expired auctions", err)
+		return err
+	}
+
+	if closed > 0 {
+		logger.Info(fmt.Sprintf("closed %d expired auctions", closed))
+	}
+
+	// inclui os leilões fechados por outra instância
+	as.bidUseCase.ForgetClosedAuctions(now)
+
+	as.publishClosedAuctions(ctx, now)
+
+	return nil
+}
+
+// publishClosedAuctions envia o evento closed aos inscritos dos leilões que
+// terminaram. Se o vencedor não puder ser buscado, o evento fica para a
+// próxima verificação.
+func (as *AuctionScheduler) publishClosedAuctions(ctx context.Context, now time.Time) {
+	for _, auctionId := range as.auctionEvents.ExpiredAuctions(now) {
+		winningInfo, err := as.auctionUseCase.FindWinningBidByAuctionId(ctx, auctionId)
+		if err != nil {
+			logger.Error("error trying to find winner of closed auction "+auctionId, err)
+			continue
+		}
+
+		as.auctionEvents.Publish(closedEvent(winningInfo, now))
+	}
+}
+
+func getAuctionCheckInterval() time.Duration {
+	checkInterval := os.Getenv("AUCTION_CHECK_INTERVAL")
+	duration, err := time.ParseDuration(checkInterval)
+	if err != nil || duration <= 0 {
+		return 10 * time.Second
+	}
+
+	return duration
+}
@@ -0,0 +1,332 @@
+package auction_usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/bid_usecase"
+)
+
+// fakeClock é o relógio injetado no scheduler, avançado manualmente pelo teste.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeAuctionRepository guarda os leilões em memória e avisa em closeCalls
+// a cada CloseExpiredAuctions. Com release, cada CloseExpiredAuctions só
+// termina depois de um envio em release.
+type fakeAuctionRepository struct {
+	mu         sync.Mutex
+	auctions   map[string]*auction_entity.Auction
+	closeCalls chan time.Time
+	release    chan struct{}
+}
+
+func newFakeAuctionRepository() *fakeAuctionRepository {
+	return &fakeAuctionRepository{
+		auctions:   make(map[string]*auction_entity.Auction),
+		closeCalls: make(chan time.Time, 100),
+	}
+}
+
+func (r *fakeAuctionRepository) CreateAuction(
+	ctx context.Context, auction *auction_entity.Auction) *internal_error.InternalError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *auction
+	r.auctions[auction.Id] = &copied
+	return nil
+}
+
+func (r *fakeAuctionRepository) FindAuctionById(
+	ctx context.Context, id string) (*auction_entity.Auction, *internal_error.InternalError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	auction, ok := r.auctions[id]
+	if !ok {
+		return nil, internal_error.NewNotFoundError("auction not found")
+	}
+	copied := *auction
+	return &copied, nil
+}
+
+func (r *fakeAuctionRepository) FindAuctions(
+	ctx context.Context, status auction_entity.AuctionStatus,
+	category, productName string) ([]auction_entity.Auction, *internal_error.InternalError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var auctions []auction_entity.Auction
+	for _, auction := range r.auctions {
+		auctions = append(auctions, *auction)
+	}
+	return auctions, nil
+}
+
+func (r *fakeAuctionRepository) CloseExpiredAuctions(
+	ctx context.Context, now time.Time) (int64, *internal_error.InternalError) {
+	r.mu.Lock()
+	release := r.release
+	r.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var closed int64
+	for _, auction := range r.auctions {
+		if auction.Status == auction_entity.Active && auction.IsExpired(now) {
+			auction.Status = auction_entity.Completed
+			closed++
+		}
+	}
+	r.closeCalls <- now
+	return closed, nil
+}
+
+func (r *fakeAuctionRepository) status(t *testing.T, id string) auction_entity.AuctionStatus {
+	t.Helper()
+	auction, err := r.FindAuctionById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("auction %s not found", id)
+	}
+	return auction.Status
+}
+
+// fakeBidUseCase registra os instantes passados a ForgetClosedAuctions.
+type fakeBidUseCase struct {
+	bid_usecase.BidUseCaseInterface
+
+	mu     sync.Mutex
+	forgot []time.Time
+}
+
+func (u *fakeBidUseCase) ForgetClosedAuctions(now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.forgot = append(u.forgot, now)
+}
+
+func newTestScheduler(repository *fakeAuctionRepository, clock *fakeClock) *AuctionScheduler {
+	scheduler := NewAuctionScheduler(
+		repository, &fakeBidRepository{}, auction_event_usecase.NewHub(), &fakeBidUseCase{})
+	scheduler.now = clock.Now
+	return scheduler
+}
+
+func TestAuctionScheduler_CloseExpiredAuctions(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)}
+	repository := newFakeAuctionRepository()
+
+	repository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "short", Status: auction_entity.Active, EndTime: clock.Now().Add(time.Minute)})
+	repository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "long", Status: auction_entity.Active, EndTime: clock.Now().Add(time.Hour)})
+
+	scheduler := newTestScheduler(repository, clock)
+
+	if err := scheduler.CloseExpiredAuctions(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := repository.status(t, "short"); status != auction_entity.Active {
+		t.Fatalf("auction should be active before its end time, got status %d", status)
+	}
+
+	clock.Advance(time.Minute)
+	scheduler.CloseExpiredAuctions(ctx)
+	if status := repository.status(t, "short"); status != auction_entity.Completed {
+		t.Fatalf("auction should be completed at its end time, got status %d", status)
+	}
+	if status := repository.status(t, "long"); status != auction_entity.Active {
+		t.Fatalf("auction should still be active, got status %d", status)
+	}
+
+	clock.Advance(time.Hour)
+	scheduler.CloseExpiredAuctions(ctx)
+	if status := repository.status(t, "long"); status != auction_entity.Completed {
+		t.Fatalf("auction should be completed after its end time, got status %d", status)
+	}
+
+	// o estado dos lances é descartado a cada verificação, até o instante dela
+	bidUseCase := scheduler.bidUseCase.(*fakeBidUseCase)
+	if len(bidUseCase.forgot) != 3 || !bidUseCase.forgot[2].Equal(clock.Now()) {
+		t.Fatalf("expected closed auctions to be forgotten on every check, got %v", bidUseCase.forgot)
+	}
+}
+
+func TestAuctionScheduler_PublishesClosedAuctions(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)}
+	repository := newFakeAuctionRepository()
+	repository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "auction", Status: auction_entity.Active, EndTime: clock.Now().Add(time.Minute)})
+
+	scheduler := newTestScheduler(repository, clock)
+	scheduler.auctionUseCase.bidRepositoryInterface = &fakeBidRepository{winning: map[string]bid_entity.Bid{
+		"auction": {Id: "winner", Amount: 1500}}}
+	subscription := scheduler.auctionEvents.Subscribe("auction", clock.Now().Add(time.Minute))
+
+	scheduler.CloseExpiredAuctions(ctx)
+	if len(subscription.Events()) != 0 {
+		t.Fatal("expected no events before the auction ends")
+	}
+
+	clock.Advance(time.Minute)
+	scheduler.CloseExpiredAuctions(ctx)
+
+	event := <-subscription.Events()
+	if event.Type != auction_event_usecase.EventClosed || event.AuctionId != "auction" {
+		t.Fatalf("expected closed event, got %+v", event)
+	}
+	winningInfo := event.Data.(*WinningInfoOutputDTO)
+	if winningInfo.Bid == nil || winningInfo.Bid.Id != "winner" {
+		t.Fatalf("expected the winner in the closed event, got %+v", winningInfo)
+	}
+	if winningInfo.Auction.Status != AuctionStatus(auction_entity.Completed) {
+		t.Fatalf("expected completed auction, got status %d", winningInfo.Auction.Status)
+	}
+	if _, ok := <-subscription.Events(); ok {
+		t.Fatal("expected subscription to end after the closed event")
+	}
+}
+
+func TestAuctionScheduler_StartRecoversExpiredAuctions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := &fakeClock{now: time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)}
+	repository := newFakeAuctionRepository()
+
+	// leilão que venceu enquanto o serviço estava parado
+	repository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "expired", Status: auction_entity.Active, EndTime: clock.Now().Add(-time.Hour)})
+	repository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "running", Status: auction_entity.Active, EndTime: clock.Now().Add(time.Minute)})
+
+	scheduler := newTestScheduler(repository, clock)
+	scheduler.checkInterval = time.Millisecond
+	scheduler.Start(ctx)
+
+	if status := repository.status(t, "expired"); status != auction_entity.Completed {
+		t.Fatalf("expired auction should be closed on start, got status %d", status)
+	}
+	if status := repository.status(t, "running"); status != auction_entity.Active {
+		t.Fatalf("running auction should stay active, got status %d", status)
+	}
+
+	clock.Advance(time.Minute)
+	deadline := time.After(time.Second)
+	for repository.status(t, "running") != auction_entity.Completed {
+		select {
+		case <-repository.closeCalls:
+		case <-deadline:
+			t.Fatal("running auction was not closed by the background check")
+		}
+	}
+
+	// depois do cancelamento o scheduler para de verificar
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	for len(repository.closeCalls) > 0 {
+		<-repository.closeCalls
+	}
+	time.Sleep(10 * time.Millisecond)
+	if calls := len(repository.closeCalls); calls != 0 {
+		t.Fatalf("scheduler kept running after cancel: %d checks", calls)
+	}
+}
+
+func TestAuctionScheduler_ShutdownWaitsForTheRunningCheck(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)}
+	repository := newFakeAuctionRepository()
+	repository.CreateAuction(context.Background(), &auction_entity.Auction{
+		Id: "auction", Status: auction_entity.Active, EndTime: clock.Now().Add(time.Minute)})
+
+	scheduler := newTestScheduler(repository, clock)
+	scheduler.checkInterval = time.Millisecond
+	scheduler.Start(context.Background())
+	<-repository.closeCalls
+
+	// a próxima verificação fica parada até release
+	repository.mu.Lock()
+	repository.release = make(chan struct{})
+	repository.mu.Unlock()
+	clock.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- scheduler.Shutdown(context.Background()) }()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("shutdown returned before the running check finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(repository.release)
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after the running check finished")
+	}
+	if status := repository.status(t, "auction"); status != auction_entity.Completed {
+		t.Fatalf("running check should close the auction, got status %d", status)
+	}
+}
+
+func TestAuctionScheduler_ShutdownTimesOut(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC)}
+	repository := newFakeAuctionRepository()
+	scheduler := newTestScheduler(repository, clock)
+	scheduler.checkInterval = time.Millisecond
+	scheduler.Start(context.Background())
+
+	repository.mu.Lock()
+	repository.release = make(chan struct{})
+	repository.mu.Unlock()
+	defer close(repository.release)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := scheduler.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestGetAuctionCheckInterval(t *testing.T) {
+	t.Setenv("AUCTION_CHECK_INTERVAL", "30s")
+	if interval := getAuctionCheckInterval(); interval != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", interval)
+	}
+
+	for _, value := range []string{"", "soon", "0s", "-1s"} {
+		t.Setenv("AUCTION_CHECK_INTERVAL", value)
+		if interval := getAuctionCheckInterval(); interval != 10*time.Second {
+			t.Fatalf("expected default 10s for %q, got %s", value, interval)
+		}
+	}
+}
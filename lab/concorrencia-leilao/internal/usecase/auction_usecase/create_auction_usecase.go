@@ -7,6 +7,7 @@ import (
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
 	"github.com/diogokimisima/fullcycle-auction/internal/usecase/bid_usecase"
 )
 
@@ -15,6 +16,10 @@ type AuctionInputDTO struct {
 	Category    string           `json:"category" binding:"required,min=2"`
 	Description string           `json:"description" binding:"required,min=10,max=200"`
 	Condition   ProductCondition `json:"condition"`
+
+	StartingPrice float64 `json:"starting_price" binding:"gte=0"`
+	ReservePrice  float64 `json:"reserve_price" binding:"gte=0"`
+	MinIncrement  float64 `json:"min_increment" binding:"gte=0"`
 }
 
 type AuctionOutputDTO struct {
@@ -25,20 +30,35 @@ type AuctionOutputDTO struct {
 	Condition   ProductCondition `json:"condition"`
 	Status      AuctionStatus    `json:"status"`
 	TimeStamp   time.Time        `json:"time_stamp" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndTime     time.Time        `json:"end_time" time_format:"2006-01-02T15:04:05Z07:00"`
+
+	StartingPrice float64 `json:"starting_price"`
+	ReservePrice  float64 `json:"reserve_price"`
+	MinIncrement  float64 `json:"min_increment"`
 }
 
+// WinningInfoOutputDTO traz o lance vencedor em Bid. Quando o maior lance não
+// alcança o preço de reserva não há vencedor: ele vem em HighestBid, com
+// ReserveMet falso e Message "reserve not met".
 type WinningInfoOutputDTO struct {
-	Auction AuctionOutputDTO          `json:"auction"`
-	Bid     *bid_usecase.BidOutputDTO `json:"bid,omitempty"`
+	Auction    AuctionOutputDTO          `json:"auction"`
+	Bid        *bid_usecase.BidOutputDTO `json:"bid,omitempty"`
+	HighestBid *bid_usecase.BidOutputDTO `json:"highest_bid,omitempty"`
+	ReserveMet bool                      `json:"reserve_met"`
+	Message    string                    `json:"message,omitempty"`
 }
 
+const MessageReserveNotMet = "reserve not met"
+
 func NewAuctionUseCase(
 	auctionRepository auction_entity.AuctionRepositoryInterface,
 	bidRepository bid_entity.BidEntityRepository,
+	auctionEvents *auction_event_usecase.Hub,
 ) AuctionUseCaseInterface {
 	return &AuctionUseCase{
 		auctionRepositoryInterface: auctionRepository,
 		bidRepositoryInterface:     bidRepository,
+		auctionEvents:              auctionEvents,
 	}
 }
 
@@ -47,6 +67,7 @@ type AuctionUseCaseInterface interface {
 	FindAuctionById(ctx context.Context, id string) (*AuctionOutputDTO, *internal_error.InternalError)
 	FindAuctions(ctx context.Context, status AuctionStatus, category, productName string) ([]AuctionOutputDTO, *internal_error.InternalError)
 	FindWinningBidByAuctionId(ctx context.Context, auctionId string) (*WinningInfoOutputDTO, *internal_error.InternalError)
+	SubscribeAuctionEvents(ctx context.Context, auctionId string) ([]auction_event_usecase.AuctionEvent, *auction_event_usecase.Subscription, *internal_error.InternalError)
 }
 
 type ProductCondition int64
@@ -55,6 +76,7 @@ type AuctionStatus int64
 type AuctionUseCase struct {
 	auctionRepositoryInterface auction_entity.AuctionRepositoryInterface
 	bidRepositoryInterface     bid_entity.BidEntityRepository
+	auctionEvents              *auction_event_usecase.Hub
 }
 
 func (au *AuctionUseCase) CreateAuction(
@@ -64,7 +86,10 @@ func (au *AuctionUseCase) CreateAuction(
 		auctionInput.ProductName,
 		auctionInput.Category,
 		auctionInput.Description,
-		auction_entity.ProductCondition(auctionInput.Condition))
+		auction_entity.ProductCondition(auctionInput.Condition),
+		auctionInput.StartingPrice,
+		auctionInput.ReservePrice,
+		auctionInput.MinIncrement)
 
 	if err != nil {
 		return err
@@ -24,6 +24,11 @@ func (au *AuctionUseCase) FindAuctionById(
 		Condition:   ProductCondition(auctionEntity.Condition),
 		Status:      AuctionStatus(auctionEntity.Status),
 		TimeStamp:   auctionEntity.TimeStamp,
+		EndTime:     auctionEntity.EndTime,
+
+		StartingPrice: auctionEntity.StartingPrice,
+		ReservePrice:  auctionEntity.ReservePrice,
+		MinIncrement:  auctionEntity.MinIncrement,
 	}
 
 	return auctionOutput, nil
@@ -52,6 +57,11 @@ func (au *AuctionUseCase) FindAuctions(
 			Condition:   ProductCondition(value.Condition),
 			Status:      AuctionStatus(value.Status),
 			TimeStamp:   value.TimeStamp,
+			EndTime:     value.EndTime,
+
+			StartingPrice: value.StartingPrice,
+			ReservePrice:  value.ReservePrice,
+			MinIncrement:  value.MinIncrement,
 		}
 		auctionOutputs = append(auctionOutputs, auctionOutput)
 	}
@@ -75,14 +85,23 @@ func (au *AuctionUseCase) FindWinningBidByAuctionId(
 		Condition:   ProductCondition(auction.Condition),
 		Status:      AuctionStatus(auction.Status),
 		TimeStamp:   auction.TimeStamp,
+		EndTime:     auction.EndTime,
+
+		StartingPrice: auction.StartingPrice,
+		ReservePrice:  auction.ReservePrice,
+		MinIncrement:  auction.MinIncrement,
 	}
 
 	bidWinnig, err := au.bidRepositoryInterface.FindWinnigBidByAuctionId(ctx, auction.Id)
 	if err != nil {
-		return &WinningInfoOutputDTO{
+		winningInfo := &WinningInfoOutputDTO{
 			Auction: auctionOutputDTO,
 			Bid:     nil,
-		}, nil
+		}
+		if auction.ReservePrice > 0 {
+			winningInfo.Message = MessageReserveNotMet
+		}
+		return winningInfo, nil
 	}
 
 	bidOutputDTO := &bid_usecase.BidOutputDTO{
@@ -93,8 +112,17 @@ func (au *AuctionUseCase) FindWinningBidByAuctionId(
 		Timestamp: bidWinnig.Timestamp,
 	}
 
+	if !auction.ReserveMet(bidWinnig.Amount) {
+		return &WinningInfoOutputDTO{
+			Auction:    auctionOutputDTO,
+			HighestBid: bidOutputDTO,
+			Message:    MessageReserveNotMet,
+		}, nil
+	}
+
 	return &WinningInfoOutputDTO{
-		Auction: auctionOutputDTO,
-		Bid:     bidOutputDTO,
+		Auction:    auctionOutputDTO,
+		Bid:        bidOutputDTO,
+		ReserveMet: true,
 	}, nil
 }
@@ -0,0 +1,125 @@
+package auction_usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/bid_usecase"
+)
+
+// fakeBidRepository responde o maior lance de cada leilão.
+type fakeBidRepository struct {
+	bid_entity.BidEntityRepository
+	winning map[string]bid_entity.Bid
+}
+
+func (r *fakeBidRepository) FindWinnigBidByAuctionId(
+	ctx context.Context, auctionId string) (*bid_entity.Bid, *internal_error.InternalError) {
+	bid, ok := r.winning[auctionId]
+	if !ok {
+		return nil, internal_error.NewNotFoundError("no bids found")
+	}
+	return &bid, nil
+}
+
+func TestFindWinningBidByAuctionId_Reserve(t *testing.T) {
+	ctx := context.Background()
+	auctionRepository := newFakeAuctionRepository()
+	for _, auction := range []auction_entity.Auction{
+		{Id: "met", ReservePrice: 1500},
+		{Id: "not-met", ReservePrice: 1500},
+		{Id: "no-bids", ReservePrice: 1500},
+		{Id: "no-reserve"},
+	} {
+		auctionRepository.CreateAuction(ctx, &auction)
+	}
+
+	useCase := NewAuctionUseCase(auctionRepository, &fakeBidRepository{winning: map[string]bid_entity.Bid{
+		"met":        {Id: "bid-met", Amount: 1500},
+		"not-met":    {Id: "bid-not-met", Amount: 1499.99},
+		"no-reserve": {Id: "bid-no-reserve", Amount: 1},
+	}}, auction_event_usecase.NewHub())
+
+	tests := []struct {
+		auctionId  string
+		winner     string
+		highest    string
+		reserveMet bool
+		message    string
+	}{
+		{"met", "bid-met", "", true, ""},
+		{"not-met", "", "bid-not-met", false, MessageReserveNotMet},
+		{"no-bids", "", "", false, MessageReserveNotMet},
+		{"no-reserve", "bid-no-reserve", "", true, ""},
+	}
+	for _, tt := range tests {
+		info, err := useCase.FindWinningBidByAuctionId(ctx, tt.auctionId)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.auctionId, err)
+		}
+
+		var winner, highest string
+		if info.Bid != nil {
+			winner = info.Bid.Id
+		}
+		if info.HighestBid != nil {
+			highest = info.HighestBid.Id
+		}
+		if winner != tt.winner || highest != tt.highest || info.ReserveMet != tt.reserveMet || info.Message != tt.message {
+			t.Errorf("%s: got winner=%q highest=%q reserve_met=%v message=%q", tt.auctionId, winner, highest, info.ReserveMet, info.Message)
+		}
+	}
+}
+
+func TestSubscribeAuctionEvents(t *testing.T) {
+	ctx := context.Background()
+	endTime := time.Now().Add(time.Hour)
+	auctionRepository := newFakeAuctionRepository()
+	auctionRepository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "active", Status: auction_entity.Active, EndTime: endTime})
+	auctionRepository.CreateAuction(ctx, &auction_entity.Auction{
+		Id: "completed", Status: auction_entity.Completed, EndTime: time.Now().Add(-time.Hour)})
+
+	useCase := NewAuctionUseCase(auctionRepository, &fakeBidRepository{winning: map[string]bid_entity.Bid{
+		"active":    {Id: "highest", Amount: 1200},
+		"completed": {Id: "winner", Amount: 2000},
+	}}, auction_event_usecase.NewHub())
+
+	events, subscription, err := useCase.SubscribeAuctionEvents(ctx, "active")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer subscription.Close()
+	if len(events) != 2 || events[0].Type != auction_event_usecase.EventHighestBid || events[1].Type != auction_event_usecase.EventCountdown {
+		t.Fatalf("expected highest bid and countdown, got %+v", events)
+	}
+	if bid := events[0].Data.(bid_usecase.BidOutputDTO); bid.Id != "highest" {
+		t.Fatalf("expected current highest bid, got %+v", bid)
+	}
+	if countdown := events[1].Data.(auction_event_usecase.CountdownOutputDTO); !countdown.EndTime.Equal(endTime) || countdown.RemainingSeconds <= 0 {
+		t.Fatalf("unexpected countdown %+v", countdown)
+	}
+
+	events, subscription, err = useCase.SubscribeAuctionEvents(ctx, "completed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subscription != nil {
+		t.Fatal("expected no subscription for a completed auction")
+	}
+	if len(events) != 1 || events[0].Type != auction_event_usecase.EventClosed {
+		t.Fatalf("expected only the closed event, got %+v", events)
+	}
+	if winningInfo := events[0].Data.(*WinningInfoOutputDTO); winningInfo.Bid == nil || winningInfo.Bid.Id != "winner" {
+		t.Fatalf("expected the winner in the closed event, got %+v", winningInfo)
+	}
+
+	if _, _, err := useCase.SubscribeAuctionEvents(ctx, "unknown"); err == nil {
+		t.Fatal("expected an error for an unknown auction")
+	}
+}
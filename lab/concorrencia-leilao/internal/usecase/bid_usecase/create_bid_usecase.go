@@ -4,11 +4,14 @@ import (
 	"context"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/diogokimisima/fullcycle-auction/configuration/logger"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
 	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
 )
 
 type BidInputDTO struct {
@@ -25,75 +28,151 @@ type BidOutputDTO struct {
 	Timestamp time.Time `json:"timestamp" time_format:"2006-01-02 15:04:05"`
 }
 
+const (
+	BidAccepted = "accepted"
+	BidPending  = "pending"
+)
+
+// BidResultOutputDTO é o resultado de CreateBid: accepted quando o batch
+// salvou o lance e pending quando o batch não terminou dentro de
+// BID_RESULT_TIMEOUT; o lance continua na fila e pode ser consultado depois.
+// Lances recusados retornam erro, com o motivo na mensagem.
+type BidResultOutputDTO struct {
+	Status string       `json:"status"`
+	Bid    BidOutputDTO `json:"bid"`
+}
+
+// pendingBid é um lance na fila do batch. O resultado da inserção é enviado
+// em result, que tem buffer para que o batch nunca espere pela requisição.
+type pendingBid struct {
+	bid    bid_entity.Bid
+	result chan *internal_error.InternalError
+}
+
+// auctionBids guarda o maior lance aceito de um leilão, incluindo os que ainda
+// estão no batch. mu fica com quem valida um lance do leilão durante toda a
+// validação, inclusive na busca do maior lance salvo, então os lances do mesmo
+// leilão são validados um de cada vez.
+type auctionBids struct {
+	mu         sync.Mutex
+	loaded     bool
+	highestBid float64
+	endTime    time.Time
+
+	// savedBid é o maior lance salvo, e o último publicado, e pending são os
+	// lances aceitos que ainda estão no batch, pelo ID; com eles o maior lance
+	// é recalculado quando um lance aceito não é salvo
+	savedBid float64
+	pending  map[string]float64
+}
+
+// recalculate volta highestBid para o maior entre o lance salvo e os que
+// ainda estão no batch.
+func (state *auctionBids) recalculate() {
+	state.highestBid = state.savedBid
+	for _, amount := range state.pending {
+		state.highestBid = max(state.highestBid, amount)
+	}
+}
+
 type BidUseCase struct {
-	BidRepository bid_entity.BidEntityRepository
+	BidRepository     bid_entity.BidEntityRepository
+	AuctionRepository auction_entity.AuctionRepositoryInterface
+	auctionEvents     auction_event_usecase.Publisher
 
 	timer               *time.Timer
 	maxBatchSize        int
 	batchInsertInterval time.Duration
-	bidChannel          chan bid_entity.Bid
+	resultTimeout       time.Duration
+	bidChannel          chan pendingBid
+
+	// closed impede novos lances depois do Shutdown; os envios para bidChannel
+	// seguram closedMutex para leitura, então o canal só fecha sem envios em
+	// andamento. done fecha quando o último batch foi processado.
+	closedMutex sync.RWMutex
+	closed      bool
+	done        chan struct{}
+
+	// shutdownCtx é o contexto do Shutdown, usado no último batch; é escrito
+	// antes de bidChannel fechar. stopBatches cancela os batches em andamento
+	// quando o Shutdown termina antes deles.
+	shutdownCtx context.Context
+	stopBatches context.CancelFunc
+
+	// leilões com lances recentes; auctionsMutex protege só o mapa, e cada
+	// leilão tem o seu lock, então a validação de um leilão não espera a dos
+	// outros
+	auctionsMutex sync.Mutex
+	auctions      map[string]*auctionBids
 }
 
-func NewBidUseCase(bidRepository bid_entity.BidEntityRepository) BidUseCaseInterface {
+func NewBidUseCase(
+	bidRepository bid_entity.BidEntityRepository,
+	auctionRepository auction_entity.AuctionRepositoryInterface,
+	auctionEvents auction_event_usecase.Publisher) BidUseCaseInterface {
 	maxSizeInterval := getMaxBatchSizeInterval()
 	maxBatchSize := getMaxBatchSize()
 
 	bidUsecase := &BidUseCase{
 		BidRepository:       bidRepository,
+		AuctionRepository:   auctionRepository,
+		auctionEvents:       auctionEvents,
 		maxBatchSize:        maxBatchSize,
 		batchInsertInterval: maxSizeInterval,
+		resultTimeout:       getBidResultTimeout(),
 		timer:               time.NewTimer(maxSizeInterval),
-		bidChannel:          make(chan bid_entity.Bid, maxBatchSize),
+		bidChannel:          make(chan pendingBid, maxBatchSize),
+		done:                make(chan struct{}),
+		auctions:            make(map[string]*auctionBids),
 	}
 
-	bidUsecase.triggerCreateRoutine(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	bidUsecase.stopBatches = cancel
+	bidUsecase.triggerCreateRoutine(ctx)
 
 	return bidUsecase
 }
 
-var bidBatch []bid_entity.Bid
-
 type BidUseCaseInterface interface {
-	CreateBid(ctx context.Context, bidInputDTO BidInputDTO) *internal_error.InternalError
+	CreateBid(ctx context.Context, bidInputDTO BidInputDTO) (*BidResultOutputDTO, *internal_error.InternalError)
 
 	FindBidByAuctionId(
 		ctx context.Context, auctionId string) ([]BidOutputDTO, *internal_error.InternalError)
 
 	FindWinnigBidByAuctionId(
 		ctx context.Context, auctionId string) (*BidOutputDTO, *internal_error.InternalError)
+
+	Shutdown(ctx context.Context) error
+
+	ForgetClosedAuctions(now time.Time)
 }
 
 func (bu *BidUseCase) triggerCreateRoutine(ctx context.Context) {
 	go func() {
-		defer close(bu.bidChannel)
+		defer close(bu.done)
+		defer bu.timer.Stop()
+
+		var bidBatch []pendingBid
 
 		for {
 			select {
-			case bidEntity, ok := <-bu.bidChannel:
+			case pending, ok := <-bu.bidChannel:
 				if !ok {
-					if len(bidBatch) > 0 {
-						if err := bu.BidRepository.CreateBid(ctx, bidBatch); err != nil {
-							logger.Error("error trying to process bid batch:", err)
-						}
-					}
+					bu.processBatch(bu.shutdownCtx, bidBatch)
 					return
 				}
 
-				bidBatch = append(bidBatch, bidEntity)
+				bidBatch = append(bidBatch, pending)
 
 				if len(bidBatch) >= bu.maxBatchSize {
-					if err := bu.BidRepository.CreateBid(ctx, bidBatch); err != nil {
-						logger.Error("error trying to process bid batch:", err)
-					}
+					bu.processBatch(ctx, bidBatch)
 
 					bidBatch = nil
 					bu.timer.Reset(bu.batchInsertInterval)
 				}
 
 			case <-bu.timer.C:
-				if err := bu.BidRepository.CreateBid(ctx, bidBatch); err != nil {
-					logger.Error("error trying to process bid batch:", err)
-				}
+				bu.processBatch(ctx, bidBatch)
 				bidBatch = nil
 				bu.timer.Reset(bu.batchInsertInterval)
 			}
@@ -101,20 +180,272 @@ func (bu *BidUseCase) triggerCreateRoutine(ctx context.Context) {
 	}()
 }
 
+// processBatch insere o batch e entrega a cada lance o seu resultado.
+func (bu *BidUseCase) processBatch(ctx context.Context, bidBatch []pendingBid) {
+	if len(bidBatch) == 0 {
+		return
+	}
+
+	bidEntities := make([]bid_entity.Bid, len(bidBatch))
+	for i, pending := range bidBatch {
+		bidEntities[i] = pending.bid
+	}
+
+	results := bu.BidRepository.CreateBid(ctx, bidEntities)
+
+	for i, pending := range bidBatch {
+		var err *internal_error.InternalError
+		if i < len(results) {
+			err = results[i]
+		} else {
+			err = internal_error.NewInternalServerError("bid result missing from batch")
+		}
+
+		if err != nil {
+			logger.Error("error trying to process bid "+pending.bid.Id, err)
+			bu.bidFailed(pending.bid, err)
+		} else if bu.bidSaved(pending.bid) {
+			bu.publishHighestBid(pending.bid)
+		}
+		pending.result <- err
+	}
+}
+
+// publishHighestBid avisa os inscritos do leilão sobre o novo maior lance salvo.
+func (bu *BidUseCase) publishHighestBid(bid bid_entity.Bid) {
+	bu.auctionEvents.Publish(auction_event_usecase.AuctionEvent{
+		Type:      auction_event_usecase.EventHighestBid,
+		AuctionId: bid.AuctionId,
+		Timestamp: bid.Timestamp,
+		Data: BidOutputDTO{
+			Id:        bid.Id,
+			UserId:    bid.UserId,
+			AuctionId: bid.AuctionId,
+			Amount:    bid.Amount,
+			Timestamp: bid.Timestamp,
+		},
+	})
+}
+
+// CreateBid valida o lance, coloca-o no batch e espera, por até
+// BID_RESULT_TIMEOUT ou até ctx terminar, o resultado da inserção.
 func (bu *BidUseCase) CreateBid(
 	ctx context.Context,
-	bidInputDTO BidInputDTO) *internal_error.InternalError {
+	bidInputDTO BidInputDTO) (*BidResultOutputDTO, *internal_error.InternalError) {
 
 	bidEntity, err := bid_entity.CreateBid(bidInputDTO.UserId, bidInputDTO.AuctionId, bidInputDTO.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bu.acceptBid(ctx, bidEntity); err != nil {
+		return nil, err
+	}
+
+	pending := pendingBid{bid: *bidEntity, result: make(chan *internal_error.InternalError, 1)}
+	if !bu.enqueue(pending) {
+		bu.bidFailed(*bidEntity, nil)
+		return nil, internal_error.NewServiceUnavailableError("server is shutting down")
+	}
+
+	output := &BidResultOutputDTO{
+		Status: BidPending,
+		Bid: BidOutputDTO{
+			Id:        bidEntity.Id,
+			UserId:    bidEntity.UserId,
+			AuctionId: bidEntity.AuctionId,
+			Amount:    bidEntity.Amount,
+			Timestamp: bidEntity.Timestamp,
+		},
+	}
+
+	timeout := time.NewTimer(bu.resultTimeout)
+	defer timeout.Stop()
+
+	select {
+	case err := <-pending.result:
+		if err != nil {
+			return nil, err
+		}
+		output.Status = BidAccepted
+	case <-timeout.C:
+	case <-ctx.Done():
+	}
+
+	return output, nil
+}
+
+// enqueue coloca o lance no batch, a menos que o Shutdown já tenha começado.
+func (bu *BidUseCase) enqueue(pending pendingBid) bool {
+	bu.closedMutex.RLock()
+	defer bu.closedMutex.RUnlock()
+
+	if bu.closed {
+		return false
+	}
+
+	bu.bidChannel <- pending
+	return true
+}
+
+// Shutdown para de aceitar lances, processa os lances que ainda estão na fila
+// com ctx e espera o último batch ser salvo, ou ctx terminar. Nesse caso os
+// batches em andamento são cancelados e os lances não salvos recebem o erro.
+func (bu *BidUseCase) Shutdown(ctx context.Context) error {
+	bu.closedMutex.Lock()
+	if !bu.closed {
+		bu.closed = true
+		bu.shutdownCtx = ctx
+		close(bu.bidChannel)
+	}
+	bu.closedMutex.Unlock()
+
+	select {
+	case <-bu.done:
+		return nil
+	case <-ctx.Done():
+		bu.stopBatches()
+		return ctx.Err()
+	}
+}
+
+// acceptBid valida o lance contra as regras do leilão e o maior lance atual
+// antes de ele entrar no batch, para que o lance recusado receba o erro na
+// própria requisição. Os lances do mesmo leilão são validados um de cada vez.
+func (bu *BidUseCase) acceptBid(ctx context.Context, bid *bid_entity.Bid) *internal_error.InternalError {
+	auction, err := bu.AuctionRepository.FindAuctionById(ctx, bid.AuctionId)
 	if err != nil {
 		return err
 	}
 
-	bu.bidChannel <- *bidEntity
+	if auction.Status != auction_entity.Active || auction.IsExpired(bid.Timestamp) {
+		bu.forgetAuction(auction.Id)
+		return internal_error.NewBadRequestError("auction is closed")
+	}
+
+	state := bu.auctionBids(auction)
+	state.mu.Lock()
+	defer state.mu.Unlock()
 
+	if !state.loaded {
+		savedBid, err := bu.BidRepository.FindHighestBidAmount(ctx, auction.Id)
+		if err != nil {
+			return err
+		}
+		state.savedBid = savedBid
+		state.recalculate()
+		state.loaded = true
+	}
+
+	if err := auction.ValidateBid(bid.Amount, state.highestBid); err != nil {
+		return err
+	}
+
+	state.highestBid = bid.Amount
+	state.pending[bid.Id] = bid.Amount
 	return nil
 }
 
+// auctionBids retorna o estado do leilão, criando-o no primeiro lance.
+func (bu *BidUseCase) auctionBids(auction *auction_entity.Auction) *auctionBids {
+	bu.auctionsMutex.Lock()
+	defer bu.auctionsMutex.Unlock()
+
+	state, ok := bu.auctions[auction.Id]
+	if !ok {
+		state = &auctionBids{pending: make(map[string]float64)}
+		bu.auctions[auction.Id] = state
+	}
+	state.endTime = auction.EndTime
+	return state
+}
+
+func (bu *BidUseCase) forgetAuction(auctionId string) {
+	bu.auctionsMutex.Lock()
+	defer bu.auctionsMutex.Unlock()
+	delete(bu.auctions, auctionId)
+}
+
+// pendingState tira o lance dos aceitos do leilão e retorna o estado com mu
+// travado; ok é falso se o estado foi descartado enquanto o lance estava no
+// batch.
+func (bu *BidUseCase) pendingState(bid bid_entity.Bid) (*auctionBids, bool) {
+	bu.auctionsMutex.Lock()
+	state, ok := bu.auctions[bid.AuctionId]
+	bu.auctionsMutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	state.mu.Lock()
+	if _, ok := state.pending[bid.Id]; !ok {
+		state.mu.Unlock()
+		return nil, false
+	}
+	delete(state.pending, bid.Id)
+	return state, true
+}
+
+// bidSaved registra o lance salvo pelo batch e indica se ele é o novo maior
+// lance salvo, que deve ser publicado. Os lances validados em sequência podem
+// chegar ao batch fora de ordem, então o lance menor que o último salvo não
+// é publicado.
+func (bu *BidUseCase) bidSaved(bid bid_entity.Bid) bool {
+	state, ok := bu.pendingState(bid)
+	if !ok {
+		return false
+	}
+	defer state.mu.Unlock()
+	if bid.Amount <= state.savedBid {
+		return false
+	}
+	state.savedBid = bid.Amount
+	return true
+}
+
+// bidFailed tira do estado do leilão o lance aceito que não foi salvo. Só se
+// ele ainda era o maior lance, o maior volta a ser o salvo ou um dos que estão
+// no batch; os lances maiores aceitos depois dele continuam valendo. Quando o
+// lance perdeu para um lance salvo por outra instância, o maior lance salvo é
+// lido de novo do banco no próximo lance.
+func (bu *BidUseCase) bidFailed(bid bid_entity.Bid, err *internal_error.InternalError) {
+	state, ok := bu.pendingState(bid)
+	if !ok {
+		return
+	}
+	defer state.mu.Unlock()
+	if err != nil && err.Message == bid_entity.BidOutbidMessage {
+		state.loaded = false
+	}
+	if state.highestBid == bid.Amount {
+		state.recalculate()
+	}
+}
+
+// ForgetClosedAuctions descarta o estado dos leilões que terminaram até now.
+// É chamado pelo scheduler depois de fechá-los, então o mapa só guarda os
+// leilões em andamento.
+func (bu *BidUseCase) ForgetClosedAuctions(now time.Time) {
+	bu.auctionsMutex.Lock()
+	defer bu.auctionsMutex.Unlock()
+
+	for auctionId, state := range bu.auctions {
+		if !now.Before(state.endTime) {
+			delete(bu.auctions, auctionId)
+		}
+	}
+}
+
+func getBidResultTimeout() time.Duration {
+	resultTimeout := os.Getenv("BID_RESULT_TIMEOUT")
+	duration, err := time.ParseDuration(resultTimeout)
+	if err != nil || duration <= 0 {
+		return 30 * time.Second
+	}
+
+	return duration
+}
+
 func getMaxBatchSizeInterval() time.Duration {
 	batchInsertInterval := os.Getenv("BATCH_INSERT_INTERVAL")
 	duration, err := time.ParseDuration(batchInsertInterval)
@@ -0,0 +1,542 @@
+package bid_usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/auction_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/entity/bid_entity"
+	"github.com/diogokimisima/fullcycle-auction/internal/internal_error"
+	"github.com/diogokimisima/fullcycle-auction/internal/usecase/auction_event_usecase"
+	"github.com/google/uuid"
+)
+
+type fakeAuctionRepository struct {
+	auction_entity.AuctionRepositoryInterface
+	auctions map[string]*auction_entity.Auction
+}
+
+func (r *fakeAuctionRepository) FindAuctionById(
+	ctx context.Context, id string) (*auction_entity.Auction, *internal_error.InternalError) {
+	auction, ok := r.auctions[id]
+	if !ok {
+		return nil, internal_error.NewNotFoundError("auction not found")
+	}
+	return auction, nil
+}
+
+// fakeBidRepository guarda os lances inseridos pelo batch e recusa os lances
+// dos leilões em closedAuctions, como o repositório faz com leilões fechados
+// depois da validação, e os que não superam o maior lance já guardado.
+type fakeBidRepository struct {
+	mu             sync.Mutex
+	bids           []bid_entity.Bid
+	closedAuctions map[string]bool
+
+	// com slowAuction, a busca do maior lance desse leilão espera release
+	slowAuction string
+	release     chan struct{}
+
+	// rejectedAmounts recusa os lances desses valores; com releaseInsert, cada
+	// inserção avisa em inserts e espera um envio em releaseInsert
+	rejectedAmounts map[float64]bool
+	inserts         chan struct{}
+	releaseInsert   chan struct{}
+
+	// com insertCanceled, cada inserção espera o fim do seu contexto e envia
+	// o erro dele
+	insertCanceled chan error
+}
+
+func (r *fakeBidRepository) CreateBid(
+	ctx context.Context, bidEntities []bid_entity.Bid) []*internal_error.InternalError {
+	if r.releaseInsert != nil {
+		r.inserts <- struct{}{}
+		<-r.releaseInsert
+	}
+	if r.insertCanceled != nil {
+		<-ctx.Done()
+		r.insertCanceled <- ctx.Err()
+		errs := make([]*internal_error.InternalError, len(bidEntities))
+		for i := range errs {
+			errs[i] = internal_error.NewInternalServerError("error trying to insert bid: " + ctx.Err().Error())
+		}
+		return errs
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := make([]*internal_error.InternalError, len(bidEntities))
+	for i, bid := range bidEntities {
+		if r.closedAuctions[bid.AuctionId] {
+			errs[i] = internal_error.NewBadRequestError("auction is closed")
+			continue
+		}
+		if r.rejectedAmounts[bid.Amount] {
+			errs[i] = internal_error.NewInternalServerError("error trying to insert bid")
+			continue
+		}
+		if r.outbid(bid) {
+			errs[i] = internal_error.NewBadRequestError(bid_entity.BidOutbidMessage)
+			continue
+		}
+		r.bids = append(r.bids, bid)
+	}
+	return errs
+}
+
+func (r *fakeBidRepository) outbid(bid bid_entity.Bid) bool {
+	for _, saved := range r.bids {
+		if saved.AuctionId == bid.AuctionId && saved.Amount >= bid.Amount {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeBidRepository) FindBidByAuctionId(
+	ctx context.Context, auctionId string) ([]bid_entity.Bid, *internal_error.InternalError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var bids []bid_entity.Bid
+	for _, bid := range r.bids {
+		if bid.AuctionId == auctionId {
+			bids = append(bids, bid)
+		}
+	}
+	return bids, nil
+}
+
+func (r *fakeBidRepository) FindHighestBidAmount(
+	ctx context.Context, auctionId string) (float64, *internal_error.InternalError) {
+	if auctionId == r.slowAuction {
+		<-r.release
+	}
+	winning, err := r.FindWinnigBidByAuctionId(ctx, auctionId)
+	if err != nil {
+		return 0, nil
+	}
+	return winning.Amount, nil
+}
+
+func (r *fakeBidRepository) FindWinnigBidByAuctionId(
+	ctx context.Context, auctionId string) (*bid_entity.Bid, *internal_error.InternalError) {
+	bids, _ := r.FindBidByAuctionId(ctx, auctionId)
+	var winning *bid_entity.Bid
+	for i := range bids {
+		if winning == nil || bids[i].Amount > winning.Amount {
+			winning = &bids[i]
+		}
+	}
+	if winning == nil {
+		return nil, internal_error.NewNotFoundError("no bids found")
+	}
+	return winning, nil
+}
+
+// fakePublisher guarda os eventos publicados pelo batch.
+type fakePublisher struct {
+	mu     sync.Mutex
+	events []auction_event_usecase.AuctionEvent
+}
+
+func (p *fakePublisher) Publish(event auction_event_usecase.AuctionEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+func newTestAuction(status auction_entity.AuctionStatus, endTime time.Time) *auction_entity.Auction {
+	return &auction_entity.Auction{
+		Id:            uuid.New().String(),
+		Status:        status,
+		EndTime:       endTime,
+		StartingPrice: 1000,
+		MinIncrement:  50,
+	}
+}
+
+func newTestUseCase(
+	t *testing.T, bidRepository *fakeBidRepository, auctions ...*auction_entity.Auction) *BidUseCase {
+	t.Helper()
+	t.Setenv("BATCH_INSERT_INTERVAL", "10ms")
+
+	auctionRepository := &fakeAuctionRepository{auctions: make(map[string]*auction_entity.Auction)}
+	for _, auction := range auctions {
+		auctionRepository.auctions[auction.Id] = auction
+	}
+	return NewBidUseCase(bidRepository, auctionRepository, &fakePublisher{}).(*BidUseCase)
+}
+
+func TestCreateBid_Rules(t *testing.T) {
+	ctx := context.Background()
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	closed := newTestAuction(auction_entity.Completed, time.Now().Add(time.Hour))
+	expired := newTestAuction(auction_entity.Active, time.Now().Add(-time.Second))
+
+	bidRepository := &fakeBidRepository{}
+	// um lance de uma execução anterior já está no banco
+	bidRepository.CreateBid(ctx, []bid_entity.Bid{{Id: uuid.New().String(), AuctionId: auction.Id, Amount: 1000}})
+
+	useCase := newTestUseCase(t, bidRepository, auction, closed, expired)
+	userId := uuid.New().String()
+
+	tests := []struct {
+		name      string
+		auctionId string
+		amount    float64
+		accepted  bool
+	}{
+		{"below the minimum increment", auction.Id, 1049, false},
+		{"beats the stored highest bid", auction.Id, 1050, true},
+		{"does not beat the pending bid", auction.Id, 1050, false},
+		{"beats the pending bid", auction.Id, 1100, true},
+		{"closed auction", closed.Id, 5000, false},
+		{"expired auction", expired.Id, 5000, false},
+		{"unknown auction", uuid.New().String(), 5000, false},
+	}
+	for _, tt := range tests {
+		result, err := useCase.CreateBid(ctx, BidInputDTO{UserId: userId, AuctionId: tt.auctionId, Amount: tt.amount})
+		if accepted := err == nil; accepted != tt.accepted {
+			t.Errorf("%s: expected accepted=%v, got error %v", tt.name, tt.accepted, err)
+		}
+		if err == nil && result.Status != BidAccepted {
+			t.Errorf("%s: expected status %q, got %q", tt.name, BidAccepted, result.Status)
+		}
+	}
+}
+
+func TestCreateBid_ConcurrentBidsForTheSameAmount(t *testing.T) {
+	ctx := context.Background()
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	useCase := newTestUseCase(t, &fakeBidRepository{}, auction)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000})
+			if err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Fatalf("expected only one of the equal bids to be accepted, got %d", accepted)
+	}
+}
+
+func TestCreateBid_FailedBidKeepsHigherQueuedBids(t *testing.T) {
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	bidRepository := &fakeBidRepository{
+		rejectedAmounts: map[float64]bool{1000: true},
+		inserts:         make(chan struct{}, 10),
+		releaseInsert:   make(chan struct{}),
+	}
+	useCase := newTestUseCase(t, bidRepository, auction)
+	defer close(bidRepository.releaseInsert)
+
+	// com o contexto cancelado, CreateBid retorna pending logo depois de
+	// colocar o lance no batch
+	pendingCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := useCase.CreateBid(pendingCtx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-bidRepository.inserts
+
+	// o lance maior é aceito enquanto o primeiro ainda está sendo inserido
+	if _, err := useCase.CreateBid(pendingCtx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1100}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// o primeiro lance falha e o segundo fica esperando a próxima inserção
+	bidRepository.releaseInsert <- struct{}{}
+	<-bidRepository.inserts
+
+	_, err := useCase.CreateBid(pendingCtx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1100})
+	if err == nil || err.Err != "bad_request" {
+		t.Fatalf("expected the queued bid of 1100 to remain the highest bid, got %v", err)
+	}
+}
+
+func TestCreateBid_SlowLookupDoesNotBlockOtherAuctions(t *testing.T) {
+	ctx := context.Background()
+	slow := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	other := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	bidRepository := &fakeBidRepository{slowAuction: slow.Id, release: make(chan struct{})}
+	useCase := newTestUseCase(t, bidRepository, slow, other)
+
+	slowDone := make(chan *internal_error.InternalError, 1)
+	go func() {
+		_, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: slow.Id, Amount: 1000})
+		slowDone <- err
+	}()
+
+	// o lance do outro leilão é validado enquanto o primeiro espera o banco
+	done := make(chan *internal_error.InternalError, 1)
+	go func() {
+		_, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: other.Id, Amount: 1000})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("bid for another auction waited for the slow lookup")
+	}
+
+	close(bidRepository.release)
+	if err := <-slowDone; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestForgetClosedAuctions(t *testing.T) {
+	ctx := context.Background()
+	ending := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	running := newTestAuction(auction_entity.Active, time.Now().Add(2*time.Hour))
+	useCase := newTestUseCase(t, &fakeBidRepository{}, ending, running)
+
+	for _, auction := range []*auction_entity.Auction{ending, running} {
+		if _, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// o maior lance salvo e publicado sai junto com o leilão
+	useCase.ForgetClosedAuctions(ending.EndTime)
+
+	useCase.auctionsMutex.Lock()
+	defer useCase.auctionsMutex.Unlock()
+	if _, ok := useCase.auctions[ending.Id]; ok {
+		t.Fatal("closed auction should be forgotten")
+	}
+	if _, ok := useCase.auctions[running.Id]; !ok {
+		t.Fatal("running auction should be kept")
+	}
+}
+
+func TestCreateBid_ReturnsBatchRejection(t *testing.T) {
+	ctx := context.Background()
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	// o leilão fecha entre a validação e a inserção do batch
+	bidRepository := &fakeBidRepository{closedAuctions: map[string]bool{auction.Id: true}}
+	useCase := newTestUseCase(t, bidRepository, auction)
+
+	_, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 2000})
+	if err == nil || err.Err != "bad_request" || err.Message != "auction is closed" {
+		t.Fatalf("expected the batch rejection, got %v", err)
+	}
+
+	// o lance recusado não conta mais como o maior lance do leilão
+	bidRepository.mu.Lock()
+	bidRepository.closedAuctions = nil
+	bidRepository.mu.Unlock()
+
+	result, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000})
+	if err != nil || result.Status != BidAccepted {
+		t.Fatalf("expected bid to be accepted after the rejection, got %+v, %v", result, err)
+	}
+	if len(bidRepository.bids) != 1 || bidRepository.bids[0].Id != result.Bid.Id {
+		t.Fatalf("expected only the accepted bid to be stored, got %+v", bidRepository.bids)
+	}
+}
+
+func TestCreateBid_RefreshesTheHighestBidWhenOutbid(t *testing.T) {
+	ctx := context.Background()
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	bidRepository := &fakeBidRepository{}
+	useCase := newTestUseCase(t, bidRepository, auction)
+
+	if _, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// outra instância salva um lance maior que o conhecido por esta
+	bidRepository.CreateBid(ctx, []bid_entity.Bid{{Id: uuid.New().String(), AuctionId: auction.Id, Amount: 2000}})
+
+	_, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1500})
+	if err == nil || err.Err != "bad_request" || err.Message != bid_entity.BidOutbidMessage {
+		t.Fatalf("expected the bid to be outbid in the repository, got %v", err)
+	}
+
+	// o maior lance é lido de novo do repositório, então o próximo lance
+	// menor é recusado sem chegar ao batch
+	_, err = useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1600})
+	if err == nil || err.Err != "bad_request" || err.Message == bid_entity.BidOutbidMessage {
+		t.Fatalf("expected the bid to be rejected against the refreshed highest bid, got %v", err)
+	}
+	if _, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 2050}); err != nil {
+		t.Fatalf("expected the bid over the refreshed highest bid to be accepted, got %v", err)
+	}
+}
+
+func TestCreateBid_PendingWhenBatchIsSlow(t *testing.T) {
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	// o batch só seria processado depois do timeout da resposta
+	t.Setenv("BATCH_INSERT_INTERVAL", "1h")
+	t.Setenv("BID_RESULT_TIMEOUT", "1ms")
+	useCase := NewBidUseCase(&fakeBidRepository{}, &fakeAuctionRepository{
+		auctions: map[string]*auction_entity.Auction{auction.Id: auction}}, &fakePublisher{}).(*BidUseCase)
+
+	result, err := useCase.CreateBid(context.Background(),
+		BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != BidPending || result.Bid.Amount != 1000 || result.Bid.Id == "" {
+		t.Fatalf("expected a pending result for the queued bid, got %+v", result)
+	}
+
+	// a requisição cancelada também recebe o lance como pendente
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	useCase.resultTimeout = time.Hour
+	result, err = useCase.CreateBid(ctx,
+		BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1100})
+	if err != nil || result.Status != BidPending {
+		t.Fatalf("expected a pending result after cancel, got %+v, %v", result, err)
+	}
+}
+
+func TestGetBidResultTimeout(t *testing.T) {
+	t.Setenv("BID_RESULT_TIMEOUT", "5s")
+	if timeout := getBidResultTimeout(); timeout != 5*time.Second {
+		t.Fatalf("expected 5s, got %s", timeout)
+	}
+
+	for _, value := range []string{"", "soon", "0s"} {
+		t.Setenv("BID_RESULT_TIMEOUT", value)
+		if timeout := getBidResultTimeout(); timeout != 30*time.Second {
+			t.Fatalf("expected default 30s for %q, got %s", value, timeout)
+		}
+	}
+}
+
+func TestShutdown_FlushesQueuedBids(t *testing.T) {
+	auctions := []*auction_entity.Auction{
+		newTestAuction(auction_entity.Active, time.Now().Add(time.Hour)),
+		newTestAuction(auction_entity.Active, time.Now().Add(time.Hour)),
+	}
+	bidRepository := &fakeBidRepository{}
+	// sem o Shutdown os lances só seriam salvos depois de uma hora
+	t.Setenv("BATCH_INSERT_INTERVAL", "1h")
+	t.Setenv("MAX_BATCH_SIZE", "10")
+	t.Setenv("BID_RESULT_TIMEOUT", "1ms")
+	useCase := NewBidUseCase(bidRepository, &fakeAuctionRepository{auctions: map[string]*auction_entity.Auction{
+		auctions[0].Id: auctions[0], auctions[1].Id: auctions[1]}}, &fakePublisher{})
+
+	for _, auction := range auctions {
+		result, err := useCase.CreateBid(context.Background(),
+			BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000})
+		if err != nil || result.Status != BidPending {
+			t.Fatalf("expected bid to be queued, got %+v, %v", result, err)
+		}
+	}
+
+	if err := useCase.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bidRepository.bids) != len(auctions) {
+		t.Fatalf("expected the %d queued bids to be stored, got %d", len(auctions), len(bidRepository.bids))
+	}
+
+	_, err := useCase.CreateBid(context.Background(),
+		BidInputDTO{UserId: uuid.New().String(), AuctionId: auctions[0].Id, Amount: 2000})
+	if err == nil || err.Err != "service_unavailable" {
+		t.Fatalf("expected bids to be refused after shutdown, got %v", err)
+	}
+	// Shutdown pode ser chamado de novo
+	if err := useCase.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error on second shutdown: %v", err)
+	}
+}
+
+func TestShutdown_CancelsTheFlushAtTheDeadline(t *testing.T) {
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	bidRepository := &fakeBidRepository{insertCanceled: make(chan error, 1)}
+	t.Setenv("BATCH_INSERT_INTERVAL", "1h")
+	t.Setenv("BID_RESULT_TIMEOUT", "1ms")
+	useCase := NewBidUseCase(bidRepository, &fakeAuctionRepository{
+		auctions: map[string]*auction_entity.Auction{auction.Id: auction}}, &fakePublisher{}).(*BidUseCase)
+
+	if _, err := useCase.CreateBid(context.Background(),
+		BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: 1000}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := useCase.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown deadline, got %v", err)
+	}
+
+	// o último batch usa o prazo do Shutdown e a rotina do batch termina
+	select {
+	case err := <-bidRepository.insertCanceled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the flush to end at the shutdown deadline, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the flush to be canceled")
+	}
+	select {
+	case <-useCase.done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the batch routine to stop")
+	}
+}
+
+func TestCreateBid_PublishesSavedHighestBids(t *testing.T) {
+	ctx := context.Background()
+	auction := newTestAuction(auction_entity.Active, time.Now().Add(time.Hour))
+	publisher := &fakePublisher{}
+	t.Setenv("BATCH_INSERT_INTERVAL", "10ms")
+	useCase := NewBidUseCase(&fakeBidRepository{}, &fakeAuctionRepository{
+		auctions: map[string]*auction_entity.Auction{auction.Id: auction}}, publisher).(*BidUseCase)
+
+	for _, amount := range []float64{1000, 1100} {
+		if _, err := useCase.CreateBid(ctx, BidInputDTO{UserId: uuid.New().String(), AuctionId: auction.Id, Amount: amount}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// um lance menor, aceito antes do maior, que chega ao batch depois dele
+	// não é publicado
+	lower := bid_entity.Bid{Id: uuid.New().String(), AuctionId: auction.Id, Amount: 1050}
+	state := useCase.auctions[auction.Id]
+	state.mu.Lock()
+	state.pending[lower.Id] = lower.Amount
+	state.mu.Unlock()
+	useCase.processBatch(ctx, []pendingBid{{bid: lower, result: make(chan *internal_error.InternalError, 1)}})
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	if len(publisher.events) != 2 {
+		t.Fatalf("expected 2 highest bid events, got %d", len(publisher.events))
+	}
+	for i, amount := range []float64{1000, 1100} {
+		event := publisher.events[i]
+		if event.Type != auction_event_usecase.EventHighestBid || event.AuctionId != auction.Id {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		if bid := event.Data.(BidOutputDTO); bid.Amount != amount {
+			t.Fatalf("expected highest bid %v, got %v", amount, bid.Amount)
+		}
+	}
+}